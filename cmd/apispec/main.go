@@ -0,0 +1,36 @@
+// apispec записывает опубликованные api/openapi.json и
+// api/order.schema.json. Запускается через make spec.
+package main
+
+import (
+	"flag"
+	"log"
+	"orderservice/internal/apispec"
+	"orderservice/internal/server"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	dir := flag.String("out", "api", "output directory")
+	flag.Parse()
+
+	files := map[string]any{
+		"openapi.json":      server.OpenAPI(),
+		"order.schema.json": apispec.OrderSchema(),
+	}
+	for name, doc := range files {
+		if err := write(filepath.Join(*dir, name), doc); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func write(path string, doc any) error {
+	data, err := apispec.MarshalIndent(doc)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
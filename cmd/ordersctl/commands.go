@@ -0,0 +1,445 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderdb/orderpsql"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/orderio"
+	"orderservice/internal/schema"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+func filterFlags(fs *flag.FlagSet) *orderdb.Filter {
+	filter := &orderdb.Filter{}
+	fs.StringVar(&filter.CustomerID, "customer", "", "filter by customer_id")
+	fs.StringVar(&filter.TrackNumber, "track", "", "filter by track_number")
+	fs.StringVar(&filter.DeliveryService, "delivery-service", "", "filter by delivery_service")
+	fs.StringVar(&filter.Currency, "currency", "", "filter by payment currency")
+	fs.StringVar(&filter.Locale, "locale", "", "filter by locale")
+	fs.IntVar(&filter.Limit, "limit", 0, "maximum number of orders")
+	fs.IntVar(&filter.Offset, "offset", 0, "number of orders to skip")
+	fs.Func("created-from", "orders created at or after this date or RFC3339 time", timeFlag(&filter.CreatedFrom))
+	fs.Func("created-to", "orders created before this date or RFC3339 time", timeFlag(&filter.CreatedTo))
+	return filter
+}
+
+func timeFlag(t *time.Time) func(string) error {
+	return func(value string) error {
+		if d, err := time.Parse(time.DateOnly, value); err == nil {
+			*t = d
+			return nil
+		}
+
+		ts, err := schema.ParseTimestamp(value)
+		if err != nil {
+			return err
+		}
+
+		*t = ts.Time
+		return nil
+	}
+}
+
+func orderUIDArgs(args []string) ([]schema.OrderUID, error) {
+	if len(args) == 0 {
+		return nil, errors.New("order_uid is required")
+	}
+
+	uids := make([]schema.OrderUID, len(args))
+	for i, arg := range args {
+		uids[i] = schema.OrderUID(arg)
+	}
+
+	return uids, nil
+}
+
+func runGet(ctx context.Context, app *App, args []string) error {
+	uids, err := orderUIDArgs(args)
+	if err != nil {
+		return err
+	}
+
+	reader, err := app.Reader()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	for _, uid := range uids {
+		order, err := reader.GetOrder(ctx, uid)
+		if err != nil {
+			return fmt.Errorf("%s: %w", uid, err)
+		}
+
+		if err := enc.Encode(&order); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runList(ctx context.Context, app *App, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	filter := filterFlags(fs)
+	asJSON := fs.Bool("json", false, "print orders as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	reader, err := app.Reader()
+	if err != nil {
+		return err
+	}
+
+	orders, err := reader.ListOrders(ctx, *filter)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(orders)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ORDER_UID\tCREATED\tCUSTOMER\tTRACK\tDELIVERY\tAMOUNT\tITEMS")
+	for _, o := range orders {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d %s\t%d\n",
+			o.OrderUID, o.DateCreated, o.CustomerID, o.TrackNumber, o.DeliveryService,
+			o.Payment.Amount, o.Payment.Currency, len(o.Items))
+	}
+
+	return w.Flush()
+}
+
+func runExport(ctx context.Context, app *App, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	filter := filterFlags(fs)
+	output := fs.String("o", "-", "output file, - for stdout")
+	formatName := fs.String("format", string(orderio.FormatNDJSON), "output format: ndjson, csv or parquet")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := orderio.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if app.api != "" {
+		return app.Client().Export(ctx, format, *filter, out)
+	}
+
+	db, err := app.DB()
+	if err != nil {
+		return err
+	}
+
+	w, err := orderio.NewWriter(out, format)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	err = db.StreamOrders(ctx, *filter, func(order schema.Order) error {
+		count++
+		return w.Write(order)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	app.log.Infof("exported %d orders", count)
+	return nil
+}
+
+func runDelete(ctx context.Context, app *App, args []string) error {
+	return forEachOrder(ctx, app, args, "deleted", func(db orderdb.AdminOrderDB, uid schema.OrderUID) error {
+		return db.DeleteOrder(ctx, uid)
+	})
+}
+
+func runAnonymize(ctx context.Context, app *App, args []string) error {
+	return forEachOrder(ctx, app, args, "anonymized", func(db orderdb.AdminOrderDB, uid schema.OrderUID) error {
+		return db.AnonymizeOrder(ctx, uid)
+	})
+}
+
+func forEachOrder(_ context.Context, app *App, args []string, done string,
+	fn func(orderdb.AdminOrderDB, schema.OrderUID) error) error {
+	uids, err := orderUIDArgs(args)
+	if err != nil {
+		return err
+	}
+
+	db, err := app.DB()
+	if err != nil {
+		return err
+	}
+
+	for _, uid := range uids {
+		if err := fn(db, uid); err != nil {
+			return fmt.Errorf("%s: %w", uid, err)
+		}
+
+		fmt.Printf("%s %s\n", uid, done)
+	}
+
+	return nil
+}
+
+func runSeq(ctx context.Context, app *App, args []string) error {
+	if len(args) == 0 {
+		return errors.New("subcommand is required: show, progress, set or reset")
+	}
+
+	switch args[0] {
+	case "show":
+		var (
+			seq schema.SeqNumber
+			err error
+		)
+
+		if app.api != "" {
+			seq, err = app.Client().SeqNumber(ctx)
+		} else {
+			var db *orderpsql.Postgres
+			if db, err = app.DB(); err == nil {
+				seq, err = db.SeqNumber(ctx)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(seq)
+		return nil
+	case "progress":
+		var (
+			progress orderdb.SeqProgress
+			err      error
+		)
+
+		if app.api != "" {
+			progress, err = app.Client().SeqProgress(ctx)
+		} else {
+			var db *orderpsql.Postgres
+			if db, err = app.DB(); err == nil {
+				progress, err = db.SeqProgress(ctx)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("low-water mark %d, high-water mark %d, missing %d\n",
+			progress.LowWaterMark, progress.HighWaterMark, progress.Missing())
+		for _, gap := range progress.Gaps {
+			fmt.Printf("gap %d-%d\n", gap.From, gap.To)
+		}
+		return nil
+	case "set", "reset":
+		var seq uint64
+		if len(args) > 1 {
+			var err error
+			if seq, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+				return fmt.Errorf("invalid sequence: %w", err)
+			}
+		} else if args[0] == "set" {
+			return errors.New("sequence value is required")
+		}
+
+		// Через API работающий сервис сразу переподписывается,
+		// при прямом доступе к БД новое значение применится после перезапуска
+		if app.api != "" {
+			if err := app.Client().Seek(ctx, schema.SeqNumber(seq)); err != nil {
+				return err
+			}
+
+			fmt.Printf("consumer resumed from %d\n", seq+1)
+			return nil
+		}
+
+		db, err := app.DB()
+		if err != nil {
+			return err
+		}
+
+		if err := db.SetSeqNumber(ctx, schema.SeqNumber(seq)); err != nil {
+			return err
+		}
+
+		fmt.Printf("seq set to %d, restart the service to resume from %d\n", seq, seq+1)
+		return nil
+	}
+
+	return fmt.Errorf("unknown subcommand: %s", args[0])
+}
+
+func runReplay(ctx context.Context, app *App, args []string) error {
+	if app.api == "" {
+		return errors.New("replay requires a running service, set -api")
+	}
+
+	if len(args) > 0 && args[0] == "status" {
+		report, err := app.Client().ShadowReport(ctx)
+		if err != nil {
+			return err
+		}
+
+		return printJSON(report)
+	}
+
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fromSeq := fs.Uint64("from-seq", 0, "replay starting at this sequence")
+	fromTime := fs.String("from-time", "", "replay messages published after this RFC3339 time")
+	shadow := fs.Bool("shadow", false, "only validate messages without writing them")
+	wait := fs.Bool("wait", false, "wait for shadow replay to finish and print the report")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := orderevent.ReplayOptions{
+		FromSeq: schema.SeqNumber(*fromSeq),
+		Shadow:  *shadow,
+	}
+
+	if *fromTime != "" {
+		t, err := time.Parse(time.RFC3339, *fromTime)
+		if err != nil {
+			return fmt.Errorf("invalid from-time: %w", err)
+		}
+
+		opts.FromTime = t
+	}
+
+	if opts.FromSeq == 0 && opts.FromTime.IsZero() {
+		return errors.New("either -from-seq or -from-time is required")
+	}
+
+	if err := app.Client().Replay(ctx, opts); err != nil {
+		return err
+	}
+
+	if !opts.Shadow || !*wait {
+		fmt.Println("replay started")
+		return nil
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		report, err := app.Client().ShadowReport(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !report.Running {
+			return printJSON(report)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func runRepublish(ctx context.Context, app *App, args []string) error {
+	uids, err := orderUIDArgs(args)
+	if err != nil {
+		return err
+	}
+
+	reader, err := app.Reader()
+	if err != nil {
+		return err
+	}
+
+	publisher, err := app.Publisher()
+	if err != nil {
+		return err
+	}
+
+	for _, uid := range uids {
+		order, err := reader.GetOrder(ctx, uid)
+		if err != nil {
+			return fmt.Errorf("%s: %w", uid, err)
+		}
+
+		if err := publisher.PublishOrder(ctx, order); err != nil {
+			return fmt.Errorf("%s: %w", uid, err)
+		}
+
+		fmt.Printf("%s republished\n", uid)
+	}
+
+	return nil
+}
+
+func runVersions(ctx context.Context, app *App, _ []string) error {
+	var versions []orderdb.VersionCount
+	if app.api != "" {
+		resp, err := app.Client().SchemaVersions(ctx)
+		if err != nil {
+			return err
+		}
+		versions = resp.Versions
+	} else {
+		db, err := app.DB()
+		if err != nil {
+			return err
+		}
+
+		if versions, err = db.SchemaVersions(ctx); err != nil {
+			return err
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tORDERS")
+	for _, v := range versions {
+		current := ""
+		if v.Version == schema.CurrentVersion {
+			current = " (current)"
+		}
+		fmt.Fprintf(w, "%d%s\t%d\n", v.Version, current, v.Orders)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"orderservice/internal/orderio"
+	"orderservice/internal/schema"
+	"orderservice/internal/server/client"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// importStats - итоги импорта. read включает строки с ошибками разбора,
+// present - корректные заказы, которые уже были в хранилище.
+type importStats struct {
+	read     int
+	skipped  int
+	invalid  int
+	inserted int
+	present  int
+}
+
+func runImport(ctx context.Context, app *App, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatName := fs.String("format", "", "input format: ndjson or csv, detected from file extension by default")
+	batchSize := fs.Int("batch", 500, "number of orders written in one transaction")
+	checkpoint := fs.String("checkpoint", "", "checkpoint file to resume an interrupted import, <input>.checkpoint by default")
+	errorsPath := fs.String("errors", "", "write per-line errors to this file instead of stderr")
+	refreshURL := fs.String("refresh", "", "service HTTP API address to refresh the cache of after import")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("input file is required, - for stdin")
+	}
+
+	if *batchSize < 1 {
+		return errors.New("batch size must be positive")
+	}
+
+	input := fs.Arg(0)
+	format, err := importFormat(input, *formatName)
+	if err != nil {
+		return err
+	}
+
+	if *checkpoint == "" && input != "-" {
+		*checkpoint = input + ".checkpoint"
+	}
+
+	db, err := app.DB()
+	if err != nil {
+		return err
+	}
+
+	in := os.Stdin
+	if input != "-" {
+		if in, err = os.Open(input); err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+
+	errOut := os.Stderr
+	if *errorsPath != "" {
+		f, err := os.OpenFile(*errorsPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		errOut = f
+	}
+
+	resumeLine, err := readCheckpoint(*checkpoint)
+	if err != nil {
+		return err
+	}
+
+	if resumeLine > 0 {
+		fmt.Fprintf(os.Stderr, "resuming after line %d\n", resumeLine)
+	}
+
+	reader, err := orderio.NewReader(in, format)
+	if err != nil {
+		return err
+	}
+
+	// lastLine - последняя обработанная строка, корректная или нет. После
+	// записи пачки все строки до нее записаны или их ошибки уже выведены,
+	// поэтому продолжение не выводит ошибки повторно.
+	var (
+		stats     importStats
+		batch     = make([]schema.Order, 0, *batchSize)
+		lastLine  int
+		savedLine = resumeLine
+	)
+
+	flush := func() error {
+		if len(batch) > 0 {
+			inserted, err := db.ImportOrders(ctx, batch)
+			if err != nil {
+				return fmt.Errorf("import batch ending at line %d: %w", lastLine, err)
+			}
+
+			stats.inserted += inserted
+			stats.present += len(batch) - inserted
+			batch = batch[:0]
+		}
+
+		if lastLine <= savedLine {
+			return nil
+		}
+		savedLine = lastLine
+		return writeCheckpoint(*checkpoint, lastLine)
+	}
+
+	for {
+		order, line, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var lineErr *orderio.LineError
+		if errors.As(err, &lineErr) {
+			if lineErr.Line <= resumeLine {
+				stats.skipped++
+				continue
+			}
+
+			stats.read++
+			stats.invalid++
+			lastLine = lineErr.Line
+			fmt.Fprintln(errOut, lineErr)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if line <= resumeLine {
+			stats.skipped++
+			continue
+		}
+
+		stats.read++
+		lastLine = line
+		if err := order.Validate(); err != nil {
+			stats.invalid++
+			fmt.Fprintf(errOut, "line %d: %s: %v\n", line, order.OrderUID, err)
+			continue
+		}
+
+		batch = append(batch, order)
+		if len(batch) == *batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("read %d, imported %d, already present %d, invalid %d, skipped as already processed %d\n",
+		stats.read, stats.inserted, stats.present, stats.invalid, stats.skipped)
+
+	if *checkpoint != "" {
+		if err := os.Remove(*checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if *refreshURL != "" {
+		if err := client.New(client.Config{URL: *refreshURL, Token: app.token}).RefreshCache(ctx); err != nil {
+			return fmt.Errorf("refresh cache: %w", err)
+		}
+
+		fmt.Println("service cache refreshed")
+	}
+
+	return nil
+}
+
+func importFormat(input, name string) (orderio.Format, error) {
+	if name != "" {
+		return orderio.ParseFormat(name)
+	}
+
+	switch strings.ToLower(filepath.Ext(input)) {
+	case ".csv":
+		return orderio.FormatCSV, nil
+	default:
+		return orderio.FormatNDJSON, nil
+	}
+}
+
+func readCheckpoint(path string) (int, error) {
+	if path == "" {
+		return 0, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	line, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+
+	return line, nil
+}
+
+// writeCheckpoint атомарно сохраняет номер последней записанной строки.
+func writeCheckpoint(path string, line int) error {
+	if path == "" {
+		return nil
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(line)+"\n"), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderdb/orderpsql"
+	"orderservice/internal/orderevent/ordernats"
+	"orderservice/internal/provider/natsprovider"
+	"orderservice/internal/provider/pgxprovider"
+	"orderservice/internal/schema"
+	"orderservice/internal/server/client"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+)
+
+var errDirectOnly = errors.New("command requires direct database access, run without -api")
+
+// OrderReader is the part of the order store available both directly
+// and over the HTTP API.
+type OrderReader interface {
+	GetOrder(ctx context.Context, orderUID schema.OrderUID) (schema.Order, error)
+	ListOrders(ctx context.Context, filter orderdb.Filter) ([]schema.Order, error)
+}
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, app *App, args []string) error
+}
+
+var commands = map[string]command{
+	"get":       {"get <order_uid>", runGet},
+	"list":      {"list [filters] [-json]", runList},
+	"export":    {"export [filters] [-format ndjson|csv|parquet] [-o file]", runExport},
+	"import":    {"import [-format ndjson|csv] [-batch N] [-checkpoint file] [-errors file] [-refresh URL] <file>", runImport},
+	"delete":    {"delete <order_uid>", runDelete},
+	"anonymize": {"anonymize <order_uid>", runAnonymize},
+	"seq":       {"seq show | seq progress | seq set <value> | seq reset", runSeq},
+	"replay":    {"replay [-from-seq N | -from-time T] [-shadow [-wait]] | replay status", runReplay},
+	"republish": {"republish <order_uid>...", runRepublish},
+	"versions":  {"versions", runVersions},
+}
+
+type App struct {
+	log *logrus.Logger
+	api string
+	// token - токен маршрутов администрирования сервиса
+	token string
+
+	reader OrderReader
+	client *client.Client
+	db     *orderpsql.Postgres
+
+	closers []func()
+}
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	log := logrus.New()
+	log.SetOutput(os.Stderr)
+	log.SetLevel(logrus.WarnLevel)
+
+	flag.Usage = usage
+	api := flag.String("api", os.Getenv("ORDERS_API"), "service HTTP API address, the database is used directly when empty")
+	verbose := flag.Bool("v", false, "verbose logging")
+	flag.Parse()
+
+	if *verbose {
+		log.SetLevel(logrus.InfoLevel)
+	}
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	// .env не обязателен, переменные могут быть заданы окружением
+	_ = godotenv.Load()
+
+	app := &App{log: log, api: *api, token: os.Getenv("ORDERS_ADMIN_TOKEN")}
+	defer app.Close()
+
+	if err := cmd.run(ctx, app, flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", flag.Arg(0), err)
+		app.Close()
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: ordersctl [-api URL] [-v] <command> [args]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nflags:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nenvironment:\n  ORDERS_ADMIN_TOKEN\n    \ttoken for the service admin API (server.admin_token)\n")
+}
+
+// Reader returns the HTTP API client when -api is set and the database otherwise.
+func (a *App) Reader() (OrderReader, error) {
+	if a.reader != nil {
+		return a.reader, nil
+	}
+
+	if a.api != "" {
+		a.reader = a.Client()
+		return a.reader, nil
+	}
+
+	db, err := a.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	a.reader = db
+	return a.reader, nil
+}
+
+func (a *App) Client() *client.Client {
+	if a.client == nil {
+		a.client = client.New(client.Config{URL: a.api, Token: a.token})
+	}
+
+	return a.client
+}
+
+func (a *App) DB() (*orderpsql.Postgres, error) {
+	if a.api != "" {
+		return nil, errDirectOnly
+	}
+
+	if a.db != nil {
+		return a.db, nil
+	}
+
+	pgxp, err := pgxprovider.New(pgxprovider.Config{
+		URL: os.Getenv("POSTGRES_URL"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+	a.closers = append(a.closers, func() { pgxp.Close(context.Background()) })
+
+	a.db = orderpsql.New(
+		orderpsql.Config{
+			QueryTimeout: 10 * time.Second,
+		},
+		orderpsql.Dependencies{
+			Log: a.log,
+			PGX: pgxp,
+		})
+
+	return a.db, nil
+}
+
+func (a *App) Publisher() (*ordernats.NatsOrderStore, error) {
+	np, err := natsprovider.New(natsprovider.Config{
+		StanClusterID: os.Getenv("STAN_CLUSTER_ID"),
+		ClientID:      fmt.Sprintf("ordersctl-%d", os.Getpid()),
+		URL:           os.Getenv("NATS_URL"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect to nats: %w", err)
+	}
+	a.closers = append(a.closers, func() { np.Close() })
+
+	return ordernats.New(
+		ordernats.Config{
+			ChannelName: os.Getenv("STAN_CHANNEL_NAME"),
+		},
+		ordernats.Dependencies{
+			Log:        a.log,
+			NSProvider: np,
+		}), nil
+}
+
+func (a *App) Close() {
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		a.closers[i]()
+	}
+	a.closers = nil
+}
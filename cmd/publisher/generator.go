@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"orderservice/internal/schema"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	currencies       = []string{"USD", "EUR", "RUB", "KZT", "BYN"}
+	locales          = []string{"en", "ru", "kk", "be"}
+	deliveryServices = []string{"meest", "cdek", "boxberry", "dpd", "wb"}
+	paymentProviders = []string{"wbpay", "applepay", "sbp"}
+	banks            = []string{"alpha", "sber", "tinkoff", "vtb"}
+	entries          = []string{"WBIL", "WBRU", "WBKZ"}
+
+	firstNames = []string{"Ivan", "Anna", "Petr", "Maria", "Test", "Olga", "Sergey"}
+	lastNames  = []string{"Ivanov", "Petrova", "Sidorov", "Testov", "Smirnova"}
+	cities     = []struct{ City, Region string }{
+		{"Moscow", "Moscow"},
+		{"Kazan", "Tatarstan"},
+		{"Almaty", "Almaty"},
+		{"Minsk", "Minsk"},
+		{"Kiryat Mozkin", "Kraiot"},
+	}
+	streets = []string{"Ploshad Mira", "Lenina", "Pushkina", "Sadovaya"}
+
+	products = []struct{ Name, Brand string }{
+		{"Mascaras", "Vivienne Sabo"},
+		{"Lipstick", "Maybelline"},
+		{"Sneakers", "Nike"},
+		{"T-Shirt", "Adidas"},
+		{"Headphones", "Sony"},
+		{"Backpack", "Xiaomi"},
+		{"Notebook", "Moleskine"},
+	}
+)
+
+const (
+	maxItems       = 5
+	maxItemPrice   = 5000
+	maxDeliveryFee = 1500
+)
+
+// Generator produces random but internally consistent orders.
+// Orders generated from the same seed are identical.
+type Generator struct {
+	rnd *rand.Rand
+}
+
+func NewGenerator(seed int64) *Generator {
+	return &Generator{
+		rnd: rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (g *Generator) Order() schema.Order {
+	uid, err := uuid.NewRandomFromReader(g.rnd)
+	if err != nil {
+		// Чтение из math/rand не возвращает ошибок
+		panic(err)
+	}
+
+	track := g.trackNumber()
+	entry := pick(g.rnd, entries)
+
+	items := make(schema.Items, 1+g.rnd.Intn(maxItems))
+	goodsTotal := 0
+	for i := range items {
+		items[i] = g.item(track)
+		goodsTotal += items[i].TotalPrice
+	}
+
+	deliveryCost := g.rnd.Intn(maxDeliveryFee + 1)
+	customFee := 0
+	if g.rnd.Intn(4) == 0 {
+		customFee = g.rnd.Intn(goodsTotal/10 + 1)
+	}
+
+	created := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC).
+		Add(time.Duration(g.rnd.Int63n(int64(3 * 365 * 24 * time.Hour))))
+	location := cities[g.rnd.Intn(len(cities))]
+	name := pick(g.rnd, firstNames) + " " + pick(g.rnd, lastNames)
+
+	return schema.Order{
+		OrderUID:    schema.OrderUID(uid.String()),
+		TrackNumber: track,
+		Entry:       entry,
+		Delivery: schema.Delivery{
+			Name:   name,
+			Phone:  fmt.Sprintf("+7%010d", g.rnd.Int63n(1e10)),
+			Zip:    100000 + g.rnd.Intn(900000),
+			City:   location.City,
+			Adress: fmt.Sprintf("%s %d", pick(g.rnd, streets), 1+g.rnd.Intn(200)),
+			Region: location.Region,
+			Email:  strings.ToLower(strings.ReplaceAll(name, " ", ".")) + "@example.com",
+		},
+		Payment: schema.Payment{
+			Transaction:   strings.ReplaceAll(uid.String(), "-", ""),
+			Currency:      pick(g.rnd, currencies),
+			Provider:      pick(g.rnd, paymentProviders),
+			Amount:        goodsTotal + deliveryCost + customFee,
+			PaymentDT:     schema.NewTimestamp(created.Add(time.Duration(g.rnd.Intn(3600)) * time.Second)),
+			Bank:          pick(g.rnd, banks),
+			DeliveryConst: deliveryCost,
+			GoodsTotal:    goodsTotal,
+			CustomFee:     customFee,
+		},
+		Items:           items,
+		Locale:          pick(g.rnd, locales),
+		CustomerID:      fmt.Sprintf("customer%d", g.rnd.Intn(10000)),
+		DeliveryService: pick(g.rnd, deliveryServices),
+		Shardkey:        g.rnd.Intn(10),
+		SmID:            g.rnd.Intn(100),
+		DateCreated:     schema.NewTimestamp(created),
+		OofShard:        g.rnd.Intn(10),
+	}
+}
+
+func (g *Generator) item(track string) schema.Item {
+	product := products[g.rnd.Intn(len(products))]
+	price := 1 + g.rnd.Intn(maxItemPrice)
+	sale := g.rnd.Intn(8) * 10
+
+	return schema.Item{
+		ChrtID:      g.rnd.Intn(1e7),
+		TrackNumber: track,
+		Price:       price,
+		RID:         fmt.Sprintf("%x", g.rnd.Uint64()),
+		Name:        product.Name,
+		Sale:        sale,
+		Size:        g.rnd.Intn(6),
+		TotalPrice:  price * (100 - sale) / 100,
+		NmID:        g.rnd.Intn(1e7),
+		Brand:       product.Brand,
+		Status:      200 + g.rnd.Intn(3),
+	}
+}
+
+func (g *Generator) trackNumber() string {
+	const letters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	b := make([]byte, 10)
+	for i := range b {
+		b[i] = letters[g.rnd.Intn(len(letters))]
+	}
+
+	return "WB" + string(b)
+}
+
+func pick(rnd *rand.Rand, values []string) string {
+	return values[rnd.Intn(len(values))]
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratorDeterministic(t *testing.T) {
+	a, b := NewGenerator(42), NewGenerator(42)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, a.Order(), b.Order())
+	}
+}
+
+func TestGeneratorTotals(t *testing.T) {
+	gen := NewGenerator(1)
+	for i := 0; i < 100; i++ {
+		order := gen.Order()
+		require.NotEmpty(t, order.Items)
+
+		goodsTotal := 0
+		for _, item := range order.Items {
+			require.Equal(t, order.TrackNumber, item.TrackNumber)
+			require.Equal(t, item.Price*(100-item.Sale)/100, item.TotalPrice)
+			goodsTotal += item.TotalPrice
+		}
+
+		require.Equal(t, goodsTotal, order.Payment.GoodsTotal)
+		require.Equal(t, order.Payment.GoodsTotal+order.Payment.DeliveryConst+order.Payment.CustomFee,
+			order.Payment.Amount)
+	}
+}
@@ -2,35 +2,118 @@ package main
 
 import (
 	"context"
-	"orderservice/internal/orderevent"
+	"errors"
+	"flag"
+	"orderservice/internal/orderevent/ordercodec"
 	"orderservice/internal/orderevent/ordernats"
 	"orderservice/internal/provider/natsprovider"
 	"orderservice/internal/schema"
 	"os"
 	"os/signal"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
 )
 
+// maxRate - сообщений в секунду при интервале в одну наносекунду
+const maxRate = float64(time.Second)
+
+type options struct {
+	count       int
+	rate        float64
+	duration    time.Duration
+	concurrency int
+	seed        int64
+
+	input      string
+	format     string
+	rewriteUID bool
+	raw        bool
+	codec      string
+}
+
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(),
 		syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 	log := logrus.New()
 
-	countToPublish := 1
-	if len(os.Args) > 1 {
-		v, err := strconv.ParseInt(os.Args[1], 10, 32)
+	opts := options{}
+	flag.IntVar(&opts.count, "count", 1, "number of orders to publish, 0 means no limit")
+	flag.Float64Var(&opts.rate, "rate", 0, "orders per second, 0 means as fast as possible")
+	flag.DurationVar(&opts.duration, "duration", 0, "stop publishing after this duration")
+	flag.IntVar(&opts.concurrency, "concurrency", 1, "number of concurrent publishers")
+	flag.Int64Var(&opts.seed, "seed", 0, "random seed, 0 means seed from current time")
+	flag.StringVar(&opts.input, "input", "", "publish orders from a JSON/NDJSON file, a directory of fixtures or - for stdin")
+	flag.StringVar(&opts.format, "format", "", "input format: json or ndjson, detected from file extension by default")
+	flag.BoolVar(&opts.rewriteUID, "rewrite-uid", false, "replace order_uid of input orders with a new one")
+	flag.BoolVar(&opts.raw, "raw", false, "publish input payloads byte for byte without decoding")
+	flag.StringVar(&opts.codec, "codec", "json", "wire format of published orders: json, protobuf or msgpack")
+	flag.Parse()
+
+	// Количество можно передать позиционным аргументом, как раньше
+	if flag.NArg() > 0 {
+		v, err := strconv.ParseInt(flag.Arg(0), 10, 32)
 		if err != nil {
 			log.Errorf("failed to parse cmd arg: %v", err)
 			return
 		}
 
-		countToPublish = int(v)
+		opts.count = int(v)
+	}
+
+	if opts.input != "" && !isFlagSet("count") && flag.NArg() == 0 {
+		// Из файлов по умолчанию публикуем всё содержимое
+		opts.count = 0
+	}
+
+	if opts.raw && opts.rewriteUID {
+		log.Error("rewrite-uid can not be used with raw mode")
+		return
+	}
+
+	// Интервал между сообщениями не может быть меньше наносекунды
+	if !(opts.rate >= 0 && opts.rate <= maxRate) {
+		log.Errorf("rate must be between 0 and %g", maxRate)
+		return
+	}
+
+	if opts.input == "" && opts.count == 0 && opts.duration == 0 {
+		log.Error("either count or duration must be set")
+		return
+	}
+
+	contentType, err := ordercodec.ParseContentType(opts.codec)
+	if err != nil {
+		log.Errorf("invalid codec: %v", err)
+		return
+	}
+
+	codec, err := ordercodec.NewMux(ordercodec.Config{ContentType: contentType}, ordercodec.Dependencies{})
+	if err != nil {
+		log.Errorf("failed to create codec: %v", err)
+		return
+	}
+
+	if opts.concurrency < 1 {
+		opts.concurrency = 1
+	}
+
+	var source Source
+	if opts.input != "" {
+		source = FileSource(opts.input, opts.format, opts.raw)
+	} else {
+		if opts.seed == 0 {
+			opts.seed = time.Now().UnixNano()
+		}
+		log.Infof("using seed %d", opts.seed)
+		source = GeneratorSource(NewGenerator(opts.seed))
 	}
 
 	if err := godotenv.Load(); err != nil {
@@ -55,74 +138,132 @@ func main() {
 		ordernats.Dependencies{
 			Log:        log,
 			NSProvider: np,
+			Codec:      codec,
 		})
-	if err != nil {
-		log.Errorf("failed to created ordernats: %v", err)
-		return
+
+	if opts.duration != 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
 	}
 
-	if err := Publish(ctx, log, ordernats, countToPublish); err != nil {
+	published, err := Publish(ctx, log, ordernats, source, opts)
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
 		log.Errorf("failed to publish: %v", err)
 	}
+
+	log.Infof("published %d orders", published)
 }
 
-func Publish(ctx context.Context, log *logrus.Logger, p orderevent.OrderPublisher, count int) error {
-	for i := 0; i < count; i++ {
-		uid := uuid.NewString()
-		order := schema.Order{
-			OrderUID:    schema.OrderUID(uid),
-			TrackNumber: "WBILMTESTTRACK",
-			Entry:       "WBIL",
-			Delivery: schema.Delivery{
-				Name:   "Test Testov",
-				Phone:  "+9720000000",
-				Zip:    2639809,
-				City:   "Kiryat Mozkin",
-				Adress: "Ploshad Mira 15",
-				Region: "Kraiot",
-				Email:  "test@gmail.com",
-			},
-			Payment: schema.Payment{
-				Transaction:   "b563feb7b2b84b6test",
-				RequestID:     "",
-				Currency:      "USD",
-				Provider:      "wbpay",
-				Amount:        1817,
-				PaymentDT:     1637907727,
-				Bank:          "alpha",
-				DeliveryConst: 1500,
-				GoodsTotal:    317,
-			},
-			Items: schema.Items{
-				ChrtID:      9934930,
-				TrackNumber: "WBILMTESTTRACK",
-				Price:       453,
-				RID:         "ab4219087a764ae0btest",
-				Name:        "Mascaras",
-				Sale:        30,
-				Size:        0,
-				TotalPrice:  317,
-				NmID:        2389212,
-				Brand:       "Vivienne Sabo",
-				Status:      202,
-			},
-			Locale:          "en",
-			InternalSign:    "",
-			CustomerID:      "test",
-			DeliveryService: "meest",
-			Shardkey:        9,
-			SmID:            99,
-			DateCreated:     "2021-11-26T06:22:19Z",
-			OofShard:        1,
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
 		}
+	})
+
+	return set
+}
+
+type Publisher interface {
+	PublishOrder(context.Context, schema.Order) error
+	PublishRaw(context.Context, []byte) error
+}
 
-		if err := p.PublishOrder(ctx, order); err != nil {
-			log.Errorf("failed to publish: %s(%d)", order.OrderUID, i)
-			return err
+// Publish читает сообщения из source в одной горутине, чтобы порядок
+// зависел только от источника, и отправляет их opts.concurrency
+// публикаторами с ограничением opts.rate сообщений в секунду.
+func Publish(ctx context.Context, log *logrus.Logger, p Publisher,
+	source Source, opts options) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var sourceErr error
+	sourceDone := make(chan struct{})
+	messages := make(chan Message, opts.concurrency)
+	go func() {
+		defer close(sourceDone)
+		defer close(messages)
+
+		var tick <-chan time.Time
+		if opts.rate > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.rate))
+			defer ticker.Stop()
+			tick = ticker.C
 		}
 
-		log.Infof("order published: %s", order.OrderUID)
+		produced := 0
+		sourceErr = source(func(msg Message) bool {
+			if opts.count != 0 && produced >= opts.count {
+				return false
+			}
+			produced++
+
+			if tick != nil {
+				select {
+				case <-ctx.Done():
+					return false
+				case <-tick:
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return false
+			case messages <- msg:
+				return true
+			}
+		})
+	}()
+
+	var (
+		published atomic.Int64
+		wg        sync.WaitGroup
+		errOnce   sync.Once
+		firstErr  error
+	)
+
+	for i := 0; i < opts.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range messages {
+				if err := publish(ctx, p, msg, opts); err != nil {
+					log.Errorf("failed to publish %s: %v", msg.Name, err)
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+
+				published.Add(1)
+				log.Debugf("order published: %s", msg.Name)
+			}
+		}()
+	}
+
+	wg.Wait()
+	<-sourceDone
+	if firstErr == nil {
+		firstErr = sourceErr
+	}
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+
+	return published.Load(), firstErr
+}
+
+func publish(ctx context.Context, p Publisher, msg Message, opts options) error {
+	if msg.Raw != nil {
+		return p.PublishRaw(ctx, msg.Raw)
+	}
+
+	order := msg.Order
+	if opts.rewriteUID {
+		order.OrderUID = schema.OrderUID(uuid.NewString())
 	}
 
-	return nil
+	return p.PublishOrder(ctx, order)
 }
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"orderservice/internal/schema"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+
+	maxLineSize = 16 * 1024 * 1024
+)
+
+// Message is a single payload to publish. Raw is set in raw mode and
+// is published as is, otherwise Order is encoded by the publisher.
+type Message struct {
+	Name  string
+	Order schema.Order
+	Raw   []byte
+}
+
+// Source calls yield for every message until it returns false.
+type Source func(yield func(Message) bool) error
+
+func GeneratorSource(gen *Generator) Source {
+	return func(yield func(Message) bool) error {
+		for {
+			order := gen.Order()
+			if !yield(Message{Name: string(order.OrderUID), Order: order}) {
+				return nil
+			}
+		}
+	}
+}
+
+// FileSource reads messages from a JSON or NDJSON file, from every
+// fixture in a directory or from stdin when path is "-".
+func FileSource(path, format string, raw bool) Source {
+	return func(yield func(Message) bool) error {
+		if path == "-" {
+			if format == "" {
+				format = formatNDJSON
+			}
+
+			_, err := readStream(os.Stdin, "stdin", format, raw, yield)
+			return err
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			_, err := readFile(path, format, raw, yield)
+			return err
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || detectFormat(entry.Name()) == "" {
+				continue
+			}
+
+			more, err := readFile(filepath.Join(path, entry.Name()), format, raw, yield)
+			if err != nil {
+				return err
+			}
+
+			if !more {
+				return nil
+			}
+		}
+
+		return nil
+	}
+}
+
+func detectFormat(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return formatJSON
+	case ".ndjson", ".jsonl":
+		return formatNDJSON
+	}
+
+	return ""
+}
+
+func readFile(path, format string, raw bool, yield func(Message) bool) (bool, error) {
+	if format == "" {
+		format = detectFormat(path)
+	}
+
+	if format == "" {
+		return false, fmt.Errorf("%s: unknown input format", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	return readStream(f, path, format, raw, yield)
+}
+
+func readStream(r io.Reader, name, format string, raw bool, yield func(Message) bool) (bool, error) {
+	switch format {
+	case formatJSON:
+		return readJSON(r, name, raw, yield)
+	case formatNDJSON:
+		return readNDJSON(r, name, raw, yield)
+	}
+
+	return false, fmt.Errorf("unsupported input format: %s", format)
+}
+
+func readJSON(r io.Reader, name string, raw bool, yield func(Message) bool) (bool, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return false, err
+	}
+
+	if raw {
+		return yield(Message{Name: name, Raw: data}), nil
+	}
+
+	var orders []schema.Order
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &orders)
+	} else {
+		orders = make([]schema.Order, 1)
+		err = json.Unmarshal(trimmed, &orders[0])
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+
+	for i, order := range orders {
+		if !yield(Message{Name: fmt.Sprintf("%s[%d]", name, i), Order: order}) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func readNDJSON(r io.Reader, name string, raw bool, yield func(Message) bool) (bool, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		msg := Message{Name: fmt.Sprintf("%s:%d", name, line)}
+		if raw {
+			msg.Raw = bytes.Clone(data)
+		} else if err := json.Unmarshal(data, &msg.Order); err != nil {
+			return false, fmt.Errorf("%s: %w", msg.Name, err)
+		}
+
+		if !yield(msg) {
+			return false, nil
+		}
+	}
+
+	return true, scanner.Err()
+}
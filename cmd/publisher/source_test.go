@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func collect(t *testing.T, source Source) []Message {
+	var res []Message
+	err := source(func(msg Message) bool {
+		res = append(res, msg)
+		return true
+	})
+	require.NoError(t, err)
+	return res
+}
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.json"),
+		[]byte(`[{"order_uid":"1"},{"order_uid":"2"}]`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.ndjson"),
+		[]byte("{\"order_uid\":\"3\"}\n\n{not json}\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("skip"), 0o600))
+
+	t.Run("raw", func(t *testing.T) {
+		res := collect(t, FileSource(dir, "", true))
+		require.Len(t, res, 3)
+		require.Equal(t, `[{"order_uid":"1"},{"order_uid":"2"}]`, string(res[0].Raw))
+		require.Equal(t, `{not json}`, string(res[2].Raw))
+	})
+
+	t.Run("decoded", func(t *testing.T) {
+		var res []Message
+		err := FileSource(dir, "", false)(func(msg Message) bool {
+			res = append(res, msg)
+			return true
+		})
+		require.Error(t, err)
+		require.Len(t, res, 3)
+		require.EqualValues(t, "1", res[0].Order.OrderUID)
+		require.EqualValues(t, "3", res[2].Order.OrderUID)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		calls := 0
+		err := FileSource(dir, "", true)(func(msg Message) bool {
+			calls++
+			return false
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, calls)
+	})
+}
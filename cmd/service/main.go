@@ -3,16 +3,26 @@ package main
 import (
 	"context"
 	"errors"
+	"io/fs"
+	"orderservice/internal/analytics"
+	"orderservice/internal/config"
+	"orderservice/internal/currency"
+	"orderservice/internal/leader"
+	"orderservice/internal/orderdb"
 	"orderservice/internal/orderdb/ordercache"
-	postgres "orderservice/internal/orderdb/orderpsql"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/orderevent/ordercodec"
 	"orderservice/internal/orderevent/ordernats"
+	"orderservice/internal/orderevent/orderpeers"
+	"orderservice/internal/outbox"
 	"orderservice/internal/provider/natsprovider"
-	"orderservice/internal/provider/pgxprovider"
+	"orderservice/internal/schema"
 	"orderservice/internal/server"
+	"orderservice/internal/webhook"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
-	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
@@ -24,59 +34,141 @@ func main() {
 	defer cancel()
 
 	log := logrus.New()
-	if err := godotenv.Load(); err != nil {
+	// .env необязателен, переменные окружения дополняют файл конфигурации
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		log.Errorf("error loading .env file: %v", err)
 		return
 	}
 
-	pgxp, err := pgxprovider.New(pgxprovider.Config{
-		URL: os.Getenv("POSTGRES_URL"),
-	})
+	loader, err := config.NewLoader(os.Args[0], os.Args[1:])
 	if err != nil {
-		log.Errorf("failed postges: %v", err)
+		log.Errorf("invalid arguments: %v", err)
 		return
 	}
-	defer pgxp.Close(context.Background())
 
-	db := postgres.New(
-		postgres.Config{
-			QueryTimeout: 1 * time.Second,
-		},
-		postgres.Dependencies{
-			Log: log,
-			PGX: pgxp,
-		})
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Errorf("failed to load config: %v", err)
+		return
+	}
+	setLogLevel(log, cfg.Log)
 
-	cache := ordercache.New(
-		ordercache.Config{},
-		ordercache.Dependencies{
-			Persistent: db,
-		})
-	if err = cache.Restore(ctx); err != nil {
-		log.Errorf("cache restore error: %v", err)
+	// Значения уже проверены в config.Validate
+	policy, _ := schema.ParseFieldPolicy(cfg.Schema.FieldPolicy)
+	registry := schema.NewRegistry(policy)
+	contentType, _ := ordercodec.ParseContentType(cfg.NATS.ContentType)
+
+	// Тип содержимого важен только для публикации, принимаются все форматы
+	codec, err := ordercodec.NewMux(ordercodec.Config{ContentType: contentType},
+		ordercodec.Dependencies{Registry: registry})
+	if err != nil {
+		log.Errorf("failed to create codec: %v", err)
 		return
 	}
-	log.Info("service restored")
+
+	// Политика полей относится к входящим сообщениям: сохраненные заказы
+	// читаются всегда, даже если в них есть поля, неизвестные этой версии
+	store, err := openStorage(cfg, log, schema.NewRegistry(schema.Lenient))
+	if err != nil {
+		log.Errorf("failed to open %s storage: %v", cfg.Storage.Backend, err)
+		return
+	}
+	defer store.close()
+
+	live := analytics.NewLive(analytics.LiveConfig{
+		Window: cfg.Analytics.LiveWindow.Std(),
+	})
+
+	// Без файла курсов отчеты строятся только в валютах платежей
+	var converter *currency.Converter
+	if path := cfg.Analytics.RatesFile; path != "" {
+		rates, err := currency.LoadStaticRates(path)
+		if err != nil {
+			log.Errorf("failed to load exchange rates: %v", err)
+			return
+		}
+		converter = currency.NewConverter(rates)
+	}
 
 	np, err := natsprovider.New(natsprovider.Config{
-		StanClusterID: os.Getenv("STAN_CLUSTER_ID"),
-		ClientID:      "user1",
-		URL:           os.Getenv("NATS_URL"),
+		StanClusterID:  cfg.NATS.ClusterID,
+		ClientID:       cfg.NATS.ClientID,
+		URL:            cfg.NATS.URL,
+		ConnectTimeout: cfg.NATS.ConnectTimeout.Std(),
 	})
 	if err != nil {
 		log.Errorf("failed to create nats provider: %v", err)
 		return
 	}
+	log.Infof("connected to nats as %s", np.ClientID())
+
+	// Реплики сообщают друг другу о своих изменениях, чтобы кеш каждой
+	// содержал все заказы, а не только обработанные ей
+	var (
+		peers       orderdb.ChangeNotifier
+		broadcaster *orderpeers.Broadcaster
+	)
+	// Файл bolt доступен только одной реплике, синхронизировать некого
+	if cfg.NATS.PeerSubject != "" && store.pgx != nil {
+		broadcaster = orderpeers.New(
+			orderpeers.Config{
+				Subject:   cfg.NATS.PeerSubject,
+				ReplicaID: np.ClientID(),
+			},
+			orderpeers.Dependencies{
+				Log:  log,
+				Conn: np.NatsConn(),
+			})
+		peers = broadcaster
+	}
+
+	cache := ordercache.New(
+		ordercache.Config{
+			SearchIndex: cfg.Cache.SearchIndex,
+		},
+		ordercache.Dependencies{
+			Persistent: store.orders,
+			Observers:  []orderdb.OrderObserver{live},
+			Peers:      peers,
+		})
+
+	// Подписка до восстановления, чтобы не пропустить изменения других
+	// реплик, сделанные во время чтения хранилища
+	if broadcaster != nil {
+		if err := broadcaster.Subscribe(cache); err != nil {
+			log.Errorf("failed to subscribe on peer changes: %v", err)
+			return
+		}
+		defer broadcaster.Unsubscribe()
+	}
+
+	if err = cache.Restore(ctx); err != nil {
+		log.Errorf("cache restore error: %v", err)
+		return
+	}
+	log.Info("service restored")
 
 	eventConsumer := ordernats.New(
 		ordernats.Config{
-			ChannelName: os.Getenv("STAN_CHANNEL_NAME"),
-			QueueDepth:  1024,
+			ChannelName:   cfg.NATS.Channel,
+			QueueDepth:    cfg.NATS.QueueDepth,
+			QueueGroup:    cfg.NATS.QueueGroup,
+			DurableName:   cfg.NATS.DurableName,
+			EventsChannel: cfg.Outbox.Channel,
+			Consumer: orderevent.ConsumerConfig{
+				Workers:          cfg.NATS.Consumer.Workers,
+				MinInflight:      cfg.NATS.Consumer.MinInflight,
+				TargetLatency:    cfg.NATS.Consumer.TargetLatency.Std(),
+				FailureThreshold: cfg.NATS.Consumer.FailureThreshold,
+				ProbeInterval:    cfg.NATS.Consumer.ProbeInterval.Std(),
+				RejectInvalid:    cfg.Outbox.Enabled,
+			},
 		},
 		ordernats.Dependencies{
 			Log:        log,
 			NSProvider: np,
 			Store:      cache,
+			Codec:      codec,
 		})
 	if err := eventConsumer.SubscribeOnOrder(ctx); err != nil {
 		log.Errorf("failed to subscribe on order: %v", err)
@@ -84,14 +176,160 @@ func main() {
 	}
 	defer eventConsumer.Unsubscribe()
 
+	// Без webhooks маршруты управления подписками не регистрируются
+	var webhooks webhook.Store
+	if cfg.Webhooks.Enabled {
+		webhooks = store.psql
+	}
+
 	server := server.NewServer(
-		server.Config{Address: os.Getenv("SERVER_ADDR")},
+		server.Config{
+			Address:         cfg.Server.Address,
+			ShutdownTimeout: cfg.Server.ShutdownTimeout.Std(),
+			RateLimit:       serverRateLimit(cfg.Server.RateLimit),
+			AdminToken:      cfg.Server.AdminToken,
+		},
 		server.Dependencies{
-			Log: log,
-			DB:  cache,
+			Log:      log,
+			DB:       cache,
+			Consumer: eventConsumer,
+			Exporter: store.exporter,
+			Webhooks: webhooks,
+			Analytics: analytics.NewService(
+				analytics.Config{
+					DefaultRange: cfg.Analytics.DefaultRange.Std(),
+				},
+				analytics.Dependencies{
+					Source:    store.aggregates,
+					Live:      live,
+					Converter: converter,
+				}),
+		})
+
+	// Задачи, которые должна выполнять только одна реплика
+	var leaderTasks []func(context.Context, leader.Lease)
+	if cfg.Outbox.Enabled {
+		relay := newRelay(cfg.Outbox, log, store, outbox.TargetEvents, eventConsumer)
+		leaderTasks = append(leaderTasks, func(ctx context.Context, lease leader.Lease) {
+			relay.Run(ctx, lease)
 		})
+	}
+
+	if cfg.Webhooks.Enabled {
+		// Доставки ставятся в очередь из собственной очереди outbox. Заказ
+		// читается из постоянного хранилища: кэш может не содержать
+		// вытесненный или записанный другой репликой заказ.
+		relay := newRelay(cfg.Outbox, log, store, outbox.TargetWebhooks,
+			webhook.NewDispatcher(webhook.DispatcherDependencies{
+				Store:  store.psql,
+				Orders: store.orders,
+			}))
+		leaderTasks = append(leaderTasks, func(ctx context.Context, lease leader.Lease) {
+			relay.Run(ctx, lease)
+		})
+
+		worker := webhook.NewWorker(
+			webhook.WorkerConfig{
+				PollInterval:     cfg.Webhooks.PollInterval.Std(),
+				BatchSize:        cfg.Webhooks.BatchSize,
+				Timeout:          cfg.Webhooks.Timeout.Std(),
+				MaxAttempts:      cfg.Webhooks.MaxAttempts,
+				Backoff:          cfg.Webhooks.Backoff.Std(),
+				MaxBackoff:       cfg.Webhooks.MaxBackoff.Std(),
+				FailureThreshold: cfg.Webhooks.FailureThreshold,
+				Cooldown:         cfg.Webhooks.Cooldown.Std(),
+			},
+			webhook.WorkerDependencies{
+				Log:   log,
+				Store: store.psql,
+			})
+		leaderTasks = append(leaderTasks, func(ctx context.Context, lease leader.Lease) {
+			worker.Run(ctx, lease)
+		})
+	}
+
+	// Со встроенным хранилищем реплика одна и всегда лидер
+	var lock leader.Lock = leader.NewLocalLock()
+	if store.pgx != nil {
+		lock = leader.NewPostgresLock(
+			leader.PostgresConfig{
+				Name:   cfg.Leader.Name,
+				Holder: np.ClientID(),
+			},
+			leader.PostgresDependencies{PGX: store.pgx})
+	}
+
+	elector := leader.New(
+		leader.Config{
+			RenewInterval: cfg.Leader.RenewInterval.Std(),
+			RetryInterval: cfg.Leader.RetryInterval.Std(),
+		},
+		leader.Dependencies{
+			Log:  log,
+			Lock: lock,
+		})
+
+	// Лидерство отдается до закрытия хранилища
+	elected := make(chan struct{})
+	defer func() { <-elected }()
+	go func() {
+		defer close(elected)
+		elector.Run(ctx, leader.Callbacks{
+			OnStarted: func(ctx context.Context, lease leader.Lease) {
+				var wg sync.WaitGroup
+				for _, task := range leaderTasks {
+					wg.Add(1)
+					go func(task func(context.Context, leader.Lease)) {
+						defer wg.Done()
+						task(ctx, lease)
+					}(task)
+				}
+				wg.Wait()
+			},
+			OnStopped: func() {
+				log.Info("leader tasks stopped")
+			},
+		})
+	}()
+
+	reloader := config.NewReloader(cfg, config.ReloaderDependencies{
+		Log:    log,
+		Loader: loader,
+		Apply: func(r config.Reloadable) {
+			setLogLevel(log, r.Log)
+			server.SetRateLimit(serverRateLimit(r.RateLimit))
+		},
+	})
+	go reloader.Run(ctx)
 
 	if err = server.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
 		log.Errorf("failed to run server: %v", err)
 	}
 }
+
+// newRelay создает Relay событий outbox для одного получателя.
+func newRelay(cfg config.Outbox, log *logrus.Logger, store *storage, target string,
+	publisher orderevent.EventPublisher) *outbox.Relay {
+	return outbox.New(
+		outbox.Config{
+			Target:        target,
+			Interval:      cfg.Interval.Std(),
+			BatchSize:     cfg.BatchSize,
+			KeepPublished: cfg.KeepPublished.Std(),
+		},
+		outbox.Dependencies{
+			Log:       log,
+			Store:     store.psql,
+			Publisher: publisher,
+		})
+}
+
+func setLogLevel(log *logrus.Logger, cfg config.Log) {
+	// Уровень проверен в config.Validate
+	level, _ := logrus.ParseLevel(cfg.Level)
+	log.SetLevel(level)
+}
+
+func serverRateLimit(cfg config.RateLimit) server.RateLimit {
+	return server.RateLimit{RPS: cfg.RPS, Burst: cfg.Burst}
+}
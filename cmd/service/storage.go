@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"orderservice/internal/analytics"
+	"orderservice/internal/config"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderdb/orderbolt"
+	postgres "orderservice/internal/orderdb/orderpsql"
+	"orderservice/internal/outbox"
+	"orderservice/internal/provider/pgxprovider"
+	"orderservice/internal/schema"
+
+	"github.com/sirupsen/logrus"
+)
+
+// storage - persistent хранилище, выбранное в storage.backend
+type storage struct {
+	orders   orderdb.OrderDB
+	exporter orderdb.StreamingOrderDB
+	// aggregates nil - отчеты вне live окна недоступны
+	aggregates analytics.Source
+
+	// pgx и psql заданы только для postgres, от них зависят выборы
+	// лидера между репликами, outbox и webhooks
+	pgx  *pgxprovider.PGXProvider
+	psql *postgres.Postgres
+
+	close func()
+}
+
+func openStorage(cfg config.Config, log *logrus.Logger, registry *schema.Registry) (*storage, error) {
+	if cfg.Storage.Backend == config.StorageBolt {
+		db, err := orderbolt.New(
+			orderbolt.Config{Path: cfg.Storage.Path},
+			orderbolt.Dependencies{
+				Log:      log,
+				Registry: registry,
+			})
+		if err != nil {
+			return nil, err
+		}
+
+		return &storage{
+			orders:   db,
+			exporter: db,
+			close: func() {
+				if err := db.Close(); err != nil {
+					log.Errorf("failed to close storage: %v", err)
+				}
+			},
+		}, nil
+	}
+
+	pgxp, err := pgxprovider.New(pgxprovider.Config{
+		URL:            cfg.Postgres.URL,
+		ConnectTimeout: cfg.Postgres.ConnectTimeout.Std(),
+		MaxConns:       cfg.Postgres.MaxConns,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// У webhooks своя очередь событий, чтобы их ошибки не задерживали
+	// публикацию в NATS
+	var targets []string
+	if cfg.Outbox.Enabled {
+		targets = append(targets, outbox.TargetEvents)
+	}
+	if cfg.Webhooks.Enabled {
+		targets = append(targets, outbox.TargetWebhooks)
+	}
+
+	db := postgres.New(
+		postgres.Config{
+			QueryTimeout:  cfg.Postgres.QueryTimeout.Std(),
+			OutboxTargets: targets,
+		},
+		postgres.Dependencies{
+			Log:      log,
+			PGX:      pgxp,
+			Registry: registry,
+		})
+
+	return &storage{
+		orders:     db,
+		exporter:   db,
+		aggregates: db,
+		pgx:        pgxp,
+		psql:       db,
+		close:      func() { pgxp.Close(context.Background()) },
+	}, nil
+}
@@ -0,0 +1,274 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"orderservice/internal/currency"
+	"orderservice/internal/schema"
+	"slices"
+	"sort"
+	"time"
+)
+
+var ErrInvalidQuery = errors.New("invalid analytics query")
+
+type GroupBy string
+
+const (
+	GroupByDay             GroupBy = "day"
+	GroupByCurrency        GroupBy = "currency"
+	GroupByDeliveryService GroupBy = "delivery_service"
+	GroupByRegion          GroupBy = "region"
+	GroupByBank            GroupBy = "bank"
+
+	dayLayout = "2006-01-02"
+
+	defaultTopBrands = 5
+)
+
+var groupings = []GroupBy{
+	GroupByDay, GroupByCurrency, GroupByDeliveryService, GroupByRegion, GroupByBank,
+}
+
+func ParseGroupBy(s string) (GroupBy, error) {
+	if s == "" {
+		return GroupByDay, nil
+	}
+
+	for _, g := range groupings {
+		if GroupBy(s) == g {
+			return g, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: unknown group_by %q", ErrInvalidQuery, s)
+}
+
+// Query selects orders created in [From, To). Zero To means now.
+// Currency is the reporting currency totals are converted to.
+type Query struct {
+	GroupBy   GroupBy
+	From      time.Time
+	To        time.Time
+	TopBrands int
+	Currency  string
+}
+
+func (q Query) Validate() error {
+	if _, err := ParseGroupBy(string(q.GroupBy)); err != nil {
+		return err
+	}
+
+	if !q.To.IsZero() && !q.From.Before(q.To) {
+		return fmt.Errorf("%w: from must be before to", ErrInvalidQuery)
+	}
+
+	if q.Currency != "" {
+		if _, err := schema.MinorUnits(q.Currency); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+		}
+	}
+
+	if q.TopBrands < 0 {
+		return fmt.Errorf("%w: negative top_brands", ErrInvalidQuery)
+	}
+
+	return nil
+}
+
+type BrandCount struct {
+	Brand string `json:"brand"`
+	Items int    `json:"items"`
+}
+
+type Bucket struct {
+	Key    string `json:"key"`
+	Orders int    `json:"orders"`
+	// Amounts and DeliveryCosts are sums per payment currency
+	Amounts       []schema.Money `json:"amounts"`
+	DeliveryCosts []schema.Money `json:"delivery_costs"`
+	// Totals in the reporting currency. They are omitted when the bucket
+	// mixes currencies and no reporting currency was requested.
+	Amount        *schema.Money `json:"amount,omitempty"`
+	AvgBasket     *schema.Money `json:"avg_basket,omitempty"`
+	DeliveryCost  *schema.Money `json:"delivery_cost,omitempty"`
+	DeliveryShare *float64      `json:"delivery_share,omitempty"`
+	// Unconverted lists currencies without an exchange rate to the
+	// reporting currency. Their amounts are left out of the totals.
+	Unconverted []string     `json:"unconverted,omitempty"`
+	TopBrands   []BrandCount `json:"top_brands"`
+}
+
+type Report struct {
+	GroupBy  GroupBy   `json:"group_by"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Currency string    `json:"currency,omitempty"`
+	// Source is "live" when the report was built from the in-memory window
+	Source  string   `json:"source"`
+	Buckets []Bucket `json:"buckets"`
+}
+
+// Source computes per-currency aggregates, for example by pushing them
+// down to SQL. Totals are filled in by Service.
+type Source interface {
+	Aggregate(ctx context.Context, q Query) ([]Bucket, error)
+}
+
+// paymentMoney builds Money from a sum of payment amounts in whole units.
+// Currencies unknown to ISO 4217 table are kept as is without minor units.
+func paymentMoney(major int64, currency string) schema.Money {
+	m, err := schema.FromMajor(major, currency)
+	if err != nil {
+		return schema.Money{Minor: major, Currency: currency}
+	}
+
+	return m
+}
+
+// AddAmounts adds sums of Payment.Amount and Payment.DeliveryConst in
+// currency to the bucket.
+func (b *Bucket) AddAmounts(currency string, amount, deliveryCost int64) {
+	b.Amounts = append(b.Amounts, paymentMoney(amount, currency))
+	b.DeliveryCosts = append(b.DeliveryCosts, paymentMoney(deliveryCost, currency))
+}
+
+// Finalize computes totals and derived metrics in the reporting currency.
+// Without one totals are only computed for single currency buckets.
+func (b *Bucket) Finalize(ctx context.Context, reporting string, conv *currency.Converter) error {
+	var amount, deliveryCost schema.Money
+	switch {
+	case reporting != "":
+		var err error
+		if amount, err = b.sum(ctx, b.Amounts, reporting, conv); err != nil {
+			return err
+		}
+
+		if deliveryCost, err = b.sum(ctx, b.DeliveryCosts, reporting, conv); err != nil {
+			return err
+		}
+	case len(b.Amounts) == 1:
+		amount, deliveryCost = b.Amounts[0], b.DeliveryCosts[0]
+	default:
+		return nil
+	}
+
+	b.Amount, b.DeliveryCost = &amount, &deliveryCost
+	if b.Orders > 0 {
+		avg := amount.Div(int64(b.Orders))
+		b.AvgBasket = &avg
+	}
+
+	if !amount.IsZero() {
+		share := float64(deliveryCost.Minor) / float64(amount.Minor)
+		b.DeliveryShare = &share
+	}
+
+	return nil
+}
+
+// sum converts amounts to the reporting currency and adds them up.
+// Currencies that cannot be converted are skipped and added to
+// Unconverted, so that one unknown currency does not fail the report.
+func (b *Bucket) sum(ctx context.Context, amounts []schema.Money, reporting string,
+	conv *currency.Converter,
+) (schema.Money, error) {
+	total, err := schema.NewMoney(0, reporting)
+	if err != nil {
+		return schema.Money{}, err
+	}
+
+	for _, m := range amounts {
+		converted, err := conv.Convert(ctx, m, reporting)
+		if errors.Is(err, currency.ErrNoRate) || errors.Is(err, schema.ErrUnknownCurrency) {
+			if !slices.Contains(b.Unconverted, m.Currency) {
+				b.Unconverted = append(b.Unconverted, m.Currency)
+			}
+			continue
+		} else if err != nil {
+			return schema.Money{}, err
+		}
+
+		if total, err = total.Add(converted); err != nil {
+			return schema.Money{}, err
+		}
+	}
+
+	return total, nil
+}
+
+// SortTopBrands orders brands by descending item count and keeps top n.
+func (b *Bucket) SortTopBrands(n int) {
+	sort.Slice(b.TopBrands, func(i, j int) bool {
+		if b.TopBrands[i].Items != b.TopBrands[j].Items {
+			return b.TopBrands[i].Items > b.TopBrands[j].Items
+		}
+		return b.TopBrands[i].Brand < b.TopBrands[j].Brand
+	})
+
+	if len(b.TopBrands) > n {
+		b.TopBrands = b.TopBrands[:n]
+	}
+}
+
+// accumulator collects raw sums for one bucket.
+type accumulator struct {
+	orders       int
+	amount       map[string]int64
+	deliveryCost map[string]int64
+	brands       map[string]int
+}
+
+func newAccumulator() *accumulator {
+	return &accumulator{
+		amount:       make(map[string]int64),
+		deliveryCost: make(map[string]int64),
+		brands:       make(map[string]int),
+	}
+}
+
+func (a *accumulator) add(other *accumulator, sign int) {
+	a.orders += sign * other.orders
+	for currency, v := range other.amount {
+		a.amount[currency] += int64(sign) * v
+	}
+	for currency, v := range other.deliveryCost {
+		a.deliveryCost[currency] += int64(sign) * v
+		if sign < 0 && a.amount[currency] == 0 && a.deliveryCost[currency] == 0 {
+			delete(a.amount, currency)
+			delete(a.deliveryCost, currency)
+		}
+	}
+	for brand, n := range other.brands {
+		a.brands[brand] += sign * n
+		if a.brands[brand] <= 0 {
+			delete(a.brands, brand)
+		}
+	}
+}
+
+func (a *accumulator) bucket(key string, topBrands int) Bucket {
+	b := Bucket{
+		Key:       key,
+		Orders:    a.orders,
+		TopBrands: make([]BrandCount, 0, len(a.brands)),
+	}
+
+	currencies := make([]string, 0, len(a.amount))
+	for currency := range a.amount {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	for _, currency := range currencies {
+		b.AddAmounts(currency, a.amount[currency], a.deliveryCost[currency])
+	}
+
+	for brand, n := range a.brands {
+		b.TopBrands = append(b.TopBrands, BrandCount{Brand: brand, Items: n})
+	}
+
+	b.SortTopBrands(topBrands)
+	return b
+}
@@ -0,0 +1,215 @@
+package analytics
+
+import (
+	"context"
+	"orderservice/internal/schema"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWindow = 7 * 24 * time.Hour
+	day           = 24 * time.Hour
+)
+
+type LiveConfig struct {
+	// Window is how many recent days of orders are kept in memory
+	Window time.Duration
+}
+
+// contribution is what a single order added to the summary, kept to
+// undo it when the order is replaced or leaves the window.
+type contribution struct {
+	day  string
+	keys map[GroupBy]string
+	acc  accumulator
+}
+
+type daySummary struct {
+	groups map[GroupBy]map[string]*accumulator
+	orders map[schema.OrderUID]struct{}
+}
+
+// Live incrementally maintains daily aggregates of recent orders. It
+// implements orderdb.OrderObserver and is fed by the cache.
+type Live struct {
+	cfg LiveConfig
+	now func() time.Time
+
+	mu     sync.Mutex
+	days   map[string]*daySummary
+	orders map[schema.OrderUID]*contribution
+}
+
+func NewLive(cfg LiveConfig) *Live {
+	if cfg.Window == 0 {
+		cfg.Window = defaultWindow
+	}
+
+	return &Live{
+		cfg:    cfg,
+		now:    time.Now,
+		days:   make(map[string]*daySummary),
+		orders: make(map[schema.OrderUID]*contribution),
+	}
+}
+
+// Since returns the start of the oldest day kept in memory.
+func (l *Live) Since() time.Time {
+	return l.now().UTC().Add(-l.cfg.Window).Truncate(day)
+}
+
+// Covers reports whether q can be answered from memory: the range must
+// start at a day boundary inside the window.
+func (l *Live) Covers(q Query) bool {
+	if q.From.Before(l.Since()) || !q.From.Equal(q.From.Truncate(day)) {
+		return false
+	}
+
+	return q.To.IsZero() || q.To.Equal(q.To.Truncate(day))
+}
+
+func (l *Live) OrderStored(order schema.Order) {
+	created := order.DateCreated.Time
+	if created.IsZero() {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.removeLocked(order.OrderUID)
+	if created.Before(l.Since()) {
+		return
+	}
+
+	c := &contribution{
+		day: created.UTC().Format(dayLayout),
+		keys: map[GroupBy]string{
+			GroupByCurrency:        order.Payment.Currency,
+			GroupByDeliveryService: order.DeliveryService,
+			GroupByRegion:          order.Delivery.Region,
+			GroupByBank:            order.Payment.Bank,
+		},
+		acc: *newAccumulator(),
+	}
+	c.acc.orders = 1
+	c.acc.amount[order.Payment.Currency] = int64(order.Payment.Amount)
+	c.acc.deliveryCost[order.Payment.Currency] = int64(order.Payment.DeliveryConst)
+	c.keys[GroupByDay] = c.day
+
+	for _, item := range order.Items {
+		if item.Brand != "" {
+			c.acc.brands[item.Brand]++
+		}
+	}
+
+	summary, ok := l.days[c.day]
+	if !ok {
+		summary = &daySummary{
+			groups: make(map[GroupBy]map[string]*accumulator),
+			orders: make(map[schema.OrderUID]struct{}),
+		}
+		l.days[c.day] = summary
+	}
+
+	summary.orders[order.OrderUID] = struct{}{}
+	summary.apply(c, 1)
+	l.orders[order.OrderUID] = c
+}
+
+func (l *Live) OrderRemoved(orderUID schema.OrderUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.removeLocked(orderUID)
+}
+
+func (l *Live) removeLocked(orderUID schema.OrderUID) {
+	c, ok := l.orders[orderUID]
+	if !ok {
+		return
+	}
+
+	delete(l.orders, orderUID)
+	if summary, ok := l.days[c.day]; ok {
+		delete(summary.orders, orderUID)
+		summary.apply(c, -1)
+	}
+}
+
+func (d *daySummary) apply(c *contribution, sign int) {
+	for g, key := range c.keys {
+		byKey, ok := d.groups[g]
+		if !ok {
+			byKey = make(map[string]*accumulator)
+			d.groups[g] = byKey
+		}
+
+		acc, ok := byKey[key]
+		if !ok {
+			acc = newAccumulator()
+			byKey[key] = acc
+		}
+
+		acc.add(&c.acc, sign)
+		if acc.orders == 0 {
+			delete(byKey, key)
+		}
+	}
+}
+
+// evictLocked удаляет дни, вышедшие за пределы окна.
+func (l *Live) evictLocked() {
+	since := l.Since().Format(dayLayout)
+	for key, summary := range l.days {
+		if key >= since {
+			continue
+		}
+
+		for uid := range summary.orders {
+			delete(l.orders, uid)
+		}
+		delete(l.days, key)
+	}
+}
+
+func (l *Live) Aggregate(_ context.Context, q Query) ([]Bucket, error) {
+	from := q.From.UTC().Format(dayLayout)
+	to := ""
+	if !q.To.IsZero() {
+		to = q.To.UTC().Format(dayLayout)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.evictLocked()
+	merged := make(map[string]*accumulator)
+	for key, summary := range l.days {
+		if key < from || (to != "" && key >= to) {
+			continue
+		}
+
+		for group, acc := range summary.groups[q.GroupBy] {
+			m, ok := merged[group]
+			if !ok {
+				m = newAccumulator()
+				merged[group] = m
+			}
+			m.add(acc, 1)
+		}
+	}
+
+	ret := make([]Bucket, 0, len(merged))
+	for key, acc := range merged {
+		ret = append(ret, acc.bucket(key, q.TopBrands))
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Key < ret[j].Key
+	})
+
+	return ret, nil
+}
@@ -0,0 +1,88 @@
+package analytics
+
+import (
+	"context"
+	"orderservice/internal/currency"
+	"orderservice/internal/schema"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testOrder(uid, created, currency string, amount, delivery int, brands ...string) schema.Order {
+	ts, err := schema.ParseTimestamp(created)
+	if err != nil {
+		panic(err)
+	}
+
+	order := schema.Order{
+		OrderUID:    schema.OrderUID(uid),
+		DateCreated: ts,
+		Payment:     schema.Payment{Currency: currency, Amount: amount, DeliveryConst: delivery},
+	}
+	for _, brand := range brands {
+		order.Items = append(order.Items, schema.Item{Brand: brand})
+	}
+	return order
+}
+
+func TestLive(t *testing.T) {
+	now := time.Date(2023, 11, 10, 12, 0, 0, 0, time.UTC)
+	live := NewLive(LiveConfig{Window: 3 * day})
+	live.now = func() time.Time { return now }
+
+	live.OrderStored(testOrder("1", "2023-11-09T10:00:00Z", "USD", 100, 10, "Nike", "Nike", "Sony"))
+	live.OrderStored(testOrder("2", "2023-11-10T10:00:00Z", "USD", 300, 30, "Sony"))
+	live.OrderStored(testOrder("3", "2023-11-10T11:00:00Z", "EUR", 50, 0))
+	// Вне окна
+	live.OrderStored(testOrder("4", "2023-11-01T11:00:00Z", "EUR", 50, 0))
+	// Повторное сохранение заменяет предыдущий вклад заказа
+	live.OrderStored(testOrder("3", "2023-11-10T11:00:00Z", "EUR", 70, 7))
+
+	from := time.Date(2023, 11, 9, 0, 0, 0, 0, time.UTC)
+	q := Query{GroupBy: GroupByCurrency, From: from, TopBrands: 1}
+	require.True(t, live.Covers(q))
+	require.False(t, live.Covers(Query{From: from.Add(time.Hour)}))
+	require.False(t, live.Covers(Query{From: from.Add(-5 * day)}))
+
+	buckets, err := live.Aggregate(context.Background(), q)
+	require.NoError(t, err)
+	require.Equal(t, []Bucket{
+		{Key: "EUR", Orders: 1,
+			Amounts:       []schema.Money{{Minor: 7000, Currency: "EUR"}},
+			DeliveryCosts: []schema.Money{{Minor: 700, Currency: "EUR"}},
+			TopBrands:     []BrandCount{}},
+		{Key: "USD", Orders: 2,
+			Amounts:       []schema.Money{{Minor: 40000, Currency: "USD"}},
+			DeliveryCosts: []schema.Money{{Minor: 4000, Currency: "USD"}},
+			TopBrands:     []BrandCount{{Brand: "Nike", Items: 2}}},
+	}, buckets)
+
+	require.NoError(t, buckets[1].Finalize(context.Background(), "", nil))
+	require.Equal(t, "200.00 USD", buckets[1].AvgBasket.String())
+	require.Equal(t, 0.1, *buckets[1].DeliveryShare)
+
+	live.OrderRemoved("1")
+	buckets, err = live.Aggregate(context.Background(), Query{GroupBy: GroupByDay, From: from, TopBrands: 5})
+	require.NoError(t, err)
+	require.Len(t, buckets, 1)
+	require.Equal(t, "2023-11-10", buckets[0].Key)
+	require.Equal(t, 2, buckets[0].Orders)
+}
+
+// Валюта без курса не ломает отчет, а исключается из итогов
+func TestFinalizeUnconverted(t *testing.T) {
+	rates, err := currency.NewStaticRates("USD", map[string]string{"EUR": "0.5"})
+	require.NoError(t, err)
+
+	b := Bucket{Orders: 3}
+	b.AddAmounts("USD", 100, 10)
+	b.AddAmounts("EUR", 50, 5)
+	b.AddAmounts("XXX", 70, 7)
+
+	require.NoError(t, b.Finalize(context.Background(), "USD", currency.NewConverter(rates)))
+	require.Equal(t, "200.00 USD", b.Amount.String())
+	require.Equal(t, "20.00 USD", b.DeliveryCost.String())
+	require.Equal(t, []string{"XXX"}, b.Unconverted)
+}
@@ -0,0 +1,94 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"orderservice/internal/currency"
+	"time"
+)
+
+type Config struct {
+	// DefaultRange is used when the query has no From
+	DefaultRange time.Duration
+}
+
+type Dependencies struct {
+	// Source answers queries outside of the live window
+	Source Source
+	Live   *Live
+	// Converter is required for reports in a reporting currency
+	Converter *currency.Converter
+}
+
+type Service struct {
+	cfg  Config
+	deps Dependencies
+}
+
+func NewService(cfg Config, deps Dependencies) *Service {
+	if cfg.DefaultRange == 0 {
+		cfg.DefaultRange = defaultWindow
+	}
+
+	return &Service{
+		cfg:  cfg,
+		deps: deps,
+	}
+}
+
+func (s *Service) Report(ctx context.Context, q Query) (Report, error) {
+	if q.GroupBy == "" {
+		q.GroupBy = GroupByDay
+	}
+
+	if q.TopBrands == 0 {
+		q.TopBrands = defaultTopBrands
+	}
+
+	if q.From.IsZero() {
+		q.From = time.Now().UTC().Add(-s.cfg.DefaultRange).Truncate(day)
+	}
+
+	if err := q.Validate(); err != nil {
+		return Report{}, err
+	}
+
+	if q.Currency != "" && s.deps.Converter == nil {
+		return Report{}, fmt.Errorf("%w: currency conversion is not configured", ErrInvalidQuery)
+	}
+
+	report := Report{
+		GroupBy:  q.GroupBy,
+		From:     q.From,
+		To:       q.To,
+		Currency: q.Currency,
+		Source:   "database",
+	}
+
+	var (
+		buckets []Bucket
+		err     error
+	)
+
+	if s.deps.Live != nil && s.deps.Live.Covers(q) {
+		report.Source = "live"
+		buckets, err = s.deps.Live.Aggregate(ctx, q)
+	} else if s.deps.Source != nil {
+		buckets, err = s.deps.Source.Aggregate(ctx, q)
+	} else {
+		err = fmt.Errorf("%w: range is outside of the live window", ErrInvalidQuery)
+	}
+
+	if err != nil {
+		return Report{}, err
+	}
+
+	for i := range buckets {
+		if err := buckets[i].Finalize(ctx, q.Currency, s.deps.Converter); err != nil {
+			return Report{}, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+		}
+	}
+
+	report.Buckets = buckets
+	return report, nil
+}
@@ -0,0 +1,229 @@
+package apispec
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema 2020-12 used by the generator.
+// OpenAPI 3.1 uses the same dialect, so schemas are shared by both.
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+
+	Defs map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Override builds the schema of a type that encodes itself differently
+// from its Go structure, for example with a custom MarshalJSON.
+type Override func(g *Generator) *Schema
+
+// Generator builds schemas from Go types by reflection following the
+// rules of encoding/json. Named structs become definitions referenced
+// with refPrefix, e.g. "#/$defs/" or "#/components/schemas/".
+//
+// Fields are optional unless the jsonschema tag marks them required. The
+// tag accepts comma separated options: required, minItems=N and minLength=N.
+type Generator struct {
+	refPrefix string
+	defs      map[string]*Schema
+	types     map[reflect.Type]string
+	overrides map[reflect.Type]Override
+}
+
+func NewGenerator(refPrefix string) *Generator {
+	g := &Generator{
+		refPrefix: refPrefix,
+		defs:      make(map[string]*Schema),
+		types:     make(map[reflect.Type]string),
+		overrides: make(map[reflect.Type]Override),
+	}
+
+	g.Override(reflect.TypeOf(time.Time{}), func(*Generator) *Schema {
+		return &Schema{Type: "string", Format: "date-time"}
+	})
+	g.Override(reflect.TypeOf(json.RawMessage{}), func(*Generator) *Schema {
+		return &Schema{}
+	})
+	registerSchemaTypes(g)
+
+	return g
+}
+
+func (g *Generator) Override(t reflect.Type, fn Override) {
+	g.overrides[t] = fn
+}
+
+// Definitions returns schemas of all named structs seen so far.
+func (g *Generator) Definitions() map[string]*Schema {
+	return g.defs
+}
+
+// Of returns the schema of the type of v.
+func (g *Generator) Of(v any) *Schema {
+	return g.Schema(reflect.TypeOf(v))
+}
+
+func (g *Generator) Schema(t reflect.Type) *Schema {
+	if fn, ok := g.overrides[t]; ok {
+		return fn(g)
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.Schema(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.Schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.Schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return g.Ref(t)
+	}
+
+	return &Schema{}
+}
+
+// Ref registers the named struct t as a definition and returns a
+// reference to it.
+func (g *Generator) Ref(t reflect.Type) *Schema {
+	name, ok := g.types[t]
+	if !ok {
+		name = g.defName(t)
+		g.types[t] = name
+		// Заглушка на случай рекурсивных типов
+		g.defs[name] = &Schema{}
+		*g.defs[name] = *g.structSchema(t)
+	}
+
+	return &Schema{Ref: g.refPrefix + name}
+}
+
+func (g *Generator) defName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := g.defs[name]; !taken {
+		return name
+	}
+
+	pkg := t.PkgPath()
+	pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+	return pkg + "." + name
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *Generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		field := g.Schema(f.Type)
+		required := false
+		for _, opt := range strings.Split(f.Tag.Get("jsonschema"), ",") {
+			key, value, _ := strings.Cut(opt, "=")
+			switch key {
+			case "required":
+				required = true
+			case "minItems":
+				field.MinItems = intPtr(value)
+			case "minLength":
+				field.MinLength = intPtr(value)
+			}
+		}
+
+		if required {
+			field = nonNull(field)
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = field
+	}
+}
+
+// nonNull drops the null alternative of a required field: null is what
+// encoding/json produces for a missing value, e.g. a zero schema.Timestamp.
+func nonNull(s *Schema) *Schema {
+	if len(s.OneOf) == 0 {
+		return s
+	}
+
+	ret := *s
+	ret.OneOf = make([]*Schema, 0, len(s.OneOf))
+	for _, alt := range s.OneOf {
+		if alt.Type != "null" {
+			ret.OneOf = append(ret.OneOf, alt)
+		}
+	}
+
+	return &ret
+}
+
+func intPtr(s string) *int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		panic("apispec: invalid jsonschema tag value " + s)
+	}
+
+	return &n
+}
@@ -0,0 +1,59 @@
+package apispec
+
+import (
+	"orderservice/internal/schema"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderSchemaPublished(t *testing.T) {
+	want, err := os.ReadFile("../../api/order.schema.json")
+	require.NoError(t, err)
+
+	got, err := MarshalIndent(OrderSchema())
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(got), "api/order.schema.json is outdated, run make spec")
+}
+
+func TestGeneratorTags(t *testing.T) {
+	type Inner struct {
+		Value string `json:"value"`
+	}
+	type Embedded struct {
+		Flag bool `json:"flag"`
+	}
+	type Outer struct {
+		Embedded
+		Name    string           `json:"name" jsonschema:"required,minLength=1"`
+		List    []Inner          `json:"list,omitempty" jsonschema:"minItems=2"`
+		Skipped string           `json:"-"`
+		Ptr     *float64         `json:"ptr"`
+		Created schema.Timestamp `json:"created" jsonschema:"required"`
+		private int
+	}
+
+	g := NewGenerator("#/$defs/")
+	ref := g.Schema(reflect.TypeOf(Outer{}))
+	require.Equal(t, "#/$defs/Outer", ref.Ref)
+
+	two, one := 2, 1
+	require.Equal(t, &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"flag": {Type: "boolean"},
+			"name": {Type: "string", MinLength: &one},
+			"list": {Type: "array", Items: &Schema{Ref: "#/$defs/Inner"}, MinItems: &two},
+			"ptr":  {Type: "number"},
+			// Обязательное поле не может быть null
+			"created": {OneOf: []*Schema{
+				{Type: "string", Format: "date-time"},
+				{Type: "integer", Description: "Unix time in seconds or milliseconds"},
+			}},
+		},
+		Required: []string{"name", "created"},
+	}, g.Definitions()["Outer"])
+	require.Contains(t, g.Definitions(), "Inner")
+}
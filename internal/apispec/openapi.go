@@ -0,0 +1,138 @@
+package apispec
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+const openAPIVersion = "3.1.0"
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem maps lower case HTTP methods to operations.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string              `json:"summary,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Builder collects operations and the schemas they reference.
+type Builder struct {
+	doc *Document
+	gen *Generator
+}
+
+func NewBuilder(title, version string) *Builder {
+	return &Builder{
+		doc: &Document{
+			OpenAPI: openAPIVersion,
+			Info:    Info{Title: title, Version: version},
+			Paths:   make(map[string]PathItem),
+		},
+		gen: NewGenerator("#/components/schemas/"),
+	}
+}
+
+// Schema returns the schema of the type of v, registering named structs
+// as components.
+func (b *Builder) Schema(v any) *Schema {
+	return b.gen.Of(v)
+}
+
+var ginParam = regexp.MustCompile(`[:*]([A-Za-z_]+)`)
+
+// Add adds an operation. Path is given in gin syntax, path parameters
+// like :id are converted to {id} and declared automatically.
+func (b *Builder) Add(method, path string, op Operation) {
+	for _, m := range ginParam.FindAllStringSubmatch(path, -1) {
+		op.Parameters = append([]Parameter{{
+			Name:     m[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		}}, op.Parameters...)
+	}
+
+	path = OpenAPIPath(path)
+	item, ok := b.doc.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		b.doc.Paths[path] = item
+	}
+
+	item[strings.ToLower(method)] = &op
+}
+
+// OpenAPIPath converts a gin route to an OpenAPI path.
+func OpenAPIPath(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return ginParam.ReplaceAllString(path, "{$1}")
+}
+
+func (b *Builder) Document() *Document {
+	b.doc.Components.Schemas = b.gen.Definitions()
+	return b.doc
+}
+
+// JSON is a response or request body with the schema of v.
+func (b *Builder) JSON(v any) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: b.Schema(v)}}
+}
+
+func QueryParam(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+// MarshalIndent is the encoding used for the published files in api/.
+func MarshalIndent(doc any) ([]byte, error) {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
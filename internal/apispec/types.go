@@ -0,0 +1,52 @@
+package apispec
+
+import (
+	"orderservice/internal/schema"
+	"reflect"
+)
+
+// registerSchemaTypes описывает типы schema с собственной JSON кодировкой
+func registerSchemaTypes(g *Generator) {
+	g.Override(reflect.TypeOf(schema.Timestamp{}), func(*Generator) *Schema {
+		return &Schema{OneOf: []*Schema{
+			{Type: "string", Format: "date-time"},
+			{Type: "integer", Description: "Unix time in seconds or milliseconds"},
+			{Type: "null"},
+		}}
+	})
+
+	g.Override(reflect.TypeOf(schema.Items{}), func(g *Generator) *Schema {
+		item := g.Ref(reflect.TypeOf(schema.Item{}))
+		one := 1
+		return &Schema{OneOf: []*Schema{
+			{Type: "array", Items: item, MinItems: &one},
+			{Ref: item.Ref, Description: "Legacy producers send a single item as an object"},
+		}}
+	})
+
+	g.Override(reflect.TypeOf(schema.Money{}), func(*Generator) *Schema {
+		return &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"amount":   {Type: "string", Pattern: `^-?[0-9]+(\.[0-9]+)?$`},
+				"currency": {Type: "string", Pattern: `^[A-Z]{3}$`},
+			},
+			Required: []string{"amount", "currency"},
+		}
+	})
+}
+
+// OrderSchema returns the standalone JSON Schema of schema.Order, the
+// contract for order payloads sent by producers.
+func OrderSchema() *Schema {
+	g := NewGenerator("#/$defs/")
+	root := g.Of(schema.Order{})
+
+	return &Schema{
+		Schema: jsonSchemaDraft,
+		ID:     "order.schema.json",
+		Title:  "Order",
+		Ref:    root.Ref,
+		Defs:   g.Definitions(),
+	}
+}
@@ -0,0 +1,319 @@
+// Package config загружает настройки сервиса из YAML или TOML файла,
+// переменных окружения и флагов командной строки, в порядке возрастания
+// приоритета.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"orderservice/internal/orderevent/ordercodec"
+	"orderservice/internal/provider/natsprovider"
+	"orderservice/internal/schema"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrInvalid = errors.New("invalid config")
+
+// Config соответствует структуре файла. Ключи флагов совпадают с путем в
+// файле, например -postgres.query_timeout=2s.
+type Config struct {
+	Log       Log       `yaml:"log" toml:"log"`
+	Server    Server    `yaml:"server" toml:"server"`
+	Storage   Storage   `yaml:"storage" toml:"storage"`
+	Postgres  Postgres  `yaml:"postgres" toml:"postgres"`
+	NATS      NATS      `yaml:"nats" toml:"nats"`
+	Cache     Cache     `yaml:"cache" toml:"cache"`
+	Schema    Schema    `yaml:"schema" toml:"schema"`
+	Analytics Analytics `yaml:"analytics" toml:"analytics"`
+	Leader    Leader    `yaml:"leader" toml:"leader"`
+	Outbox    Outbox    `yaml:"outbox" toml:"outbox"`
+	Webhooks  Webhooks  `yaml:"webhooks" toml:"webhooks"`
+}
+
+type Log struct {
+	// Level перечитывается по SIGHUP
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL"`
+}
+
+type Server struct {
+	Address         string   `yaml:"address" toml:"address" env:"SERVER_ADDR"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// RateLimit перечитывается по SIGHUP
+	RateLimit RateLimit `yaml:"rate_limit" toml:"rate_limit"`
+	// AdminToken включает маршруты /admin и /webhooks, пустой - маршруты
+	// недоступны
+	AdminToken string `yaml:"admin_token" toml:"admin_token" env:"ADMIN_TOKEN"`
+}
+
+type RateLimit struct {
+	// RPS - допустимое число запросов в секунду на весь сервер, 0 - без ограничения
+	RPS   float64 `yaml:"rps" toml:"rps" env:"RATE_LIMIT_RPS"`
+	Burst int     `yaml:"burst" toml:"burst" env:"RATE_LIMIT_BURST"`
+}
+
+const (
+	StoragePostgres = "postgres"
+	StorageBolt     = "bolt"
+)
+
+// Storage выбирает persistent хранилище заказов. Встроенное хранилище
+// bolt работает без Postgres, но только с одной репликой и без outbox.
+type Storage struct {
+	Backend string `yaml:"backend" toml:"backend" env:"STORAGE_BACKEND"`
+	// Path - файл базы bolt
+	Path string `yaml:"path" toml:"path" env:"STORAGE_PATH"`
+}
+
+type Postgres struct {
+	URL            string   `yaml:"url" toml:"url" env:"POSTGRES_URL"`
+	ConnectTimeout Duration `yaml:"connect_timeout" toml:"connect_timeout"`
+	QueryTimeout   Duration `yaml:"query_timeout" toml:"query_timeout"`
+	MaxConns       int32    `yaml:"max_conns" toml:"max_conns"`
+}
+
+type NATS struct {
+	URL       string `yaml:"url" toml:"url" env:"NATS_URL"`
+	ClusterID string `yaml:"cluster_id" toml:"cluster_id" env:"STAN_CLUSTER_ID"`
+	// ClientID уникален для каждой реплики, пустое значение - сгенерировать
+	ClientID string `yaml:"client_id" toml:"client_id" env:"STAN_CLIENT_ID"`
+	Channel  string `yaml:"channel" toml:"channel" env:"STAN_CHANNEL_NAME"`
+	// QueueGroup включает durable подписку в группе для нескольких реплик
+	QueueGroup  string `yaml:"queue_group" toml:"queue_group" env:"STAN_QUEUE_GROUP"`
+	DurableName string `yaml:"durable_name" toml:"durable_name"`
+	// PeerSubject - subject core NATS для уведомлений об изменениях кеша
+	// между репликами, пустое значение отключает уведомления
+	PeerSubject    string   `yaml:"peer_subject" toml:"peer_subject"`
+	QueueDepth     int      `yaml:"queue_depth" toml:"queue_depth"`
+	ConnectTimeout Duration `yaml:"connect_timeout" toml:"connect_timeout"`
+	// ContentType публикуемых сообщений, принимаются все форматы
+	ContentType string `yaml:"content_type" toml:"content_type"`
+	// Consumer - обработка сообщений канала, queue_depth ограничивает
+	// количество сообщений в обработке сверху
+	Consumer Consumer `yaml:"consumer" toml:"consumer"`
+}
+
+type Consumer struct {
+	// Workers обрабатывают сообщения разных заказов параллельно
+	Workers     int `yaml:"workers" toml:"workers" env:"CONSUMER_WORKERS"`
+	MinInflight int `yaml:"min_inflight" toml:"min_inflight"`
+	// TargetLatency - задержка записи, выше которой количество сообщений в
+	// обработке уменьшается, 0 отключает адаптивный предел
+	TargetLatency Duration `yaml:"target_latency" toml:"target_latency"`
+	// FailureThreshold ошибок хранилища подряд приостанавливают подписку,
+	// 0 отключает паузу
+	FailureThreshold int      `yaml:"failure_threshold" toml:"failure_threshold"`
+	ProbeInterval    Duration `yaml:"probe_interval" toml:"probe_interval"`
+}
+
+// Cache - настройки кеша заказов. Кеш хранит все заказы, поэтому размера
+// у него нет. search_index применяется только при запуске.
+type Cache struct {
+	SearchIndex bool `yaml:"search_index" toml:"search_index" env:"CACHE_SEARCH_INDEX"`
+}
+
+type Schema struct {
+	FieldPolicy string `yaml:"field_policy" toml:"field_policy" env:"SCHEMA_FIELD_POLICY"`
+}
+
+type Analytics struct {
+	// RatesFile - JSON с курсами валют, без него отчеты строятся только
+	// в валютах платежей
+	RatesFile    string   `yaml:"rates_file" toml:"rates_file" env:"RATES_FILE"`
+	LiveWindow   Duration `yaml:"live_window" toml:"live_window"`
+	DefaultRange Duration `yaml:"default_range" toml:"default_range"`
+}
+
+// Leader - выбор реплики для фоновых задач через advisory блокировку Postgres.
+type Leader struct {
+	Name          string   `yaml:"name" toml:"name"`
+	RenewInterval Duration `yaml:"renew_interval" toml:"renew_interval"`
+	RetryInterval Duration `yaml:"retry_interval" toml:"retry_interval"`
+}
+
+// Outbox - публикация событий order.accepted и order.rejected лидером.
+type Outbox struct {
+	Enabled       bool     `yaml:"enabled" toml:"enabled" env:"OUTBOX_ENABLED"`
+	Channel       string   `yaml:"channel" toml:"channel" env:"STAN_EVENTS_CHANNEL"`
+	Interval      Duration `yaml:"interval" toml:"interval"`
+	BatchSize     int      `yaml:"batch_size" toml:"batch_size"`
+	KeepPublished Duration `yaml:"keep_published" toml:"keep_published"`
+}
+
+// Webhooks - доставка событий outbox подписчикам, требует outbox.enabled.
+type Webhooks struct {
+	Enabled          bool     `yaml:"enabled" toml:"enabled" env:"WEBHOOKS_ENABLED"`
+	PollInterval     Duration `yaml:"poll_interval" toml:"poll_interval"`
+	BatchSize        int      `yaml:"batch_size" toml:"batch_size"`
+	Timeout          Duration `yaml:"timeout" toml:"timeout"`
+	MaxAttempts      int      `yaml:"max_attempts" toml:"max_attempts"`
+	Backoff          Duration `yaml:"backoff" toml:"backoff"`
+	MaxBackoff       Duration `yaml:"max_backoff" toml:"max_backoff"`
+	FailureThreshold int      `yaml:"failure_threshold" toml:"failure_threshold"`
+	Cooldown         Duration `yaml:"cooldown" toml:"cooldown"`
+}
+
+// Default возвращает значения, которые раньше были зашиты в cmd/service.
+func Default() Config {
+	return Config{
+		Log: Log{Level: logrus.InfoLevel.String()},
+		Server: Server{
+			Address:         ":8080",
+			ShutdownTimeout: Duration(5 * time.Second),
+		},
+		Storage: Storage{
+			Backend: StoragePostgres,
+			Path:    "orders.db",
+		},
+		Postgres: Postgres{
+			ConnectTimeout: Duration(2 * time.Second),
+			QueryTimeout:   Duration(time.Second),
+		},
+		NATS: NATS{
+			QueueDepth:     1024,
+			ConnectTimeout: Duration(3 * time.Second),
+			ContentType:    "application/json",
+			PeerSubject:    "orders.cache",
+			Consumer: Consumer{
+				Workers:          1,
+				MinInflight:      1,
+				TargetLatency:    Duration(250 * time.Millisecond),
+				FailureThreshold: 5,
+				ProbeInterval:    Duration(5 * time.Second),
+			},
+		},
+		Schema: Schema{FieldPolicy: "lenient"},
+		Analytics: Analytics{
+			LiveWindow:   Duration(7 * 24 * time.Hour),
+			DefaultRange: Duration(7 * 24 * time.Hour),
+		},
+		Leader: Leader{
+			Name:          "orderservice",
+			RenewInterval: Duration(5 * time.Second),
+			RetryInterval: Duration(5 * time.Second),
+		},
+		Outbox: Outbox{
+			Channel:       "order-events",
+			Interval:      Duration(time.Second),
+			BatchSize:     100,
+			KeepPublished: Duration(24 * time.Hour),
+		},
+		Webhooks: Webhooks{
+			PollInterval:     Duration(time.Second),
+			BatchSize:        50,
+			Timeout:          Duration(10 * time.Second),
+			MaxAttempts:      8,
+			Backoff:          Duration(5 * time.Second),
+			MaxBackoff:       Duration(time.Hour),
+			FailureThreshold: 5,
+			Cooldown:         Duration(time.Minute),
+		},
+	}
+}
+
+// Validate проверяет значения, которые иначе привели бы к ошибке только
+// при первом обращении к компоненту.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	_, err := logrus.ParseLevel(c.Log.Level)
+	check(err == nil, "log.level: unknown level %q", c.Log.Level)
+
+	_, _, err = net.SplitHostPort(c.Server.Address)
+	check(err == nil, "server.address: %q is not host:port", c.Server.Address)
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(c.Server.RateLimit.RPS >= 0, "server.rate_limit.rps must not be negative")
+	check(c.Server.RateLimit.RPS == 0 || c.Server.RateLimit.Burst > 0,
+		"server.rate_limit.burst must be positive when rps is set")
+
+	switch c.Storage.Backend {
+	case StoragePostgres:
+		check(c.Postgres.URL != "", "postgres.url is required")
+		check(c.Postgres.QueryTimeout > 0, "postgres.query_timeout must be positive")
+		check(c.Postgres.MaxConns >= 0, "postgres.max_conns must not be negative")
+	case StorageBolt:
+		check(c.Storage.Path != "", "storage.path is required")
+		check(!c.Outbox.Enabled, "outbox requires the postgres storage backend")
+		check(c.NATS.QueueGroup == "", "nats.queue_group requires the postgres storage backend")
+	default:
+		check(false, "storage.backend: unknown backend %q", c.Storage.Backend)
+	}
+
+	check(c.NATS.URL != "", "nats.url is required")
+	check(c.NATS.ClusterID != "", "nats.cluster_id is required")
+	check(natsprovider.ValidClientID(c.NATS.ClientID),
+		"nats.client_id: %q may only contain letters, digits, - and _", c.NATS.ClientID)
+	check(c.NATS.Channel != "", "nats.channel is required")
+	check(c.NATS.QueueDepth > 0, "nats.queue_depth must be positive")
+	check(c.NATS.Consumer.Workers > 0, "nats.consumer.workers must be positive")
+	check(c.NATS.Consumer.MinInflight > 0 && c.NATS.Consumer.MinInflight <= c.NATS.QueueDepth,
+		"nats.consumer.min_inflight must be between 1 and queue_depth")
+	check(c.NATS.Consumer.TargetLatency >= 0, "nats.consumer.target_latency must not be negative")
+	check(c.NATS.Consumer.FailureThreshold >= 0, "nats.consumer.failure_threshold must not be negative")
+	check(c.NATS.Consumer.ProbeInterval > 0, "nats.consumer.probe_interval must be positive")
+
+	_, err = ordercodec.ParseContentType(c.NATS.ContentType)
+	check(err == nil, "nats.content_type: %v", err)
+
+	_, err = schema.ParseFieldPolicy(c.Schema.FieldPolicy)
+	check(err == nil, "schema.field_policy: %v", err)
+
+	check(c.Analytics.LiveWindow >= 0, "analytics.live_window must not be negative")
+	check(c.Analytics.DefaultRange >= 0, "analytics.default_range must not be negative")
+
+	check(c.Leader.Name != "", "leader.name is required")
+	check(c.Leader.RenewInterval > 0, "leader.renew_interval must be positive")
+	check(c.Leader.RetryInterval > 0, "leader.retry_interval must be positive")
+
+	if c.Outbox.Enabled {
+		check(c.Outbox.Channel != "", "outbox.channel is required")
+		check(c.Outbox.Interval > 0, "outbox.interval must be positive")
+		check(c.Outbox.BatchSize > 0, "outbox.batch_size must be positive")
+		check(c.Outbox.KeepPublished > 0, "outbox.keep_published must be positive")
+	}
+
+	if c.Webhooks.Enabled {
+		check(c.Outbox.Enabled, "webhooks.enabled requires outbox.enabled")
+		check(c.Webhooks.PollInterval > 0, "webhooks.poll_interval must be positive")
+		check(c.Webhooks.BatchSize > 0, "webhooks.batch_size must be positive")
+		check(c.Webhooks.Timeout > 0, "webhooks.timeout must be positive")
+		check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts must be positive")
+		check(c.Webhooks.Backoff > 0, "webhooks.backoff must be positive")
+		check(c.Webhooks.MaxBackoff >= c.Webhooks.Backoff, "webhooks.max_backoff must not be less than backoff")
+		check(c.Webhooks.FailureThreshold > 0, "webhooks.failure_threshold must be positive")
+		check(c.Webhooks.Cooldown > 0, "webhooks.cooldown must be positive")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalid, errors.Join(errs...))
+	}
+	return nil
+}
+
+// Duration принимает в файле и окружении строки вида "1s" или "1h30m".
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+const testYAML = `
+log:
+  level: debug
+server:
+  address: ":9000"
+  rate_limit:
+    rps: 10
+    burst: 20
+postgres:
+  url: postgres://file
+  query_timeout: 2s
+nats:
+  url: nats://file
+  cluster_id: cluster
+  channel: orders
+`
+
+const testTOML = `
+[log]
+level = "debug"
+
+[server]
+address = ":9000"
+
+[server.rate_limit]
+rps = 10.0
+burst = 20
+
+[postgres]
+url = "postgres://file"
+query_timeout = "2s"
+
+[nats]
+url = "nats://file"
+cluster_id = "cluster"
+channel = "orders"
+`
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	for name, content := range map[string]string{"config.yaml": testYAML, "config.toml": testTOML} {
+		t.Run(name, func(t *testing.T) {
+			path := writeFile(t, name, content)
+			t.Setenv("POSTGRES_URL", "postgres://env")
+			t.Setenv("NATS_URL", "nats://env")
+
+			loader, err := NewLoader("test", []string{"-config", path, "-nats.url=nats://flag", "-nats.queue_depth", "7"})
+			require.NoError(t, err)
+
+			cfg, err := loader.Load()
+			require.NoError(t, err)
+
+			want := Default()
+			want.Log.Level = "debug"
+			want.Server.Address = ":9000"
+			want.Server.RateLimit = RateLimit{RPS: 10, Burst: 20}
+			want.Postgres.URL = "postgres://env"
+			want.Postgres.QueryTimeout = Duration(2 * time.Second)
+			want.NATS.URL = "nats://flag"
+			want.NATS.ClusterID = "cluster"
+			want.NATS.Channel = "orders"
+			want.NATS.QueueDepth = 7
+			require.Equal(t, want, cfg)
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		file    string
+		content string
+		args    []string
+	}{
+		{name: "unknown_key", file: "c.yaml", content: testYAML + "extra: 1\n"},
+		{name: "bad_duration", file: "c.yaml", content: testYAML + "analytics:\n  live_window: week\n"},
+		{name: "extension", file: "c.json", content: "{}"},
+		{name: "invalid", file: "c.yaml", content: testYAML, args: []string{"-log.level=loud"}},
+		{name: "required", file: "c.toml", content: "[log]\nlevel = \"info\"\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			loader, err := NewLoader("test", append([]string{"-config", writeFile(t, tc.file, tc.content)}, tc.args...))
+			require.NoError(t, err)
+
+			_, err = loader.Load()
+			require.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+
+	_, err := NewLoader("test", []string{"-nats.queue_depth=many"})
+	require.Error(t, err)
+}
+
+func TestReload(t *testing.T) {
+	path := writeFile(t, "config.yaml", testYAML)
+	loader, err := NewLoader("test", []string{"-config", path})
+	require.NoError(t, err)
+
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+
+	var applied []Reloadable
+	r := NewReloader(cfg, ReloaderDependencies{
+		Log:    logrus.New(),
+		Loader: loader,
+		Apply:  func(c Reloadable) { applied = append(applied, c) },
+	})
+
+	// Адрес требует перезапуска и не меняется, уровень логов применяется
+	edited := `
+log:
+  level: warn
+server:
+  address: ":9001"
+  rate_limit:
+    rps: 5
+    burst: 5
+postgres:
+  url: postgres://file
+nats:
+  url: nats://file
+  cluster_id: cluster
+  channel: orders
+`
+	require.NoError(t, os.WriteFile(path, []byte(edited), 0o600))
+	require.NoError(t, r.Reload())
+	require.Equal(t, []Reloadable{{Log: Log{Level: "warn"}, RateLimit: RateLimit{RPS: 5, Burst: 5}}}, applied)
+	require.Equal(t, ":9000", r.current.Server.Address)
+
+	next, err := loader.Load()
+	require.NoError(t, err)
+	require.Equal(t, []string{"postgres.query_timeout", "server.address"},
+		changed(cfg, next.withReloadable(cfg.Reloadable())))
+
+	// Ошибочная конфигурация не применяется
+	require.NoError(t, os.WriteFile(path, []byte("log:\n  level: loud\n"), 0o600))
+	require.ErrorIs(t, r.Reload(), ErrInvalid)
+	require.Len(t, applied, 1)
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Loader запоминает путь к файлу и флаги командной строки, чтобы при
+// перезагрузке применить их поверх нового содержимого файла.
+type Loader struct {
+	path      string
+	overrides map[string]string
+}
+
+// NewLoader разбирает аргументы командной строки. Флаг -config задает
+// файл, по умолчанию берется переменная CONFIG_FILE. Остальные флаги
+// соответствуют ключам файла.
+func NewLoader(name string, args []string) (*Loader, error) {
+	l := &Loader{overrides: make(map[string]string)}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&l.path, "config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file")
+
+	defaults := Default()
+	for _, f := range fields(&defaults) {
+		f := f
+		fs.Func(f.key, fmt.Sprintf("%s (default %q)", f.key, f.String()), func(s string) error {
+			// Проверяем значение сразу, чтобы ошибка указывала на флаг
+			if err := f.Set(s); err != nil {
+				return err
+			}
+			l.overrides[f.key] = s
+			return nil
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *Loader) Path() string {
+	return l.path
+}
+
+// Load собирает конфигурацию: значения по умолчанию, файл, окружение,
+// флаги. Результат проверяется Validate.
+func (l *Loader) Load() (Config, error) {
+	cfg := Default()
+
+	if l.path != "" {
+		if err := decodeFile(l.path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, f := range fields(&cfg) {
+		v, ok := os.LookupEnv(f.env)
+		if f.env == "" || !ok {
+			continue
+		}
+
+		if err := f.Set(v); err != nil {
+			return Config{}, fmt.Errorf("%w: %s: %w", ErrInvalid, f.env, err)
+		}
+	}
+
+	for _, f := range fields(&cfg) {
+		if v, ok := l.overrides[f.key]; ok {
+			if err := f.Set(v); err != nil {
+				return Config{}, fmt.Errorf("%w: -%s: %w", ErrInvalid, f.key, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func decodeFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	default:
+		return fmt.Errorf("%w: unsupported config file extension %q", ErrInvalid, ext)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalid, path, err)
+	}
+	return nil
+}
+
+// field - лист структуры Config, доступный по ключу вида server.address.
+type field struct {
+	key string
+	env string
+	v   reflect.Value
+}
+
+func fields(cfg *Config) []field {
+	var ret []field
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			key := prefix + sf.Tag.Get("yaml")
+			fv := v.Field(i)
+
+			if sf.Type.Kind() == reflect.Struct {
+				walk(key+".", fv)
+				continue
+			}
+
+			ret = append(ret, field{key: key, env: sf.Tag.Get("env"), v: fv})
+		}
+	}
+	walk("", reflect.ValueOf(cfg).Elem())
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].key < ret[j].key })
+	return ret
+}
+
+func (f field) Set(s string) error {
+	if u, ok := f.v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch f.v.Kind() {
+	case reflect.String:
+		f.v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.v.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, f.v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		f.v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", f.v.Type())
+	}
+
+	return nil
+}
+
+func (f field) String() string {
+	if m, ok := f.v.Interface().(encoding.TextMarshaler); ok {
+		text, _ := m.MarshalText()
+		return string(text)
+	}
+
+	return fmt.Sprint(f.v.Interface())
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Reloadable - настройки, которые безопасно менять без перезапуска.
+// Размера кеша среди них нет: кеш хранит все заказы и отвечает на
+// GetOrder и ListOrders без обращения к persistent хранилищу, поэтому
+// ограничение его размера - отдельная задача, а не перезагрузка.
+type Reloadable struct {
+	Log       Log
+	RateLimit RateLimit
+}
+
+func (c *Config) Reloadable() Reloadable {
+	return Reloadable{Log: c.Log, RateLimit: c.Server.RateLimit}
+}
+
+// withReloadable возвращает копию c с перезагружаемыми настройками из r.
+func (c Config) withReloadable(r Reloadable) Config {
+	c.Log = r.Log
+	c.Server.RateLimit = r.RateLimit
+	return c
+}
+
+type ReloaderDependencies struct {
+	Log    *logrus.Logger
+	Loader *Loader
+	// Apply вызывается после успешной перезагрузки
+	Apply func(Reloadable)
+}
+
+// Reloader перечитывает конфигурацию по SIGHUP. Изменения, требующие
+// перезапуска, игнорируются с предупреждением, ошибочная конфигурация
+// не применяется.
+type Reloader struct {
+	deps ReloaderDependencies
+	log  *logrus.Entry
+
+	mu      sync.Mutex
+	current Config
+}
+
+func NewReloader(current Config, deps ReloaderDependencies) *Reloader {
+	return &Reloader{
+		deps:    deps,
+		log:     deps.Log.WithField("component", "config"),
+		current: current,
+	}
+}
+
+// Run ждет SIGHUP до отмены ctx.
+func (r *Reloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := r.Reload(); err != nil {
+				r.log.Errorf("reload failed, keeping current config: %v", err)
+			}
+		}
+	}
+}
+
+func (r *Reloader) Reload() error {
+	next, err := r.deps.Loader.Load()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range changed(r.current, next.withReloadable(r.current.Reloadable())) {
+		r.log.Warnf("%s changed, restart required to apply", key)
+	}
+
+	r.current = r.current.withReloadable(next.Reloadable())
+	r.deps.Apply(r.current.Reloadable())
+	r.log.Info("config reloaded")
+	return nil
+}
+
+// changed возвращает ключи, значения которых различаются в a и b.
+func changed(a, b Config) []string {
+	var keys []string
+	fb := fields(&b)
+	for i, f := range fields(&a) {
+		if !reflect.DeepEqual(f.v.Interface(), fb[i].v.Interface()) {
+			keys = append(keys, f.key)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,192 @@
+package currency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"orderservice/internal/schema"
+	"os"
+)
+
+var ErrNoRate = errors.New("no exchange rate")
+
+// RatesProvider returns how many units of to one unit of from costs.
+type RatesProvider interface {
+	Rate(ctx context.Context, from, to string) (*big.Rat, error)
+}
+
+// StaticRates are exchange rates relative to a base currency loaded
+// from a file, for example {"base":"USD","rates":{"EUR":"0.92"}}.
+type StaticRates struct {
+	Base  string            `json:"base"`
+	Rates map[string]string `json:"rates"`
+
+	parsed map[string]*big.Rat
+}
+
+func LoadStaticRates(path string) (*StaticRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := &StaticRates{}
+	if err := json.Unmarshal(data, rates); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := rates.parse(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return rates, nil
+}
+
+func NewStaticRates(base string, rates map[string]string) (*StaticRates, error) {
+	r := &StaticRates{Base: base, Rates: rates}
+	if err := r.parse(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (s *StaticRates) parse() error {
+	if _, err := schema.MinorUnits(s.Base); err != nil {
+		return err
+	}
+
+	s.parsed = map[string]*big.Rat{s.Base: big.NewRat(1, 1)}
+	for currency, value := range s.Rates {
+		if _, err := schema.MinorUnits(currency); err != nil {
+			return err
+		}
+
+		rate, ok := new(big.Rat).SetString(value)
+		if !ok || rate.Sign() <= 0 {
+			return fmt.Errorf("invalid %s rate %q", currency, value)
+		}
+
+		s.parsed[currency] = rate
+	}
+
+	return nil
+}
+
+func (s *StaticRates) Rate(_ context.Context, from, to string) (*big.Rat, error) {
+	fromRate, ok := s.parsed[from]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoRate, from)
+	}
+
+	toRate, ok := s.parsed[to]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoRate, to)
+	}
+
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
+
+type Converter struct {
+	rates RatesProvider
+}
+
+func NewConverter(rates RatesProvider) *Converter {
+	return &Converter{rates: rates}
+}
+
+// Convert converts m to currency to, rounding half to even to the
+// minor units of the target currency.
+func (c *Converter) Convert(ctx context.Context, m schema.Money, to string) (schema.Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+
+	fromUnits, err := schema.MinorUnits(m.Currency)
+	if err != nil {
+		return schema.Money{}, err
+	}
+
+	toUnits, err := schema.MinorUnits(to)
+	if err != nil {
+		return schema.Money{}, err
+	}
+
+	rate, err := c.rates.Rate(ctx, m.Currency, to)
+	if err != nil {
+		return schema.Money{}, err
+	}
+
+	v := new(big.Rat).SetInt64(m.Minor)
+	v.Mul(v, rate)
+	v.Mul(v, scale(toUnits-fromUnits))
+
+	minor, err := roundHalfEven(v)
+	if err != nil {
+		return schema.Money{}, err
+	}
+
+	return schema.NewMoney(minor, to)
+}
+
+// Sum converts amounts to currency to and adds them up.
+func (c *Converter) Sum(ctx context.Context, amounts []schema.Money, to string) (schema.Money, error) {
+	total, err := schema.NewMoney(0, to)
+	if err != nil {
+		return schema.Money{}, err
+	}
+
+	for _, m := range amounts {
+		converted, err := c.Convert(ctx, m, to)
+		if err != nil {
+			return schema.Money{}, err
+		}
+
+		if total, err = total.Add(converted); err != nil {
+			return schema.Money{}, err
+		}
+	}
+
+	return total, nil
+}
+
+func scale(exp int) *big.Rat {
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(exp))), nil)
+	if exp < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), p)
+	}
+
+	return new(big.Rat).SetInt(p)
+}
+
+func roundHalfEven(v *big.Rat) (int64, error) {
+	num, den := v.Num(), v.Denom()
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+
+	// Сравниваем удвоенный остаток с делителем
+	r2 := new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2))
+	switch cmp := r2.Cmp(den); {
+	case cmp > 0, cmp == 0 && q.Bit(0) == 1:
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+
+	if !q.IsInt64() {
+		return 0, schema.ErrMoneyOverflow
+	}
+
+	return q.Int64(), nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
@@ -0,0 +1,38 @@
+package currency
+
+import (
+	"context"
+	"orderservice/internal/schema"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	rates, err := NewStaticRates("USD", map[string]string{
+		"EUR": "0.9",
+		"JPY": "150",
+	})
+	require.NoError(t, err)
+	conv := NewConverter(rates)
+	ctx := context.Background()
+
+	m, err := conv.Convert(ctx, schema.Money{Minor: 1000, Currency: "USD"}, "JPY")
+	require.NoError(t, err)
+	require.Equal(t, schema.Money{Minor: 1500, Currency: "JPY"}, m)
+
+	// 0.05 USD = 0.045 EUR, округляется к четному
+	m, err = conv.Convert(ctx, schema.Money{Minor: 5, Currency: "USD"}, "EUR")
+	require.NoError(t, err)
+	require.Equal(t, int64(4), m.Minor)
+
+	sum, err := conv.Sum(ctx, []schema.Money{
+		{Minor: 1000, Currency: "USD"},
+		{Minor: 900, Currency: "EUR"},
+	}, "USD")
+	require.NoError(t, err)
+	require.Equal(t, schema.Money{Minor: 2000, Currency: "USD"}, sum)
+
+	_, err = conv.Convert(ctx, schema.Money{Minor: 1, Currency: "RUB"}, "USD")
+	require.ErrorIs(t, err, ErrNoRate)
+}
@@ -0,0 +1,188 @@
+// Package leader выбирает одну реплику для фоновых задач, которые не
+// должны выполняться параллельно.
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRenewInterval = 5 * time.Second
+	defaultRetryInterval = 5 * time.Second
+)
+
+// ErrLeaseLost означает, что лидерство перешло к другой реплике или
+// блокировка потеряна вместе с соединением.
+var ErrLeaseLost = errors.New("leader lease lost")
+
+// Lock - распределенная блокировка. Каждый захват выдает fencing token
+// больше всех предыдущих.
+type Lock interface {
+	// TryAcquire возвращает ok=false, если блокировку держит другая реплика
+	TryAcquire(ctx context.Context) (token int64, ok bool, err error)
+	// Renew подтверждает, что блокировка с token еще удерживается,
+	// иначе возвращает ErrLeaseLost
+	Renew(ctx context.Context, token int64) error
+	Release(ctx context.Context)
+	// Current возвращает token последнего захвата
+	Current(ctx context.Context) (int64, error)
+}
+
+// Lease передается задачам лидера. Перед записью, которую нельзя
+// выполнять двум репликам, задача проверяет Check: после паузы процесса
+// лидером могла стать другая реплика.
+type Lease struct {
+	Token int64
+	lock  Lock
+}
+
+func (l Lease) Check(ctx context.Context) error {
+	current, err := l.lock.Current(ctx)
+	if err != nil {
+		return err
+	}
+
+	if current != l.Token {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+type Config struct {
+	// RenewInterval - период проверки блокировки, после ее потери задачи
+	// останавливаются не позже чем через этот интервал
+	RenewInterval time.Duration
+	// RetryInterval - период попыток захвата блокировки
+	RetryInterval time.Duration
+}
+
+type Callbacks struct {
+	// OnStarted запускается в отдельной горутине после получения
+	// лидерства. ctx отменяется при его потере или остановке Run.
+	OnStarted func(ctx context.Context, lease Lease)
+	// OnStopped вызывается после завершения OnStarted
+	OnStopped func()
+}
+
+type Dependencies struct {
+	Log  *logrus.Logger
+	Lock Lock
+}
+
+type Elector struct {
+	cfg  Config
+	deps Dependencies
+
+	mu    sync.Mutex
+	token int64
+
+	log *logrus.Entry
+}
+
+func New(cfg Config, deps Dependencies) *Elector {
+	if cfg.RenewInterval == 0 {
+		cfg.RenewInterval = defaultRenewInterval
+	}
+	if cfg.RetryInterval == 0 {
+		cfg.RetryInterval = defaultRetryInterval
+	}
+
+	return &Elector{
+		cfg:  cfg,
+		deps: deps,
+		log:  deps.Log.WithField("component", "leader"),
+	}
+}
+
+// Leader возвращает token текущего лидерства этой реплики.
+func (e *Elector) Leader() (token int64, ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.token, e.token != 0
+}
+
+func (e *Elector) setToken(token int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.token = token
+}
+
+// Run пытается стать лидером до отмены ctx. Лидерство отдается при
+// остановке или потере блокировки, после чего попытки продолжаются.
+func (e *Elector) Run(ctx context.Context, cb Callbacks) {
+	for {
+		token, ok, err := e.deps.Lock.TryAcquire(ctx)
+		switch {
+		case err != nil:
+			e.log.Errorf("failed to acquire leader lock: %v", err)
+		case ok:
+			e.lead(ctx, Lease{Token: token, lock: e.deps.Lock}, cb)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.cfg.RetryInterval):
+		}
+	}
+}
+
+func (e *Elector) lead(ctx context.Context, lease Lease, cb Callbacks) {
+	e.log.Infof("became leader with token %d", lease.Token)
+	e.setToken(lease.Token)
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if cb.OnStarted != nil {
+			cb.OnStarted(leaderCtx, lease)
+		}
+	}()
+
+	e.renew(leaderCtx, lease)
+
+	// Задачи останавливаются до освобождения блокировки, чтобы новый
+	// лидер не начал работу параллельно с ними
+	cancel()
+	<-done
+	e.setToken(0)
+	if cb.OnStopped != nil {
+		cb.OnStopped()
+	}
+
+	// Контекст Run может быть уже отменен, а блокировку нужно отпустить
+	releaseCtx, cancelRelease := context.WithTimeout(context.Background(), e.cfg.RenewInterval)
+	defer cancelRelease()
+	e.deps.Lock.Release(releaseCtx)
+	e.log.Infof("leadership with token %d finished", lease.Token)
+}
+
+// renew продлевает блокировку до отмены ctx или ее потери.
+func (e *Elector) renew(ctx context.Context, lease Lease) {
+	ticker := time.NewTicker(e.cfg.RenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		renewCtx, cancel := context.WithTimeout(ctx, e.cfg.RenewInterval)
+		err := e.deps.Lock.Renew(renewCtx, lease.Token)
+		cancel()
+		if err != nil {
+			e.log.Errorf("lost leadership: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package leader
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// memLock - Lock в памяти, held отражает состояние блокировки
+type memLock struct {
+	mu      sync.Mutex
+	held    bool
+	busy    bool
+	token   int64
+	renewOK bool
+}
+
+func (l *memLock) TryAcquire(context.Context) (int64, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.held || l.busy {
+		return 0, false, nil
+	}
+
+	l.held = true
+	l.renewOK = true
+	l.token++
+	return l.token, true, nil
+}
+
+func (l *memLock) Renew(_ context.Context, token int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.renewOK || token != l.token {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (l *memLock) Release(context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.held = false
+}
+
+func (l *memLock) Current(context.Context) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.token, nil
+}
+
+func (l *memLock) set(fn func(l *memLock)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fn(l)
+}
+
+func TestElector(t *testing.T) {
+	lock := &memLock{busy: true}
+	e := New(Config{RenewInterval: time.Millisecond, RetryInterval: time.Millisecond},
+		Dependencies{Log: logrus.New(), Lock: lock})
+
+	started := make(chan Lease)
+	stopped := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx, Callbacks{
+			OnStarted: func(ctx context.Context, lease Lease) {
+				started <- lease
+				<-ctx.Done()
+			},
+			OnStopped: func() { stopped <- struct{}{} },
+		})
+	}()
+
+	// Пока блокировку держит другая реплика, задачи не запускаются
+	select {
+	case <-started:
+		t.Fatal("started while the lock is busy")
+	case <-time.After(20 * time.Millisecond):
+	}
+	_, ok := e.Leader()
+	require.False(t, ok)
+
+	lock.set(func(l *memLock) { l.busy = false })
+	lease := <-started
+	require.Equal(t, int64(1), lease.Token)
+	require.NoError(t, lease.Check(ctx))
+
+	// Потеря блокировки останавливает задачи до ее освобождения,
+	// следующий захват выдает больший token
+	lock.set(func(l *memLock) { l.renewOK = false })
+	<-stopped
+	next := <-started
+	require.Equal(t, int64(2), next.Token)
+	require.ErrorIs(t, lease.Check(ctx), ErrLeaseLost)
+
+	token, ok := e.Leader()
+	require.True(t, ok)
+	require.Equal(t, int64(2), token)
+
+	cancel()
+	<-stopped
+	<-done
+	lock.set(func(l *memLock) { require.False(t, l.held) })
+	_, ok = e.Leader()
+	require.False(t, ok)
+}
@@ -0,0 +1,55 @@
+package leader
+
+import (
+	"context"
+	"sync"
+)
+
+// LocalLock - Lock внутри одного процесса, для развертываний с одной
+// репликой, например со встроенным хранилищем orderbolt.
+type LocalLock struct {
+	mu    sync.Mutex
+	held  bool
+	token int64
+}
+
+func NewLocalLock() *LocalLock {
+	return &LocalLock{}
+}
+
+func (l *LocalLock) TryAcquire(context.Context) (int64, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.held {
+		return 0, false, nil
+	}
+
+	l.held = true
+	l.token++
+	return l.token, true, nil
+}
+
+func (l *LocalLock) Renew(_ context.Context, token int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.held || token != l.token {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (l *LocalLock) Release(context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.held = false
+}
+
+func (l *LocalLock) Current(context.Context) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.token, nil
+}
@@ -0,0 +1,134 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"orderservice/internal/provider/pgxprovider"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type PostgresConfig struct {
+	// Name отличает независимые выборы, по нему вычисляется ключ
+	// advisory блокировки
+	Name string
+	// Holder записывается в leader_leases для диагностики
+	Holder string
+}
+
+type PostgresDependencies struct {
+	PGX *pgxprovider.PGXProvider
+}
+
+// PostgresLock - Lock на session-level advisory блокировке. Блокировка
+// живет, пока открыто соединение, поэтому оно забирается из пула на все
+// время лидерства. Fencing token хранится в таблице leader_leases.
+type PostgresLock struct {
+	cfg  PostgresConfig
+	deps PostgresDependencies
+	key  int64
+
+	mu   sync.Mutex
+	conn *pgx.Conn
+}
+
+func NewPostgresLock(cfg PostgresConfig, deps PostgresDependencies) *PostgresLock {
+	h := fnv.New64a()
+	h.Write([]byte(cfg.Name))
+
+	return &PostgresLock{
+		cfg:  cfg,
+		deps: deps,
+		key:  int64(h.Sum64()),
+	}
+}
+
+func (l *PostgresLock) TryAcquire(ctx context.Context) (int64, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		return 0, false, errors.New("lock is already held")
+	}
+
+	pooled, err := l.deps.PGX.Acquire(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var ok bool
+	if err := pooled.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, l.key).Scan(&ok); err != nil || !ok {
+		pooled.Release()
+		return 0, false, err
+	}
+
+	// Соединение больше не возвращается в пул: его закрытие снимает блокировку
+	conn := pooled.Hijack()
+
+	var token int64
+	err = conn.QueryRow(ctx, `
+		INSERT INTO leader_leases (name, token, holder, acquired_at, renewed_at)
+		VALUES ($1, 1, $2, now(), now())
+		ON CONFLICT (name) DO UPDATE SET
+			token = leader_leases.token + 1,
+			holder = EXCLUDED.holder,
+			acquired_at = now(),
+			renewed_at = now()
+		RETURNING token`, l.cfg.Name, l.cfg.Holder).Scan(&token)
+	if err != nil {
+		conn.Close(ctx)
+		return 0, false, err
+	}
+
+	l.conn = conn
+	return token, true, nil
+}
+
+func (l *PostgresLock) Renew(ctx context.Context, token int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return ErrLeaseLost
+	}
+
+	tag, err := l.conn.Exec(ctx,
+		`UPDATE leader_leases SET renewed_at = now() WHERE name = $1 AND token = $2`,
+		l.cfg.Name, token)
+	if err != nil {
+		// Без соединения блокировка уже могла быть снята сервером
+		return fmt.Errorf("%w: %w", ErrLeaseLost, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (l *PostgresLock) Release(ctx context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return
+	}
+
+	// Закрытие сессии снимает все ее advisory блокировки
+	l.conn.Close(ctx)
+	l.conn = nil
+}
+
+func (l *PostgresLock) Current(ctx context.Context) (int64, error) {
+	var token int64
+	err := l.deps.PGX.QueryRow(ctx,
+		`SELECT token FROM leader_leases WHERE name = $1`, l.cfg.Name).Scan(&token)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+
+	return token, err
+}
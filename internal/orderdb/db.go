@@ -13,9 +13,98 @@ type OrderDB interface {
 	SeqNumber(ctx context.Context) (schema.SeqNumber, error)
 	AddOrder(ctx context.Context, order schema.Order, seq schema.SeqNumber) error
 	GetOrder(ctx context.Context, orderUI schema.OrderUID) (schema.Order, error)
-	ListOrders(ctx context.Context) ([]schema.Order, error)
+	ListOrders(ctx context.Context, filter Filter) ([]schema.Order, error)
 }
 
 type RestorableOrderDB interface {
 	Restore(ctx context.Context) error
 }
+
+// AdminOrderDB is implemented by stores that support maintenance operations.
+type AdminOrderDB interface {
+	DeleteOrder(ctx context.Context, orderUID schema.OrderUID) error
+	AnonymizeOrder(ctx context.Context, orderUID schema.OrderUID) error
+	SetSeqNumber(ctx context.Context, seq schema.SeqNumber) error
+}
+
+// StreamingOrderDB is implemented by stores that can iterate over large
+// result sets without loading them into memory.
+type StreamingOrderDB interface {
+	StreamOrders(ctx context.Context, filter Filter, fn func(schema.Order) error) error
+}
+
+type SearchResult struct {
+	Order schema.Order `json:"order"`
+	Rank  float64      `json:"rank"`
+}
+
+// Searcher finds orders by customer name, phone, email, item name or
+// brand. Results are ordered by descending rank.
+type Searcher interface {
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+}
+
+// Key is an order attribute with a secondary index.
+type Key string
+
+const (
+	KeyTrackNumber Key = "track_number"
+	KeyTransaction Key = "transaction"
+	KeyRID         Key = "rid"
+	KeyCustomerID  Key = "customer_id"
+)
+
+// IndexedOrderDB looks orders up by secondary keys. ErrNotFound is
+// returned when no order matches.
+type IndexedOrderDB interface {
+	FindOrders(ctx context.Context, key Key, value string) ([]schema.Order, error)
+}
+
+// OrderObserver is notified about changes of the orders held by a store.
+type OrderObserver interface {
+	OrderStored(order schema.Order)
+	OrderRemoved(orderUID schema.OrderUID)
+}
+
+// Rejecter records an order that failed decoding or validation together
+// with its message sequence, so that the message can be acknowledged and
+// the rejection reported downstream.
+type Rejecter interface {
+	RejectOrder(ctx context.Context, orderUID schema.OrderUID, reason string, seq schema.SeqNumber) error
+}
+
+// OrderChange describes an order written or removed by a replica.
+type OrderChange struct {
+	OrderUID schema.OrderUID  `json:"order_uid"`
+	Removed  bool             `json:"removed,omitempty"`
+	Seq      schema.SeqNumber `json:"seq,omitempty"`
+}
+
+// ChangeNotifier tells other replicas sharing the persistent store about
+// changes made by this one.
+type ChangeNotifier interface {
+	NotifyChange(ctx context.Context, change OrderChange) error
+}
+
+// ChangeApplier applies changes made by other replicas.
+type ChangeApplier interface {
+	ApplyChange(ctx context.Context, change OrderChange) error
+}
+
+// VersionCount is the number of stored orders in a schema version.
+type VersionCount struct {
+	Version int `json:"version"`
+	Orders  int `json:"orders"`
+}
+
+// VersionReporter is implemented by stores that keep the schema version
+// of every stored order.
+type VersionReporter interface {
+	SchemaVersions(ctx context.Context) ([]VersionCount, error)
+}
+
+// Pinger checks that the store is reachable. Consumers use it to decide
+// when to resume after store failures.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
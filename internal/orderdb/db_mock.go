@@ -69,18 +69,18 @@ func (mr *MockOrderDBMockRecorder) GetOrder(arg0, arg1 any) *gomock.Call {
 }
 
 // ListOrders mocks base method.
-func (m *MockOrderDB) ListOrders(arg0 context.Context) ([]schema.Order, error) {
+func (m *MockOrderDB) ListOrders(arg0 context.Context, arg1 Filter) ([]schema.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListOrders", arg0)
+	ret := m.ctrl.Call(m, "ListOrders", arg0, arg1)
 	ret0, _ := ret[0].([]schema.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListOrders indicates an expected call of ListOrders.
-func (mr *MockOrderDBMockRecorder) ListOrders(arg0 any) *gomock.Call {
+func (mr *MockOrderDBMockRecorder) ListOrders(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrders", reflect.TypeOf((*MockOrderDB)(nil).ListOrders), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrders", reflect.TypeOf((*MockOrderDB)(nil).ListOrders), arg0, arg1)
 }
 
 // SeqNumber mocks base method.
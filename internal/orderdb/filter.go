@@ -0,0 +1,60 @@
+package orderdb
+
+import (
+	"orderservice/internal/schema"
+	"time"
+)
+
+// Filter restricts the orders returned by ListOrders. Empty fields match
+// any value, zero Limit means no limit. Filtered results are ordered by
+// order_uid so that Offset and Limit can be used for paging.
+type Filter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+	Currency        string
+	Locale          string
+
+	// CreatedFrom and CreatedTo select orders created in
+	// [CreatedFrom, CreatedTo), zero values leave the range open
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+
+	Limit  int
+	Offset int
+}
+
+func (f Filter) Match(order schema.Order) bool {
+	return match(f.CustomerID, order.CustomerID) &&
+		match(f.TrackNumber, order.TrackNumber) &&
+		match(f.DeliveryService, order.DeliveryService) &&
+		match(f.Currency, order.Payment.Currency) &&
+		match(f.Locale, order.Locale) &&
+		f.matchCreated(order.DateCreated.Time)
+}
+
+func (f Filter) matchCreated(created time.Time) bool {
+	if !f.CreatedFrom.IsZero() && (created.IsZero() || created.Before(f.CreatedFrom)) {
+		return false
+	}
+
+	return f.CreatedTo.IsZero() || (!created.IsZero() && created.Before(f.CreatedTo))
+}
+
+// Page applies Offset and Limit to already filtered and sorted orders.
+func (f Filter) Page(orders []schema.Order) []schema.Order {
+	if f.Offset >= len(orders) {
+		return orders[:0]
+	}
+
+	orders = orders[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(orders) {
+		orders = orders[:f.Limit]
+	}
+
+	return orders
+}
+
+func match(want, got string) bool {
+	return want == "" || want == got
+}
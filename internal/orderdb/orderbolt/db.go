@@ -0,0 +1,449 @@
+// Package orderbolt хранит заказы во встроенной базе bbolt в одном файле.
+// Подходит для демонстраций, тестов и edge развертываний с одной
+// репликой: файл блокируется открывшим его процессом.
+package orderbolt
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/schema"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const defaultOpenTimeout = 5 * time.Second
+
+var (
+	ordersBucket = []byte("orders")
+	metaBucket   = []byte("meta")
+	seqKey       = []byte("seq")
+	// processedBucket - номера обработанных сообщений выше seq в meta
+	processedBucket = []byte("processed")
+)
+
+type Config struct {
+	Path string
+	// OpenTimeout ограничивает ожидание блокировки файла, занятого другим
+	// процессом
+	OpenTimeout time.Duration
+}
+
+type Dependencies struct {
+	Log *logrus.Logger
+	// Registry decodes orders stored in older schema versions,
+	// lenient by default
+	Registry *schema.Registry
+}
+
+type Bolt struct {
+	cfg  Config
+	deps Dependencies
+
+	db  *bolt.DB
+	log *logrus.Entry
+}
+
+// record - значение в bucket orders
+type record struct {
+	Version int             `json:"schema_version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// New открывает или создает файл базы. Файл нужно закрыть через Close.
+func New(cfg Config, deps Dependencies) (*Bolt, error) {
+	if cfg.OpenTimeout == 0 {
+		cfg.OpenTimeout = defaultOpenTimeout
+	}
+	if deps.Registry == nil {
+		deps.Registry = schema.NewRegistry(schema.Lenient)
+	}
+
+	db, err := bolt.Open(cfg.Path, 0o600, &bolt.Options{Timeout: cfg.OpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", cfg.Path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{ordersBucket, metaBucket, processedBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create buckets: %w", err)
+	}
+
+	return &Bolt{
+		cfg:  cfg,
+		deps: deps,
+		db:   db,
+		log:  deps.Log.WithField("component", "orderdb"),
+	}, nil
+}
+
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+func (b *Bolt) SeqNumber(_ context.Context) (schema.SeqNumber, error) {
+	var seq schema.SeqNumber
+	err := b.db.View(func(tx *bolt.Tx) error {
+		seq = loadSeq(tx)
+		return nil
+	})
+
+	return seq, err
+}
+
+// AddOrder записывает заказ и номер сообщения в одной транзакции.
+// Повторная публикация заказа перезаписывает сохраненные данные.
+func (b *Bolt) AddOrder(_ context.Context, order schema.Order, seq schema.SeqNumber) error {
+	value, err := encode(order)
+	if err != nil {
+		return err
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		// Повторно доставленное сообщение не перезаписывает заказ данными,
+		// устаревшими после следующих сообщений
+		if processed(tx, seq) {
+			return orderdb.ErrAlreadyProcessed
+		}
+
+		if err := tx.Bucket(ordersBucket).Put([]byte(order.OrderUID), value); err != nil {
+			return err
+		}
+		return markProcessed(tx, seq)
+	})
+	if errors.Is(err, orderdb.ErrAlreadyProcessed) {
+		b.log.Infof("message %d is already processed", seq)
+		return err
+	} else if err != nil {
+		b.log.Errorf("failed to add order: %v", err)
+		return err
+	}
+
+	b.log.Infof("order added: %s", order.OrderUID)
+	return nil
+}
+
+// RejectOrder сохраняет только номер сообщения с некорректным заказом.
+func (b *Bolt) RejectOrder(_ context.Context, orderUID schema.OrderUID, reason string, seq schema.SeqNumber) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if processed(tx, seq) {
+			return orderdb.ErrAlreadyProcessed
+		}
+		return markProcessed(tx, seq)
+	})
+	if errors.Is(err, orderdb.ErrAlreadyProcessed) {
+		b.log.Infof("message %d is already processed", seq)
+		return err
+	} else if err != nil {
+		b.log.Errorf("failed to save seq number: %v", err)
+		return err
+	}
+
+	b.log.Infof("order rejected: %q: %s", orderUID, reason)
+	return nil
+}
+
+func (b *Bolt) GetOrder(_ context.Context, orderUID schema.OrderUID) (schema.Order, error) {
+	var (
+		order schema.Order
+		err   error
+	)
+	viewErr := b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(ordersBucket).Get([]byte(orderUID))
+		if value == nil {
+			err = orderdb.ErrNotFound
+			return nil
+		}
+
+		order, err = b.decode(orderUID, value)
+		return nil
+	})
+	if viewErr != nil {
+		return schema.Order{}, viewErr
+	}
+
+	return order, err
+}
+
+func (b *Bolt) ListOrders(ctx context.Context, filter orderdb.Filter) ([]schema.Order, error) {
+	ret := make([]schema.Order, 0)
+	err := b.StreamOrders(ctx, filter, func(order schema.Order) error {
+		ret = append(ret, order)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// StreamOrders передает заказы в fn в порядке order_uid. Фильтр
+// применяется к каждому заказу, индексов по полям нет. fn вызывается
+// внутри читающей транзакции и не должен писать в эту же базу.
+func (b *Bolt) StreamOrders(ctx context.Context, filter orderdb.Filter, fn func(schema.Order) error) error {
+	skipped, sent := 0, 0
+	return b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(ordersBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			order, err := b.decode(schema.OrderUID(k), v)
+			if err != nil {
+				return err
+			}
+
+			if !filter.Match(order) {
+				continue
+			}
+			if skipped < filter.Offset {
+				skipped++
+				continue
+			}
+
+			if err := fn(order); err != nil {
+				return err
+			}
+
+			sent++
+			if filter.Limit > 0 && sent == filter.Limit {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+func (b *Bolt) DeleteOrder(_ context.Context, orderUID schema.OrderUID) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(ordersBucket)
+		if bucket.Get([]byte(orderUID)) == nil {
+			return orderdb.ErrNotFound
+		}
+		return bucket.Delete([]byte(orderUID))
+	})
+	if err != nil {
+		return err
+	}
+
+	b.log.Infof("order deleted: %s", orderUID)
+	return nil
+}
+
+func (b *Bolt) AnonymizeOrder(_ context.Context, orderUID schema.OrderUID) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(ordersBucket)
+		value := bucket.Get([]byte(orderUID))
+		if value == nil {
+			return orderdb.ErrNotFound
+		}
+
+		order, err := b.decode(orderUID, value)
+		if err != nil {
+			return err
+		}
+
+		value, err = encode(order.Anonymized())
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(orderUID), value)
+	})
+	if err != nil {
+		return err
+	}
+
+	b.log.Infof("order anonymized: %s", orderUID)
+	return nil
+}
+
+// SetSeqNumber перезаписывает low-water mark, в том числе на меньшее
+// значение. Обработанные номера не выше него забываются.
+func (b *Bolt) SetSeqNumber(_ context.Context, seq schema.SeqNumber) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		processed := tx.Bucket(processedBucket).Cursor()
+		for k, _ := processed.First(); k != nil && decodeSeq(k) <= seq; k, _ = processed.First() {
+			if err := processed.Delete(); err != nil {
+				return err
+			}
+		}
+
+		if err := storeSeq(tx, seq); err != nil {
+			return err
+		}
+		return advanceMark(tx)
+	})
+	if err != nil {
+		b.log.Errorf("failed to set seq number: %v", err)
+		return err
+	}
+
+	b.log.Infof("seq number set: %d", seq)
+	return nil
+}
+
+// Rewind забывает номера начиная с from и опускает low-water mark ниже
+// него, чтобы повторно доставленные сообщения были записаны заново.
+func (b *Bolt) Rewind(_ context.Context, from schema.SeqNumber) error {
+	before := max(from, 1) - 1
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		processed := tx.Bucket(processedBucket).Cursor()
+		for k, _ := processed.Seek(encodeSeq(before + 1)); k != nil; k, _ = processed.Seek(encodeSeq(before + 1)) {
+			if err := processed.Delete(); err != nil {
+				return err
+			}
+		}
+
+		if loadSeq(tx) <= before {
+			return nil
+		}
+		return storeSeq(tx, before)
+	})
+	if err != nil {
+		b.log.Errorf("failed to rewind seq number: %v", err)
+		return err
+	}
+
+	b.log.Infof("seq numbers rewound to %d", before)
+	return nil
+}
+
+// SeqProgress возвращает low-water mark и участки обработанных номеров
+// выше него.
+func (b *Bolt) SeqProgress(_ context.Context) (orderdb.SeqProgress, error) {
+	var (
+		mark      schema.SeqNumber
+		processed []orderdb.SeqRange
+	)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		mark = loadSeq(tx)
+		return tx.Bucket(processedBucket).ForEach(func(k, _ []byte) error {
+			seq := decodeSeq(k)
+			if n := len(processed); n > 0 && processed[n-1].To+1 == seq {
+				processed[n-1].To = seq
+			} else {
+				processed = append(processed, orderdb.SeqRange{From: seq, To: seq})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return orderdb.SeqProgress{}, err
+	}
+
+	return orderdb.NewSeqProgress(mark, processed), nil
+}
+
+// SchemaVersions возвращает количество заказов в каждой версии схемы.
+func (b *Bolt) SchemaVersions(_ context.Context) ([]orderdb.VersionCount, error) {
+	counts := make(map[int]int)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(ordersBucket).ForEach(func(k, v []byte) error {
+			var rec record
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return fmt.Errorf("order %s: %w", k, err)
+			}
+			counts[rec.Version]++
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]orderdb.VersionCount, 0, len(counts))
+	for version, n := range counts {
+		ret = append(ret, orderdb.VersionCount{Version: version, Orders: n})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Version < ret[j].Version })
+	return ret, nil
+}
+
+func encode(order schema.Order) ([]byte, error) {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(record{Version: schema.CurrentVersion, Data: data})
+}
+
+// decode приводит заказ, сохраненный в старой версии схемы, к текущей
+func (b *Bolt) decode(uid schema.OrderUID, value []byte) (schema.Order, error) {
+	var rec record
+	if err := json.Unmarshal(value, &rec); err != nil {
+		return schema.Order{}, fmt.Errorf("order %s: %w", uid, err)
+	}
+
+	order, err := b.deps.Registry.DecodeVersion(rec.Version, rec.Data)
+	if err != nil {
+		b.log.Errorf("failed to decode order %s of version %d: %v", uid, rec.Version, err)
+		return schema.Order{}, fmt.Errorf("order %s: %w", uid, err)
+	}
+
+	return order, nil
+}
+
+func loadSeq(tx *bolt.Tx) schema.SeqNumber {
+	value := tx.Bucket(metaBucket).Get(seqKey)
+	if len(value) != 8 {
+		return 0
+	}
+
+	return decodeSeq(value)
+}
+
+func storeSeq(tx *bolt.Tx, seq schema.SeqNumber) error {
+	return tx.Bucket(metaBucket).Put(seqKey, encodeSeq(seq))
+}
+
+func processed(tx *bolt.Tx, seq schema.SeqNumber) bool {
+	return seq <= loadSeq(tx) || tx.Bucket(processedBucket).Get(encodeSeq(seq)) != nil
+}
+
+// markProcessed отмечает номер обработанным и продвигает low-water mark
+// до конца непрерывного участка обработанных номеров. Ключи bucket
+// processed в big endian, поэтому курсор обходит их по возрастанию.
+func markProcessed(tx *bolt.Tx, seq schema.SeqNumber) error {
+	if err := tx.Bucket(processedBucket).Put(encodeSeq(seq), nil); err != nil {
+		return err
+	}
+	return advanceMark(tx)
+}
+
+func advanceMark(tx *bolt.Tx) error {
+	mark := loadSeq(tx)
+	processed := tx.Bucket(processedBucket).Cursor()
+	for k, _ := processed.First(); k != nil && decodeSeq(k) == mark+1; k, _ = processed.First() {
+		if err := processed.Delete(); err != nil {
+			return err
+		}
+		mark++
+	}
+
+	return storeSeq(tx, mark)
+}
+
+func encodeSeq(seq schema.SeqNumber) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(seq))
+}
+
+func decodeSeq(key []byte) schema.SeqNumber {
+	return schema.SeqNumber(binary.BigEndian.Uint64(key))
+}
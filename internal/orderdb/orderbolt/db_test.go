@@ -0,0 +1,63 @@
+package orderbolt
+
+import (
+	"context"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderdb/orderdbtest"
+	"orderservice/internal/schema"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func open(t *testing.T, path string) *Bolt {
+	db, err := New(Config{Path: path}, Dependencies{Log: logrus.New()})
+	require.NoError(t, err)
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	orderdbtest.Run(t, func(t *testing.T) orderdb.OrderDB {
+		db := open(t, filepath.Join(t.TempDir(), "orders.db"))
+		t.Cleanup(func() { db.Close() })
+		return db
+	})
+}
+
+// Заказы и номер сообщения сохраняются между открытиями файла
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.db")
+
+	db := open(t, path)
+	require.NoError(t, db.AddOrder(ctx, schema.Order{OrderUID: "b", CustomerID: "c1"}, 2))
+	require.NoError(t, db.AddOrder(ctx, schema.Order{OrderUID: "a", CustomerID: "c2"}, 1))
+	require.NoError(t, db.RejectOrder(ctx, "bad", "invalid", 3))
+	require.NoError(t, db.Close())
+
+	db = open(t, path)
+	defer db.Close()
+
+	seq, err := db.SeqNumber(ctx)
+	require.NoError(t, err)
+	require.Equal(t, schema.SeqNumber(3), seq)
+
+	orders, err := db.ListOrders(ctx, orderdb.Filter{})
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	require.Equal(t, schema.OrderUID("a"), orders[0].OrderUID)
+
+	orders, err = db.ListOrders(ctx, orderdb.Filter{CustomerID: "c1"})
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	require.Equal(t, schema.OrderUID("b"), orders[0].OrderUID)
+
+	_, err = db.GetOrder(ctx, "bad")
+	require.ErrorIs(t, err, orderdb.ErrNotFound)
+
+	versions, err := db.SchemaVersions(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []orderdb.VersionCount{{Version: schema.CurrentVersion, Orders: 2}}, versions)
+}
@@ -2,17 +2,28 @@ package ordercache
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"orderservice/internal/orderdb"
 	"orderservice/internal/schema"
+	"sort"
 	"sync"
 	"sync/atomic"
 )
 
 type Config struct {
+	// SearchIndex включает in-memory полнотекстовый индекс. Без него поиск
+	// выполняется persistent хранилищем.
+	SearchIndex bool
 }
 
 type Dependencies struct {
 	Persistent orderdb.OrderDB
+	// Observers уведомляются о каждом заказе, попавшем в кеш или удаленном из него
+	Observers []orderdb.OrderObserver
+	// Peers сообщает другим репликам об изменениях, сделанных через этот
+	// кеш. Без него кеш видит только собственные изменения.
+	Peers orderdb.ChangeNotifier
 }
 
 type CacheDB struct {
@@ -22,29 +33,190 @@ type CacheDB struct {
 	cached      sync.Map
 	cachedCount atomic.Int32
 	seq         atomic.Uint64
+
+	search  *searchIndex
+	indexes *secondaryIndex
 }
 
 func New(cfg Config, deps Dependencies) *CacheDB {
-	return &CacheDB{
-		cfg:  cfg,
-		deps: deps,
+	c := &CacheDB{
+		cfg:     cfg,
+		deps:    deps,
+		indexes: newSecondaryIndex(),
+	}
+
+	if cfg.SearchIndex {
+		c.search = newSearchIndex()
+	}
+
+	return c
+}
+
+// put сохраняет заказ в кеше и обновляет индексы.
+func (c *CacheDB) put(order schema.Order) {
+	prev, loaded := c.cached.Swap(order.OrderUID, order)
+	if !loaded {
+		c.cachedCount.Add(1)
+		c.indexes.replace(nil, &order)
+	} else {
+		old := prev.(schema.Order)
+		c.indexes.replace(&old, &order)
+	}
+
+	if c.search != nil {
+		c.search.add(order)
+	}
+
+	for _, o := range c.deps.Observers {
+		o.OrderStored(order)
+	}
+}
+
+func (c *CacheDB) drop(orderUID schema.OrderUID) {
+	if prev, loaded := c.cached.LoadAndDelete(orderUID); loaded {
+		c.cachedCount.Add(-1)
+		old := prev.(schema.Order)
+		c.indexes.replace(&old, nil)
+	}
+
+	if c.search != nil {
+		c.search.remove(orderUID)
+	}
+
+	for _, o := range c.deps.Observers {
+		o.OrderRemoved(orderUID)
 	}
 }
 
-func (c *CacheDB) SeqNumber(_ context.Context) (schema.SeqNumber, error) {
+// SeqNumber возвращает low-water mark persistent хранилища, которое
+// отслеживает каждый номер. Иначе - наибольший номер, известный кешу.
+func (c *CacheDB) SeqNumber(ctx context.Context) (schema.SeqNumber, error) {
+	if _, ok := c.deps.Persistent.(orderdb.SeqTracker); ok {
+		return c.deps.Persistent.SeqNumber(ctx)
+	}
+
 	return schema.SeqNumber(c.seq.Load()), nil
 }
 
+// SeqProgress возвращает обработанные номера и пропуски persistent
+// хранилища.
+func (c *CacheDB) SeqProgress(ctx context.Context) (orderdb.SeqProgress, error) {
+	tracker, ok := c.deps.Persistent.(orderdb.SeqTracker)
+	if !ok {
+		return orderdb.SeqProgress{}, errors.New("persistent store does not track processed sequences")
+	}
+
+	return tracker.SeqProgress(ctx)
+}
+
+// Rewind забывает обработанные номера persistent хранилища начиная с from.
+func (c *CacheDB) Rewind(ctx context.Context, from schema.SeqNumber) error {
+	tracker, ok := c.deps.Persistent.(orderdb.SeqTracker)
+	if !ok {
+		return errors.New("persistent store does not track processed sequences")
+	}
+
+	return tracker.Rewind(ctx, from)
+}
+
 func (c *CacheDB) AddOrder(ctx context.Context, order schema.Order, seq schema.SeqNumber) error {
-	if err := c.deps.Persistent.AddOrder(ctx, order, seq); err != nil {
+	err := c.deps.Persistent.AddOrder(ctx, order, seq)
+	if errors.Is(err, orderdb.ErrAlreadyProcessed) {
+		return c.redelivered(ctx, order.OrderUID, seq)
+	}
+	if err != nil {
 		return err
 	}
 
-	c.cached.Store(order.OrderUID, order)
-	c.cachedCount.Add(1)
+	c.put(order)
+	c.storeSeq(seq)
+	return c.notify(ctx, orderdb.OrderChange{OrderUID: order.OrderUID, Seq: seq})
+}
+
+// RejectOrder передает отказ persistent хранилищу, кеш не меняется.
+// Хранилище, которое не сохраняет отказы, не отслеживает и номера
+// сообщений, для него запоминается только номер.
+func (c *CacheDB) RejectOrder(ctx context.Context, orderUID schema.OrderUID, reason string, seq schema.SeqNumber) error {
+	if rejecter, ok := c.deps.Persistent.(orderdb.Rejecter); ok {
+		if err := rejecter.RejectOrder(ctx, orderUID, reason, seq); err != nil {
+			return err
+		}
+	}
+
+	c.storeSeq(seq)
 	return nil
 }
 
+// redelivered обновляет кеш и повторяет уведомление для уже
+// обработанного сообщения: если уведомление после первой обработки не
+// дошло, сообщение было возвращено брокеру. Заказ перечитывается из
+// persistent хранилища, в нем могут быть данные следующих сообщений.
+func (c *CacheDB) redelivered(ctx context.Context, orderUID schema.OrderUID, seq schema.SeqNumber) error {
+	order, err := c.deps.Persistent.GetOrder(ctx, orderUID)
+	switch {
+	case err == nil:
+		c.put(order)
+	case errors.Is(err, orderdb.ErrNotFound):
+		c.drop(orderUID)
+	default:
+		return err
+	}
+
+	if err := c.notify(ctx, orderdb.OrderChange{OrderUID: orderUID, Seq: seq}); err != nil {
+		return err
+	}
+	return orderdb.ErrAlreadyProcessed
+}
+
+// notify сообщает об изменении другим репликам. Ошибка возвращается
+// вызывающему, чтобы сообщение было доставлено повторно, при повторной
+// доставке уведомление отправляется снова, см. redelivered.
+func (c *CacheDB) notify(ctx context.Context, change orderdb.OrderChange) error {
+	if c.deps.Peers == nil {
+		return nil
+	}
+
+	if err := c.deps.Peers.NotifyChange(ctx, change); err != nil {
+		return fmt.Errorf("notify peers about %s: %w", change.OrderUID, err)
+	}
+	return nil
+}
+
+// ApplyChange обновляет кеш по изменению, сделанному другой репликой.
+// Заказ перечитывается из persistent хранилища, поэтому повторное или
+// запоздавшее уведомление не приводит к устаревшим данным.
+func (c *CacheDB) ApplyChange(ctx context.Context, change orderdb.OrderChange) error {
+	c.storeSeq(change.Seq)
+	if change.Removed {
+		c.drop(change.OrderUID)
+		return nil
+	}
+
+	order, err := c.deps.Persistent.GetOrder(ctx, change.OrderUID)
+	if errors.Is(err, orderdb.ErrNotFound) {
+		c.drop(change.OrderUID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	c.put(order)
+	return nil
+}
+
+// storeSeq увеличивает наибольший известный кешу номер сообщения. Он
+// возвращается SeqNumber, если persistent хранилище не отслеживает
+// каждый номер.
+func (c *CacheDB) storeSeq(seq schema.SeqNumber) {
+	for {
+		cur := c.seq.Load()
+		if uint64(seq) <= cur || c.seq.CompareAndSwap(cur, uint64(seq)) {
+			return
+		}
+	}
+}
+
 func (c *CacheDB) GetOrder(_ context.Context, orderUID schema.OrderUID) (schema.Order, error) {
 	v, ok := c.cached.Load(orderUID)
 	if !ok {
@@ -54,18 +226,28 @@ func (c *CacheDB) GetOrder(_ context.Context, orderUID schema.OrderUID) (schema.
 	return v.(schema.Order), nil
 }
 
-func (c *CacheDB) ListOrders(_ context.Context) ([]schema.Order, error) {
+func (c *CacheDB) ListOrders(_ context.Context, filter orderdb.Filter) ([]schema.Order, error) {
 	ret := make([]schema.Order, 0, c.cachedCount.Load())
 	c.cached.Range(func(_, value any) bool {
-		ret = append(ret, value.(schema.Order))
+		if order := value.(schema.Order); filter.Match(order) {
+			ret = append(ret, order)
+		}
 		return true
 	})
 
-	return ret, nil
+	if filter == (orderdb.Filter{}) {
+		return ret, nil
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].OrderUID < ret[j].OrderUID
+	})
+
+	return filter.Page(ret), nil
 }
 
 func (c *CacheDB) Restore(ctx context.Context) error {
-	res, err := c.deps.Persistent.ListOrders(ctx)
+	res, err := c.deps.Persistent.ListOrders(ctx, orderdb.Filter{})
 	if err != nil {
 		return err
 	}
@@ -76,9 +258,128 @@ func (c *CacheDB) Restore(ctx context.Context) error {
 	}
 
 	for _, order := range res {
-		c.cached.Store(order.OrderUID, order)
+		c.put(order)
 	}
 
 	c.seq.Store(uint64(seq))
 	return nil
 }
+
+func (c *CacheDB) admin() (orderdb.AdminOrderDB, error) {
+	admin, ok := c.deps.Persistent.(orderdb.AdminOrderDB)
+	if !ok {
+		return nil, errors.New("persistent store does not support admin operations")
+	}
+
+	return admin, nil
+}
+
+func (c *CacheDB) DeleteOrder(ctx context.Context, orderUID schema.OrderUID) error {
+	admin, err := c.admin()
+	if err != nil {
+		return err
+	}
+
+	if err := admin.DeleteOrder(ctx, orderUID); err != nil {
+		return err
+	}
+
+	c.drop(orderUID)
+	return c.notify(ctx, orderdb.OrderChange{OrderUID: orderUID, Removed: true})
+}
+
+func (c *CacheDB) AnonymizeOrder(ctx context.Context, orderUID schema.OrderUID) error {
+	admin, err := c.admin()
+	if err != nil {
+		return err
+	}
+
+	if err := admin.AnonymizeOrder(ctx, orderUID); err != nil {
+		return err
+	}
+
+	if v, ok := c.cached.Load(orderUID); ok {
+		c.put(v.(schema.Order).Anonymized())
+	}
+	return c.notify(ctx, orderdb.OrderChange{OrderUID: orderUID})
+}
+
+func (c *CacheDB) SetSeqNumber(ctx context.Context, seq schema.SeqNumber) error {
+	admin, err := c.admin()
+	if err != nil {
+		return err
+	}
+
+	if err := admin.SetSeqNumber(ctx, seq); err != nil {
+		return err
+	}
+
+	c.seq.Store(uint64(seq))
+	return nil
+}
+
+// SchemaVersions возвращает версии схемы заказов в persistent хранилище,
+// в кеше заказы всегда хранятся в текущей версии.
+func (c *CacheDB) SchemaVersions(ctx context.Context) ([]orderdb.VersionCount, error) {
+	reporter, ok := c.deps.Persistent.(orderdb.VersionReporter)
+	if !ok {
+		return nil, errors.New("persistent store does not report schema versions")
+	}
+
+	return reporter.SchemaVersions(ctx)
+}
+
+func (c *CacheDB) Search(ctx context.Context, query string, limit int) ([]orderdb.SearchResult, error) {
+	if c.search != nil {
+		return c.search.search(query, limit, func(uid schema.OrderUID) (schema.Order, bool) {
+			v, ok := c.cached.Load(uid)
+			if !ok {
+				return schema.Order{}, false
+			}
+			return v.(schema.Order), true
+		}), nil
+	}
+
+	searcher, ok := c.deps.Persistent.(orderdb.Searcher)
+	if !ok {
+		return nil, errors.New("search is not supported")
+	}
+
+	return searcher.Search(ctx, query, limit)
+}
+
+// FindOrders ищет заказы по вторичному индексу кеша, а если в кеше
+// ничего не найдено - в persistent хранилище.
+func (c *CacheDB) FindOrders(ctx context.Context, key orderdb.Key, value string) ([]schema.Order, error) {
+	uids := c.indexes.find(key, value)
+	ret := make([]schema.Order, 0, len(uids))
+	for _, uid := range uids {
+		if v, ok := c.cached.Load(uid); ok {
+			ret = append(ret, v.(schema.Order))
+		}
+	}
+
+	if len(ret) > 0 {
+		sort.Slice(ret, func(i, j int) bool {
+			return ret[i].OrderUID < ret[j].OrderUID
+		})
+		return ret, nil
+	}
+
+	if indexed, ok := c.deps.Persistent.(orderdb.IndexedOrderDB); ok {
+		return indexed.FindOrders(ctx, key, value)
+	}
+
+	return nil, orderdb.ErrNotFound
+}
+
+// Ping проверяет persistent хранилище, хранилища без проверки считаются
+// доступными.
+func (c *CacheDB) Ping(ctx context.Context) error {
+	pinger, ok := c.deps.Persistent.(orderdb.Pinger)
+	if !ok {
+		return nil
+	}
+
+	return pinger.Ping(ctx)
+}
@@ -2,10 +2,16 @@ package ordercache
 
 import (
 	"context"
+	"errors"
 	"orderservice/internal/orderdb"
+	"orderservice/internal/orderdb/orderbolt"
+	"orderservice/internal/orderdb/orderdbtest"
 	"orderservice/internal/schema"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
@@ -15,6 +21,19 @@ var testOrder = []schema.Order{
 	{OrderUID: "2234", Entry: "64363"},
 }
 
+// Номера сообщений отслеживает persistent хранилище, поэтому кеш
+// проверяется вместе с bolt
+func TestConformance(t *testing.T) {
+	orderdbtest.Run(t, func(t *testing.T) orderdb.OrderDB {
+		db, err := orderbolt.New(orderbolt.Config{Path: filepath.Join(t.TempDir(), "orders.db")},
+			orderbolt.Dependencies{Log: logrus.New()})
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		return New(Config{SearchIndex: true}, Dependencies{Persistent: db})
+	})
+}
+
 func TestAddGet(t *testing.T) {
 	testOrder := schema.Order{TrackNumber: "12314", Entry: "64363"}
 
@@ -35,7 +54,7 @@ func TestAddGet(t *testing.T) {
 			setup: func(db *orderdb.MockOrderDB) {
 				order := testOrder
 				order.OrderUID = "key1"
-				db.EXPECT().AddOrder(gomock.Any(), order, 0)
+				db.EXPECT().AddOrder(gomock.Any(), order, schema.SeqNumber(0))
 			},
 		},
 		{
@@ -45,7 +64,7 @@ func TestAddGet(t *testing.T) {
 			setup: func(db *orderdb.MockOrderDB) {
 				order := testOrder
 				order.OrderUID = "key1"
-				db.EXPECT().AddOrder(gomock.Any(), order, 0)
+				db.EXPECT().AddOrder(gomock.Any(), order, schema.SeqNumber(0))
 			},
 		},
 	}
@@ -94,7 +113,7 @@ func TestList(t *testing.T) {
 			list: testOrder,
 			setup: func(db *orderdb.MockOrderDB) {
 				for _, order := range testOrder {
-					db.EXPECT().AddOrder(gomock.Any(), order, 0)
+					db.EXPECT().AddOrder(gomock.Any(), order, schema.SeqNumber(0))
 				}
 			},
 		},
@@ -117,7 +136,7 @@ func TestList(t *testing.T) {
 				require.NoError(t, err)
 			}
 
-			res, err := cache.ListOrders(context.Background())
+			res, err := cache.ListOrders(context.Background(), orderdb.Filter{})
 			require.NoError(t, err)
 			require.Len(t, res, len(testOrder))
 			for _, order := range res {
@@ -131,7 +150,8 @@ func TestRestore(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
 	db := orderdb.NewMockOrderDB(ctrl)
-	db.EXPECT().ListOrders(gomock.Any()).Return(testOrder, nil)
+	db.EXPECT().ListOrders(gomock.Any(), orderdb.Filter{}).Return(testOrder, nil)
+	db.EXPECT().SeqNumber(gomock.Any()).Return(schema.SeqNumber(0), nil)
 
 	cache := New(
 		Config{},
@@ -142,10 +162,199 @@ func TestRestore(t *testing.T) {
 	err := cache.Restore(context.Background())
 	require.NoError(t, err)
 
-	res, err := cache.ListOrders(context.Background())
+	res, err := cache.ListOrders(context.Background(), orderdb.Filter{})
 	require.NoError(t, err)
 	require.Len(t, res, len(testOrder))
 	for _, order := range res {
 		require.Contains(t, testOrder, order)
 	}
 }
+
+func TestListFilter(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2023, 11, d, 0, 0, 0, 0, time.UTC) }
+	orders := []schema.Order{
+		{OrderUID: "3", CustomerID: "a", DateCreated: schema.NewTimestamp(day(2))},
+		{OrderUID: "1", CustomerID: "a", DateCreated: schema.NewTimestamp(day(1))},
+		{OrderUID: "2", CustomerID: "b", DateCreated: schema.NewTimestamp(day(2))},
+		{OrderUID: "4", CustomerID: "a"},
+	}
+
+	ctrl := gomock.NewController(t)
+	db := orderdb.NewMockOrderDB(ctrl)
+	db.EXPECT().AddOrder(gomock.Any(), gomock.Any(), gomock.Any()).Times(len(orders))
+
+	cache := New(Config{}, Dependencies{Persistent: db})
+	for _, order := range orders {
+		require.NoError(t, cache.AddOrder(context.Background(), order, 0))
+	}
+
+	res, err := cache.ListOrders(context.Background(), orderdb.Filter{CustomerID: "a", Offset: 1, Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, []schema.Order{orders[0]}, res)
+
+	res, err = cache.ListOrders(context.Background(), orderdb.Filter{CreatedFrom: day(2), CreatedTo: day(3)})
+	require.NoError(t, err)
+	require.Equal(t, []schema.Order{orders[2], orders[0]}, res)
+}
+
+func TestSearch(t *testing.T) {
+	orders := []schema.Order{
+		{
+			OrderUID: "1",
+			Delivery: schema.Delivery{Name: "Test Testov", Phone: "+9720000000", Email: "test@gmail.com"},
+			Items:    schema.Items{{Name: "Mascaras", Brand: "Vivienne Sabo"}},
+		},
+		{
+			OrderUID: "2",
+			Delivery: schema.Delivery{Name: "Ivan Testov", Phone: "+7 912 000 00 00"},
+			Items:    schema.Items{{Name: "Sneakers", Brand: "Nike"}},
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	db := orderdb.NewMockOrderDB(ctrl)
+	db.EXPECT().AddOrder(gomock.Any(), gomock.Any(), gomock.Any()).Times(len(orders))
+
+	cache := New(Config{SearchIndex: true}, Dependencies{Persistent: db})
+	for _, order := range orders {
+		require.NoError(t, cache.AddOrder(context.Background(), order, 0))
+	}
+
+	cases := []struct {
+		query string
+		want  []schema.OrderUID
+	}{
+		{query: "testov", want: []schema.OrderUID{"1", "2"}},
+		{query: "Ivan Testov", want: []schema.OrderUID{"2", "1"}},
+		{query: "test@gmail.com", want: []schema.OrderUID{"1"}},
+		{query: "+7 (912) 000-00-00", want: []schema.OrderUID{"2"}},
+		{query: "vivienne", want: []schema.OrderUID{"1"}},
+		{query: "unknown", want: []schema.OrderUID{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			res, err := cache.Search(context.Background(), c.query, 10)
+			require.NoError(t, err)
+
+			got := make([]schema.OrderUID, 0, len(res))
+			for _, r := range res {
+				got = append(got, r.Order.OrderUID)
+			}
+			require.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestFindOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := orderdb.NewMockOrderDB(ctrl)
+	db.EXPECT().AddOrder(gomock.Any(), gomock.Any(), gomock.Any()).Times(3)
+
+	cache := New(Config{}, Dependencies{Persistent: db})
+	ctx := context.Background()
+	require.NoError(t, cache.AddOrder(ctx, schema.Order{OrderUID: "1", TrackNumber: "T1",
+		Items: schema.Items{{RID: "r1"}, {RID: "r2"}}}, 1))
+	require.NoError(t, cache.AddOrder(ctx, schema.Order{OrderUID: "2", TrackNumber: "T1"}, 2))
+
+	res, err := cache.FindOrders(ctx, orderdb.KeyTrackNumber, "T1")
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+
+	res, err = cache.FindOrders(ctx, orderdb.KeyRID, "r2")
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+
+	// Повторное добавление заказа заменяет старые значения ключей
+	require.NoError(t, cache.AddOrder(ctx, schema.Order{OrderUID: "1", TrackNumber: "T2"}, 3))
+	_, err = cache.FindOrders(ctx, orderdb.KeyRID, "r2")
+	require.ErrorIs(t, err, orderdb.ErrNotFound)
+
+	res, err = cache.FindOrders(ctx, orderdb.KeyTrackNumber, "T1")
+	require.NoError(t, err)
+	require.Equal(t, schema.OrderUID("2"), res[0].OrderUID)
+}
+
+// peerLink передает уведомления одной реплики другой, как orderpeers
+type peerLink struct {
+	peer orderdb.ChangeApplier
+}
+
+func (l *peerLink) NotifyChange(ctx context.Context, change orderdb.OrderChange) error {
+	return l.peer.ApplyChange(ctx, change)
+}
+
+func TestPeerChanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := orderdb.NewMockOrderDB(ctrl)
+	order := schema.Order{OrderUID: "1", TrackNumber: "T1"}
+
+	link := &peerLink{}
+	first := New(Config{}, Dependencies{Persistent: db, Peers: link})
+	second := New(Config{}, Dependencies{Persistent: db})
+	link.peer = second
+
+	// Вторая реплика перечитывает заказ, записанный первой, из общего хранилища
+	ctx := context.Background()
+	db.EXPECT().AddOrder(gomock.Any(), order, schema.SeqNumber(5))
+	db.EXPECT().GetOrder(gomock.Any(), order.OrderUID).Return(order, nil)
+	require.NoError(t, first.AddOrder(ctx, order, 5))
+
+	got, err := second.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.Equal(t, order, got)
+	seq, err := second.SeqNumber(ctx)
+	require.NoError(t, err)
+	require.Equal(t, schema.SeqNumber(5), seq)
+
+	// Уведомление о заказе, которого уже нет в хранилище, удаляет его из кеша
+	db.EXPECT().GetOrder(gomock.Any(), order.OrderUID).Return(schema.Order{}, orderdb.ErrNotFound)
+	require.NoError(t, second.ApplyChange(ctx, orderdb.OrderChange{OrderUID: order.OrderUID}))
+	_, err = second.GetOrder(ctx, order.OrderUID)
+	require.ErrorIs(t, err, orderdb.ErrNotFound)
+
+	require.NoError(t, first.ApplyChange(ctx, orderdb.OrderChange{OrderUID: order.OrderUID, Removed: true}))
+	_, err = first.GetOrder(ctx, order.OrderUID)
+	require.ErrorIs(t, err, orderdb.ErrNotFound)
+}
+
+// failingLink не доставляет первое уведомление
+type failingLink struct {
+	peerLink
+	failed bool
+}
+
+func (l *failingLink) NotifyChange(ctx context.Context, change orderdb.OrderChange) error {
+	if !l.failed {
+		l.failed = true
+		return errors.New("nats unavailable")
+	}
+	return l.peerLink.NotifyChange(ctx, change)
+}
+
+// Уведомление, не дошедшее после записи, отправляется при повторной
+// доставке сообщения, которое хранилище уже обработало
+func TestPeerRedelivery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := orderdb.NewMockOrderDB(ctrl)
+	order := schema.Order{OrderUID: "1", TrackNumber: "T1"}
+
+	link := &failingLink{}
+	first := New(Config{}, Dependencies{Persistent: db, Peers: link})
+	second := New(Config{}, Dependencies{Persistent: db})
+	link.peer = second
+
+	ctx := context.Background()
+	db.EXPECT().AddOrder(gomock.Any(), order, schema.SeqNumber(5))
+	require.Error(t, first.AddOrder(ctx, order, 5))
+	_, err := second.GetOrder(ctx, order.OrderUID)
+	require.ErrorIs(t, err, orderdb.ErrNotFound)
+
+	db.EXPECT().AddOrder(gomock.Any(), order, schema.SeqNumber(5)).Return(orderdb.ErrAlreadyProcessed)
+	db.EXPECT().GetOrder(gomock.Any(), order.OrderUID).Return(order, nil).Times(2)
+	require.ErrorIs(t, first.AddOrder(ctx, order, 5), orderdb.ErrAlreadyProcessed)
+
+	got, err := second.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.Equal(t, order, got)
+}
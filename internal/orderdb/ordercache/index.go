@@ -0,0 +1,89 @@
+package ordercache
+
+import (
+	"orderservice/internal/orderdb"
+	"orderservice/internal/schema"
+	"sync"
+)
+
+// secondaryIndex maps values of secondary keys to order uids.
+type secondaryIndex struct {
+	mu      sync.RWMutex
+	entries map[orderdb.Key]map[string]map[schema.OrderUID]struct{}
+}
+
+func newSecondaryIndex() *secondaryIndex {
+	return &secondaryIndex{
+		entries: make(map[orderdb.Key]map[string]map[schema.OrderUID]struct{}),
+	}
+}
+
+func orderKeys(order schema.Order) map[orderdb.Key][]string {
+	keys := map[orderdb.Key][]string{
+		orderdb.KeyTrackNumber: {order.TrackNumber},
+		orderdb.KeyTransaction: {order.Payment.Transaction},
+		orderdb.KeyCustomerID:  {order.CustomerID},
+	}
+
+	for _, item := range order.Items {
+		keys[orderdb.KeyRID] = append(keys[orderdb.KeyRID], item.RID)
+	}
+
+	return keys
+}
+
+// replace удаляет значения ключей старой версии заказа и добавляет новые.
+func (idx *secondaryIndex) replace(old *schema.Order, order *schema.Order) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if old != nil {
+		for key, values := range orderKeys(*old) {
+			for _, value := range values {
+				uids := idx.entries[key][value]
+				delete(uids, old.OrderUID)
+				if len(uids) == 0 {
+					delete(idx.entries[key], value)
+				}
+			}
+		}
+	}
+
+	if order == nil {
+		return
+	}
+
+	for key, values := range orderKeys(*order) {
+		byValue, ok := idx.entries[key]
+		if !ok {
+			byValue = make(map[string]map[schema.OrderUID]struct{})
+			idx.entries[key] = byValue
+		}
+
+		for _, value := range values {
+			if value == "" {
+				continue
+			}
+
+			uids, ok := byValue[value]
+			if !ok {
+				uids = make(map[schema.OrderUID]struct{})
+				byValue[value] = uids
+			}
+			uids[order.OrderUID] = struct{}{}
+		}
+	}
+}
+
+func (idx *secondaryIndex) find(key orderdb.Key, value string) []schema.OrderUID {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	uids := idx.entries[key][value]
+	ret := make([]schema.OrderUID, 0, len(uids))
+	for uid := range uids {
+		ret = append(ret, uid)
+	}
+
+	return ret
+}
@@ -0,0 +1,153 @@
+package ordercache
+
+import (
+	"orderservice/internal/orderdb"
+	"orderservice/internal/schema"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// searchIndex is an inverted index from lowercase tokens of customer
+// and item fields to the orders containing them.
+type searchIndex struct {
+	mu     sync.RWMutex
+	tokens map[string]map[schema.OrderUID]struct{}
+	orders map[schema.OrderUID][]string
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		tokens: make(map[string]map[schema.OrderUID]struct{}),
+		orders: make(map[schema.OrderUID][]string),
+	}
+}
+
+func orderTokens(order schema.Order) []string {
+	fields := []string{
+		string(order.OrderUID),
+		order.TrackNumber,
+		order.CustomerID,
+		order.Delivery.Name,
+		order.Delivery.Email,
+		order.Delivery.City,
+	}
+	for _, item := range order.Items {
+		fields = append(fields, item.Name, item.Brand)
+	}
+
+	set := make(map[string]struct{})
+	for _, field := range fields {
+		for _, token := range tokenize(field) {
+			set[token] = struct{}{}
+		}
+	}
+
+	// Email и телефон также ищутся целиком
+	if email := strings.ToLower(order.Delivery.Email); email != "" {
+		set[email] = struct{}{}
+	}
+	if phone := orderdb.PhoneDigits(order.Delivery.Phone); phone != "" {
+		set[phone] = struct{}{}
+	}
+
+	ret := make([]string, 0, len(set))
+	for token := range set {
+		ret = append(ret, token)
+	}
+
+	return ret
+}
+
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func (idx *searchIndex) add(order schema.Order) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeLocked(order.OrderUID)
+	tokens := orderTokens(order)
+	for _, token := range tokens {
+		uids, ok := idx.tokens[token]
+		if !ok {
+			uids = make(map[schema.OrderUID]struct{})
+			idx.tokens[token] = uids
+		}
+		uids[order.OrderUID] = struct{}{}
+	}
+
+	idx.orders[order.OrderUID] = tokens
+}
+
+func (idx *searchIndex) remove(orderUID schema.OrderUID) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeLocked(orderUID)
+}
+
+func (idx *searchIndex) removeLocked(orderUID schema.OrderUID) {
+	for _, token := range idx.orders[orderUID] {
+		uids := idx.tokens[token]
+		delete(uids, orderUID)
+		if len(uids) == 0 {
+			delete(idx.tokens, token)
+		}
+	}
+
+	delete(idx.orders, orderUID)
+}
+
+// search ранжирует заказы по доле совпавших токенов запроса.
+func (idx *searchIndex) search(query string, limit int, get func(schema.OrderUID) (schema.Order, bool)) []orderdb.SearchResult {
+	queryTokens := tokenize(query)
+	if orderdb.IsPhone(query) {
+		queryTokens = []string{orderdb.PhoneDigits(query)}
+	} else if strings.Contains(query, "@") {
+		queryTokens = append(queryTokens, strings.ToLower(strings.TrimSpace(query)))
+	}
+
+	if len(queryTokens) == 0 {
+		return nil
+	}
+
+	idx.mu.RLock()
+	matches := make(map[schema.OrderUID]int)
+	for _, token := range queryTokens {
+		for uid := range idx.tokens[token] {
+			matches[uid]++
+		}
+	}
+	idx.mu.RUnlock()
+
+	ret := make([]orderdb.SearchResult, 0, len(matches))
+	for uid, count := range matches {
+		order, ok := get(uid)
+		if !ok {
+			continue
+		}
+
+		ret = append(ret, orderdb.SearchResult{
+			Order: order,
+			Rank:  float64(count) / float64(len(queryTokens)),
+		})
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Rank != ret[j].Rank {
+			return ret[i].Rank > ret[j].Rank
+		}
+		return ret[i].Order.OrderUID < ret[j].Order.OrderUID
+	})
+
+	if limit > 0 && len(ret) > limit {
+		ret = ret[:limit]
+	}
+
+	return ret
+}
@@ -0,0 +1,381 @@
+// Package orderdbtest проверяет, что реализация orderdb.OrderDB ведет себя
+// так же, как остальные: сервис должен работать одинаково с любым
+// хранилищем.
+package orderdbtest
+
+import (
+	"context"
+	"fmt"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/schema"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Factory возвращает пустое хранилище для одного теста. Освобождение
+// ресурсов регистрируется через t.Cleanup.
+type Factory func(t *testing.T) orderdb.OrderDB
+
+// Run запускает все проверки, каждую на новом хранилище.
+func Run(t *testing.T, open Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, db orderdb.OrderDB)
+	}{
+		{"NotFound", testNotFound},
+		{"AddGet", testAddGet},
+		{"Overwrite", testOverwrite},
+		{"SeqNumber", testSeqNumber},
+		{"Redelivery", testRedelivery},
+		{"Rewind", testRewind},
+		{"ListOrders", testListOrders},
+		{"ListPaging", testListPaging},
+		{"PhoneSearch", testPhoneSearch},
+		{"Concurrent", testConcurrent},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+// Order возвращает заполненный заказ, который без потерь переживает
+// сериализацию в любом хранилище.
+func Order(uid schema.OrderUID) schema.Order {
+	created := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	return schema.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: schema.Delivery{
+			Name:   "Test Testov",
+			Phone:  "+9720000000",
+			Zip:    2639809,
+			City:   "Kiryat Mozkin",
+			Adress: "Ploshad Mira 15",
+			Region: "Kraiot",
+			Email:  "test@gmail.com",
+		},
+		Payment: schema.Payment{
+			Transaction:   string(uid),
+			Currency:      "USD",
+			Provider:      "wbpay",
+			Amount:        1817,
+			PaymentDT:     schema.NewTimestamp(created.Add(time.Minute)),
+			Bank:          "alpha",
+			DeliveryConst: 1500,
+			GoodsTotal:    317,
+		},
+		Items: schema.Items{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			RID:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        9,
+		SmID:            99,
+		DateCreated:     schema.NewTimestamp(created),
+		OofShard:        1,
+	}
+}
+
+func testNotFound(t *testing.T, db orderdb.OrderDB) {
+	_, err := db.GetOrder(context.Background(), "missing")
+	require.ErrorIs(t, err, orderdb.ErrNotFound)
+
+	orders, err := db.ListOrders(context.Background(), orderdb.Filter{})
+	require.NoError(t, err)
+	require.Empty(t, orders)
+}
+
+func testAddGet(t *testing.T, db orderdb.OrderDB) {
+	ctx := context.Background()
+	order := Order("b563feb7b2b84b6test")
+	require.NoError(t, db.AddOrder(ctx, order, 1))
+
+	got, err := db.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.Equal(t, order, got)
+
+	_, err = db.GetOrder(ctx, "b563feb7b2b84b6")
+	require.ErrorIs(t, err, orderdb.ErrNotFound)
+}
+
+// Повторная публикация заказа заменяет сохраненные данные
+func testOverwrite(t *testing.T, db orderdb.OrderDB) {
+	ctx := context.Background()
+	order := Order("uid")
+	require.NoError(t, db.AddOrder(ctx, order, 1))
+
+	order.TrackNumber = "UPDATED"
+	require.NoError(t, db.AddOrder(ctx, order, 2))
+
+	got, err := db.GetOrder(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.Equal(t, "UPDATED", got.TrackNumber)
+
+	orders, err := db.ListOrders(ctx, orderdb.Filter{})
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+}
+
+// Номер сообщения - low-water mark: он не переходит через пропуск, а
+// после заполнения пропуска продвигается до конца обработанного участка.
+// Повторная и запоздавшая запись его не откатывает.
+func testSeqNumber(t *testing.T, db orderdb.OrderDB) {
+	ctx := context.Background()
+	seq, err := db.SeqNumber(ctx)
+	require.NoError(t, err)
+	require.Zero(t, seq)
+
+	for _, step := range []struct {
+		uid     schema.OrderUID
+		seq     schema.SeqNumber
+		want    schema.SeqNumber
+		wantErr error
+	}{
+		{"b", 2, 0, nil},
+		{"c", 3, 0, nil},
+		{"a", 1, 3, nil},
+		{"e", 5, 3, nil},
+		{"b", 2, 3, orderdb.ErrAlreadyProcessed},
+		{"e", 5, 3, orderdb.ErrAlreadyProcessed},
+	} {
+		require.ErrorIs(t, db.AddOrder(ctx, Order(step.uid), step.seq), step.wantErr)
+
+		seq, err := db.SeqNumber(ctx)
+		require.NoError(t, err)
+		require.Equal(t, step.want, seq, "after order %s", step.uid)
+	}
+
+	tracker, ok := db.(orderdb.SeqTracker)
+	if !ok {
+		return
+	}
+
+	progress, err := tracker.SeqProgress(ctx)
+	require.NoError(t, err)
+	require.Equal(t, orderdb.SeqProgress{
+		LowWaterMark:  3,
+		HighWaterMark: 5,
+		Processed:     []orderdb.SeqRange{{From: 5, To: 5}},
+		Gaps:          []orderdb.SeqRange{{From: 4, To: 4}},
+	}, progress)
+
+	// Перемещение за пропуск присоединяет следующий обработанный участок
+	if admin, ok := db.(orderdb.AdminOrderDB); ok {
+		require.NoError(t, admin.SetSeqNumber(ctx, 4))
+		seq, err = db.SeqNumber(ctx)
+		require.NoError(t, err)
+		require.Equal(t, schema.SeqNumber(5), seq)
+	}
+}
+
+// Сообщение, повторно доставленное после пропуска, не перезаписывает
+// заказ данными, устаревшими после следующих сообщений
+func testRedelivery(t *testing.T, db orderdb.OrderDB) {
+	ctx := context.Background()
+	require.NoError(t, db.AddOrder(ctx, Order("a"), 1))
+
+	v1, v2 := Order("x"), Order("x")
+	v1.TrackNumber, v2.TrackNumber = "v1", "v2"
+	require.NoError(t, db.AddOrder(ctx, v1, 3))
+	require.NoError(t, db.AddOrder(ctx, v2, 4))
+
+	// После перезапуска сообщения доставляются снова с пропуска 2
+	require.NoError(t, db.AddOrder(ctx, Order("b"), 2))
+	require.ErrorIs(t, db.AddOrder(ctx, v1, 3), orderdb.ErrAlreadyProcessed)
+
+	got, err := db.GetOrder(ctx, "x")
+	require.NoError(t, err)
+	require.Equal(t, "v2", got.TrackNumber)
+
+	seq, err := db.SeqNumber(ctx)
+	require.NoError(t, err)
+	require.Equal(t, schema.SeqNumber(4), seq)
+}
+
+// После Rewind повторно доставленные сообщения снова записываются, а
+// номера ниже начала повторной обработки остаются обработанными
+func testRewind(t *testing.T, db orderdb.OrderDB) {
+	tracker, ok := db.(orderdb.SeqTracker)
+	if !ok {
+		t.Skip("store does not track processed sequences")
+	}
+
+	ctx := context.Background()
+	v1, v2 := Order("x"), Order("x")
+	v1.TrackNumber, v2.TrackNumber = "v1", "v2"
+	require.NoError(t, db.AddOrder(ctx, Order("a"), 1))
+	require.NoError(t, db.AddOrder(ctx, v2, 2))
+	require.NoError(t, db.AddOrder(ctx, Order("c"), 3))
+	require.NoError(t, db.AddOrder(ctx, Order("e"), 5))
+
+	require.NoError(t, tracker.Rewind(ctx, 2))
+	seq, err := db.SeqNumber(ctx)
+	require.NoError(t, err)
+	require.Equal(t, schema.SeqNumber(1), seq)
+
+	require.ErrorIs(t, db.AddOrder(ctx, Order("a"), 1), orderdb.ErrAlreadyProcessed)
+	require.NoError(t, db.AddOrder(ctx, v1, 2))
+
+	got, err := db.GetOrder(ctx, "x")
+	require.NoError(t, err)
+	require.Equal(t, "v1", got.TrackNumber)
+
+	progress, err := tracker.SeqProgress(ctx)
+	require.NoError(t, err)
+	require.Equal(t, orderdb.NewSeqProgress(2, nil), progress)
+}
+
+func testListOrders(t *testing.T, db orderdb.OrderDB) {
+	ctx := context.Background()
+	for i, uid := range []schema.OrderUID{"c", "a", "b"} {
+		order := Order(uid)
+		if uid == "b" {
+			order.CustomerID = "other"
+			order.Payment.Currency = "RUB"
+		}
+		require.NoError(t, db.AddOrder(ctx, order, schema.SeqNumber(i+1)))
+	}
+
+	all, err := db.ListOrders(ctx, orderdb.Filter{})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []schema.OrderUID{"a", "b", "c"}, uids(all))
+
+	for _, tt := range []struct {
+		filter orderdb.Filter
+		want   []schema.OrderUID
+	}{
+		{orderdb.Filter{CustomerID: "test"}, []schema.OrderUID{"a", "c"}},
+		{orderdb.Filter{Currency: "RUB"}, []schema.OrderUID{"b"}},
+		{orderdb.Filter{DeliveryService: "meest", Locale: "en"}, []schema.OrderUID{"a", "b", "c"}},
+		{orderdb.Filter{TrackNumber: "missing"}, []schema.OrderUID{}},
+		{orderdb.Filter{
+			CreatedFrom: time.Date(2021, 11, 26, 0, 0, 0, 0, time.UTC),
+			CreatedTo:   time.Date(2021, 11, 27, 0, 0, 0, 0, time.UTC),
+		}, []schema.OrderUID{"a", "b", "c"}},
+		{orderdb.Filter{CreatedFrom: time.Date(2021, 11, 27, 0, 0, 0, 0, time.UTC)}, []schema.OrderUID{}},
+	} {
+		got, err := db.ListOrders(ctx, tt.filter)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, uids(got), "filter %+v", tt.filter)
+	}
+}
+
+// Телефон находится независимо от пробелов, скобок и дефисов в запросе
+// и в сохраненном заказе. Хранилища без поиска пропускают проверку.
+func testPhoneSearch(t *testing.T, db orderdb.OrderDB) {
+	searcher, ok := db.(orderdb.Searcher)
+	if !ok {
+		t.Skip("store does not implement orderdb.Searcher")
+	}
+
+	ctx := context.Background()
+	for i, uid := range []schema.OrderUID{"a", "b"} {
+		order := Order(uid)
+		if uid == "b" {
+			order.Delivery.Phone = "+7 (912) 000-00-00"
+		}
+		require.NoError(t, db.AddOrder(ctx, order, schema.SeqNumber(i+1)))
+	}
+
+	for _, query := range []string{"+7 912 000 00 00", "79120000000", "+7-912-000-0000"} {
+		res, err := searcher.Search(ctx, query, 10)
+		require.NoError(t, err)
+
+		got := make([]schema.Order, 0, len(res))
+		for _, r := range res {
+			got = append(got, r.Order)
+		}
+		require.Equal(t, []schema.OrderUID{"b"}, uids(got), "query %q", query)
+	}
+}
+
+// С фильтром результаты упорядочены по order_uid, поэтому страницы не
+// пересекаются
+func testListPaging(t *testing.T, db orderdb.OrderDB) {
+	ctx := context.Background()
+	for i := 5; i > 0; i-- {
+		require.NoError(t, db.AddOrder(ctx, Order(schema.OrderUID(fmt.Sprintf("order-%d", i))), schema.SeqNumber(i)))
+	}
+
+	var pages [][]schema.OrderUID
+	for offset := 0; offset < 6; offset += 2 {
+		got, err := db.ListOrders(ctx, orderdb.Filter{CustomerID: "test", Limit: 2, Offset: offset})
+		require.NoError(t, err)
+		pages = append(pages, uids(got))
+	}
+
+	require.Equal(t, [][]schema.OrderUID{
+		{"order-1", "order-2"},
+		{"order-3", "order-4"},
+		{"order-5"},
+	}, pages)
+}
+
+// Одновременная запись из нескольких горутин: ни один заказ не теряется,
+// после обработки всех сообщений номер равен наибольшему
+func testConcurrent(t *testing.T, db orderdb.OrderDB) {
+	const (
+		workers   = 8
+		perWorker = 10
+	)
+
+	ctx := context.Background()
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, workers*perWorker)
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				seq := schema.SeqNumber(w*perWorker + i + 1)
+				uid := schema.OrderUID(fmt.Sprintf("order-%03d", seq))
+				errs <- db.AddOrder(ctx, Order(uid), seq)
+				if _, err := db.GetOrder(ctx, uid); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	orders, err := db.ListOrders(ctx, orderdb.Filter{})
+	require.NoError(t, err)
+	require.Len(t, orders, workers*perWorker)
+
+	seq, err := db.SeqNumber(ctx)
+	require.NoError(t, err)
+	require.Equal(t, schema.SeqNumber(workers*perWorker), seq)
+}
+
+func uids(orders []schema.Order) []schema.OrderUID {
+	ret := make([]schema.OrderUID, 0, len(orders))
+	for _, order := range orders {
+		ret = append(ret, order.OrderUID)
+	}
+	return ret
+}
@@ -0,0 +1,126 @@
+package orderpsql
+
+import (
+	"context"
+	"fmt"
+	"orderservice/internal/analytics"
+	"time"
+)
+
+var groupExprs = map[analytics.GroupBy]string{
+	analytics.GroupByDay:             `to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')`,
+	analytics.GroupByCurrency:        `data->'Payment'->>'currency'`,
+	analytics.GroupByDeliveryService: `data->>'delivery_service'`,
+	analytics.GroupByRegion:          `data->'Delivery'->>'region'`,
+	analytics.GroupByBank:            `data->'Payment'->>'bank'`,
+}
+
+// Суммы считаются отдельно по каждой валюте платежа, пересчет
+// в валюту отчета выполняет analytics.Service
+const aggregateQuery = `SELECT coalesce(%[1]s, '') AS key,
+		coalesce(data->'Payment'->>'currency', '') AS currency,
+		count(*),
+		coalesce(sum((data->'Payment'->>'amount')::bigint), 0),
+		coalesce(sum((data->'Payment'->>'delivery_cost')::bigint), 0)
+	FROM orderDB
+	WHERE created_at >= $1 AND created_at < $2
+	GROUP BY key, currency
+	ORDER BY key, currency`
+
+const topBrandsQuery = `SELECT key, brand, items FROM (
+		SELECT coalesce(%[1]s, '') AS key,
+			item->>'brand' AS brand,
+			count(*) AS items,
+			row_number() OVER (PARTITION BY coalesce(%[1]s, '')
+				ORDER BY count(*) DESC, item->>'brand') AS rank
+		FROM orderDB, jsonb_array_elements(order_items(data)) AS item
+		WHERE created_at >= $1 AND created_at < $2
+			AND coalesce(item->>'brand', '') <> ''
+		GROUP BY key, brand
+	) AS brands
+	WHERE rank <= $3`
+
+// Aggregate вычисляет аналитику на стороне базы.
+func (p *Postgres) Aggregate(ctx context.Context, q analytics.Query) ([]analytics.Bucket, error) {
+	expr, ok := groupExprs[q.GroupBy]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown group_by %q", analytics.ErrInvalidQuery, q.GroupBy)
+	}
+
+	to := q.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	res, err := p.deps.PGX.Query(ctx, fmt.Sprintf(aggregateQuery, expr), q.From, to)
+	if err != nil {
+		p.log.Errorf("failed to aggregate: %v", err)
+		return nil, err
+	}
+	defer res.Close()
+
+	buckets := make([]analytics.Bucket, 0)
+	byKey := make(map[string]int)
+	for res.Next() {
+		var (
+			key, currency        string
+			orders               int
+			amount, deliveryCost int64
+		)
+
+		if err := res.Scan(&key, &currency, &orders, &amount, &deliveryCost); err != nil {
+			p.log.Errorf("Scan failed: %v", err)
+			return nil, err
+		}
+
+		i, ok := byKey[key]
+		if !ok {
+			i = len(buckets)
+			byKey[key] = i
+			buckets = append(buckets, analytics.Bucket{
+				Key:       key,
+				TopBrands: make([]analytics.BrandCount, 0),
+			})
+		}
+
+		buckets[i].Orders += orders
+		buckets[i].AddAmounts(currency, amount, deliveryCost)
+	}
+
+	if err := res.Err(); err != nil {
+		return nil, err
+	}
+	res.Close()
+
+	brands, err := p.deps.PGX.Query(ctx, fmt.Sprintf(topBrandsQuery, expr), q.From, to, q.TopBrands)
+	if err != nil {
+		p.log.Errorf("failed to aggregate brands: %v", err)
+		return nil, err
+	}
+	defer brands.Close()
+
+	for brands.Next() {
+		var (
+			key   string
+			brand analytics.BrandCount
+		)
+
+		if err := brands.Scan(&key, &brand.Brand, &brand.Items); err != nil {
+			p.log.Errorf("Scan failed: %v", err)
+			return nil, err
+		}
+
+		if i, ok := byKey[key]; ok {
+			buckets[i].TopBrands = append(buckets[i].TopBrands, brand)
+		}
+	}
+
+	for i := range buckets {
+		buckets[i].SortTopBrands(q.TopBrands)
+	}
+
+	return buckets, brands.Err()
+}
@@ -5,21 +5,48 @@ import (
 	"encoding/json"
 	"errors"
 	"orderservice/internal/orderdb"
+	"orderservice/internal/orderevent"
 	"orderservice/internal/provider/pgxprovider"
 	"orderservice/internal/schema"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/sirupsen/logrus"
 )
 
 type Config struct {
 	QueryTimeout time.Duration
+	// OutboxTargets - получатели событий order.accepted и order.rejected,
+	// см. internal/outbox. Для каждого событие записывается в таблицу
+	// outbox отдельной строкой в одной транзакции с заказом. Пустой список
+	// отключает outbox.
+	OutboxTargets []string
 }
 
+// PGX - методы пула соединений, которые использует Postgres. Реализуется
+// pgxprovider.PGXProvider, в тестах - pgxmock.
+type PGX interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+var _ PGX = (*pgxprovider.PGXProvider)(nil)
+
+var (
+	_ orderdb.Pinger     = (*Postgres)(nil)
+	_ orderdb.SeqTracker = (*Postgres)(nil)
+)
+
 type Dependencies struct {
 	Log *logrus.Logger
-	PGX *pgxprovider.PGXProvider
+	PGX PGX
+	// Registry decodes rows stored in older schema versions,
+	// lenient by default
+	Registry *schema.Registry
 }
 
 type Postgres struct {
@@ -29,7 +56,11 @@ type Postgres struct {
 	log *logrus.Entry
 }
 
-func New(cfg Config, deps Dependencies) orderdb.OrderDB {
+func New(cfg Config, deps Dependencies) *Postgres {
+	if deps.Registry == nil {
+		deps.Registry = schema.NewRegistry(schema.Lenient)
+	}
+
 	return &Postgres{
 		cfg:  cfg,
 		deps: deps,
@@ -77,35 +108,278 @@ func (p *Postgres) AddOrder(ctx context.Context, order schema.Order, seq schema.
 		p.log.Errorf("failed to create transaction: %v", err)
 		return err
 	}
+	// Без отката при ошибке соединение не вернется в пул
+	defer txn.Rollback(context.Background()) //nolint:errcheck
 
-	_, err = txn.Exec(ctx, `INSERT INTO orderDB (order_uid, data)
-		VALUES ($1, $2)`, order.OrderUID, data)
+	if err := p.beginMessage(ctx, txn, seq); err != nil {
+		return err
+	}
+
+	// Повторная публикация заказа (например, через ordersctl republish)
+	// перезаписывает сохраненные данные, а не блокирует очередь ошибкой
+	_, err = txn.Exec(ctx, `INSERT INTO orderDB (order_uid, data, schema_version, created_at, paid_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (order_uid) DO UPDATE SET data = EXCLUDED.data,
+			schema_version = EXCLUDED.schema_version,
+			created_at = EXCLUDED.created_at, paid_at = EXCLUDED.paid_at`,
+		order.OrderUID, data, schema.CurrentVersion,
+		order.DateCreated.Ptr(), order.Payment.PaymentDT.Ptr())
 	if err != nil {
 		p.log.Errorf("failed to insert: %v", err)
 		return err
 	}
 
-	_, err = txn.Exec(ctx, `UPDATE seqDB SET seq = $1 WHERE seq < $1`, seq)
+	if err := p.finishMessage(ctx, txn, orderevent.NewEvent(orderevent.EventOrderAccepted,
+		order.OrderUID, seq, "")); err != nil {
+		return err
+	}
+
+	p.log.Infof("order added: %s", order.OrderUID)
+	return nil
+}
+
+// RejectOrder сохраняет номер сообщения с некорректным заказом и событие
+// order.rejected, не записывая сам заказ.
+func (p *Postgres) RejectOrder(ctx context.Context, orderUID schema.OrderUID, reason string, seq schema.SeqNumber) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	txn, err := p.deps.PGX.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		p.log.Errorf("failed to create transaction: %v", err)
+		return err
+	}
+	defer txn.Rollback(context.Background()) //nolint:errcheck
+
+	if err := p.beginMessage(ctx, txn, seq); err != nil {
+		return err
+	}
+
+	if err := p.finishMessage(ctx, txn, orderevent.NewEvent(orderevent.EventOrderRejected,
+		orderUID, seq, reason)); err != nil {
+		return err
+	}
+
+	p.log.Infof("order rejected: %q: %s", orderUID, reason)
+	return nil
+}
+
+// beginMessage записывает номер сообщения в processed_seqs. Уникальный
+// номер не дает двум транзакциям обработать одно сообщение, строка seqDB
+// при этом не блокируется, поэтому сообщения разных заказов записываются
+// параллельно. Для повторно доставленного сообщения возвращается
+// orderdb.ErrAlreadyProcessed, иначе оно перезаписало бы заказ данными,
+// устаревшими после следующих сообщений.
+func (p *Postgres) beginMessage(ctx context.Context, txn pgx.Tx, seq schema.SeqNumber) error {
+	tag, err := txn.Exec(ctx, `INSERT INTO processed_seqs (seq)
+		SELECT $1::BIGINT WHERE $1::BIGINT > (SELECT seq FROM seqDB WHERE id = 1)
+		ON CONFLICT (seq) DO NOTHING`, seq)
 	if err != nil {
 		p.log.Errorf("failed to save seq number: %v", err)
 		return err
 	}
 
+	if tag.RowsAffected() == 0 {
+		p.log.Infof("message %d is already processed", seq)
+		return orderdb.ErrAlreadyProcessed
+	}
+	return nil
+}
+
+// finishMessage записывает событие outbox, фиксирует транзакцию и
+// продвигает low-water mark. Вызывается после beginMessage.
+func (p *Postgres) finishMessage(ctx context.Context, txn pgx.Tx, event orderevent.Event) error {
+	for _, target := range p.cfg.OutboxTargets {
+		if err := insertEvent(ctx, txn, target, event); err != nil {
+			p.log.Errorf("failed to write outbox event: %v", err)
+			return err
+		}
+	}
+
 	if err := txn.Commit(ctx); err != nil {
 		p.log.Errorf("failed to commit order transaction: %v", err)
 		return err
 	}
 
-	p.log.Infof("order added: %s", order.OrderUID)
+	// Сообщение уже сохранено: если отметка не сдвинется сейчас, ее
+	// сдвинет обработка следующего сообщения
+	if err := p.advance(ctx); err != nil {
+		p.log.Errorf("failed to advance seq number: %v", err)
+	}
+	return nil
+}
+
+// advance продвигает low-water mark в отдельной короткой транзакции,
+// строка seqDB блокируется только на время ее обновления.
+func (p *Postgres) advance(ctx context.Context) error {
+	txn, err := p.deps.PGX.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback(context.Background()) //nolint:errcheck
+
+	if err := advanceMark(ctx, txn); err != nil {
+		return err
+	}
+	return txn.Commit(ctx)
+}
+
+// advanceMark переносит непрерывный участок номеров, начинающийся сразу
+// после low-water mark, из processed_seqs в seqDB. Участок ищется выше
+// текущего значения, поэтому обновление, дождавшееся блокировки строки
+// после другого, не уменьшает его.
+func advanceMark(ctx context.Context, txn pgx.Tx) error {
+	_, err := txn.Exec(ctx, `UPDATE seqDB SET seq = (
+			SELECT MIN(p.seq) FROM processed_seqs p
+			WHERE p.seq > seqDB.seq
+				AND NOT EXISTS (SELECT 1 FROM processed_seqs q WHERE q.seq = p.seq + 1))
+		WHERE id = 1 AND EXISTS (SELECT 1 FROM processed_seqs WHERE seq = seqDB.seq + 1)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = txn.Exec(ctx, `DELETE FROM processed_seqs
+		WHERE seq <= (SELECT seq FROM seqDB WHERE id = 1)`)
+	return err
+}
+
+// SeqProgress возвращает low-water mark и участки обработанных номеров
+// выше него.
+func (p *Postgres) SeqProgress(ctx context.Context) (orderdb.SeqProgress, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	var mark schema.SeqNumber
+	if err := p.deps.PGX.QueryRow(ctx, `SELECT seq FROM seqDB WHERE id = 1`).Scan(&mark); err != nil {
+		p.log.Errorf("failed to select seq number: %v", err)
+		return orderdb.SeqProgress{}, err
+	}
+
+	// Номера одного участка имеют одинаковую разность с порядковым номером
+	res, err := p.deps.PGX.Query(ctx, `SELECT MIN(seq), MAX(seq) FROM (
+			SELECT seq, seq - ROW_NUMBER() OVER (ORDER BY seq) AS island FROM processed_seqs) s
+		GROUP BY island ORDER BY 1`)
+	if err != nil {
+		p.log.Errorf("failed to select processed seq numbers: %v", err)
+		return orderdb.SeqProgress{}, err
+	}
+	defer res.Close()
+
+	var processed []orderdb.SeqRange
+	for res.Next() {
+		var r orderdb.SeqRange
+		if err := res.Scan(&r.From, &r.To); err != nil {
+			p.log.Errorf("scan failed: %v", err)
+			return orderdb.SeqProgress{}, err
+		}
+		processed = append(processed, r)
+	}
+	if err := res.Err(); err != nil {
+		return orderdb.SeqProgress{}, err
+	}
+
+	return orderdb.NewSeqProgress(mark, processed), nil
+}
+
+// Rewind забывает номера начиная с from и опускает low-water mark ниже
+// него, чтобы повторно доставленные сообщения были записаны заново.
+func (p *Postgres) Rewind(ctx context.Context, from schema.SeqNumber) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	txn, err := p.deps.PGX.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		p.log.Errorf("failed to create transaction: %v", err)
+		return err
+	}
+	defer txn.Rollback(context.Background()) //nolint:errcheck
+
+	before := max(from, 1) - 1
+	if _, err := txn.Exec(ctx, `UPDATE seqDB SET seq = LEAST(seq, $1) WHERE id = 1`, before); err != nil {
+		p.log.Errorf("failed to rewind seq number: %v", err)
+		return err
+	}
+
+	if _, err := txn.Exec(ctx, `DELETE FROM processed_seqs WHERE seq > $1`, before); err != nil {
+		p.log.Errorf("failed to clear processed seq numbers: %v", err)
+		return err
+	}
+
+	if err := txn.Commit(ctx); err != nil {
+		p.log.Errorf("failed to commit seq number: %v", err)
+		return err
+	}
+
+	p.log.Infof("seq numbers rewound to %d", before)
 	return nil
 }
 
+// ImportOrders записывает пачку заказов одной транзакцией, не изменяя
+// номер последнего обработанного сообщения. Уже существующие заказы
+// пропускаются, поэтому повторный импорт безопасен. Возвращает количество
+// добавленных заказов.
+func (p *Postgres) ImportOrders(ctx context.Context, orders []schema.Order) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	batch := &pgx.Batch{}
+	for _, order := range orders {
+		data, err := json.Marshal(order)
+		if err != nil {
+			return 0, err
+		}
+
+		batch.Queue(`INSERT INTO orderDB (order_uid, data, schema_version, created_at, paid_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (order_uid) DO NOTHING`,
+			order.OrderUID, data, schema.CurrentVersion,
+			order.DateCreated.Ptr(), order.Payment.PaymentDT.Ptr())
+	}
+
+	txn, err := p.deps.PGX.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		p.log.Errorf("failed to create transaction: %v", err)
+		return 0, err
+	}
+	defer txn.Rollback(context.Background()) //nolint:errcheck
+
+	res := txn.SendBatch(ctx, batch)
+	inserted := 0
+	for range orders {
+		tag, err := res.Exec()
+		if err != nil {
+			res.Close()
+			p.log.Errorf("failed to import: %v", err)
+			return 0, err
+		}
+
+		inserted += int(tag.RowsAffected())
+	}
+
+	if err := res.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := txn.Commit(ctx); err != nil {
+		p.log.Errorf("failed to commit import transaction: %v", err)
+		return 0, err
+	}
+
+	p.log.Infof("orders imported: %d of %d", inserted, len(orders))
+	return inserted, nil
+}
+
 func (p *Postgres) GetOrder(ctx context.Context, orderUID schema.OrderUID) (schema.Order, error) {
 	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
 	defer cancel()
 
-	res, err := p.deps.PGX.Query(ctx, `SELECT data FROM orderDB
-		WHERE order_uid = &1)`, orderUID)
+	var (
+		data    []byte
+		version int
+	)
+
+	err := p.deps.PGX.QueryRow(ctx, `SELECT data, schema_version FROM orderDB
+		WHERE order_uid = $1`, orderUID).Scan(&data, &version)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return schema.Order{}, orderdb.ErrNotFound
 	} else if err != nil {
@@ -113,48 +387,158 @@ func (p *Postgres) GetOrder(ctx context.Context, orderUID schema.OrderUID) (sche
 		return schema.Order{}, err
 	}
 
-	var data []byte
-	if err = res.Scan(&data); err != nil {
-		return schema.Order{}, err
-	}
+	return p.decode(orderUID, version, data)
+}
 
-	var order schema.Order
-	if err := json.Unmarshal(data, &order); err != nil {
-		return schema.Order{}, err
+func (p *Postgres) ListOrders(ctx context.Context, filter orderdb.Filter) ([]schema.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	ret := make([]schema.Order, 0)
+	err := p.queryOrders(ctx, filter, func(order schema.Order) error {
+		ret = append(ret, order)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return order, nil
+	return ret, nil
 }
 
-func (p *Postgres) ListOrders(ctx context.Context) ([]schema.Order, error) {
-	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
-	defer cancel()
+// StreamOrders передает заказы в fn по одному по мере чтения из базы.
+// QueryTimeout не применяется, длительность ограничивается только ctx.
+func (p *Postgres) StreamOrders(ctx context.Context, filter orderdb.Filter,
+	fn func(schema.Order) error) error {
+	return p.queryOrders(ctx, filter, fn)
+}
 
-	res, err := p.deps.PGX.Query(ctx, "SELECT data FROM orderDB")
+func (p *Postgres) queryOrders(ctx context.Context, filter orderdb.Filter,
+	fn func(schema.Order) error) error {
+	query, args := listQuery(filter)
+	res, err := p.deps.PGX.Query(ctx, query, args...)
 	if err != nil {
 		p.log.Errorf("failed to list: %v", err)
-		return nil, err
+		return err
 	}
 	defer res.Close()
 
-	ret := make([]schema.Order, 0)
 	for res.Next() {
 		var (
-			data  []byte
-			order schema.Order
+			uid     schema.OrderUID
+			data    []byte
+			version int
 		)
 
-		if err = res.Scan(&data); err != nil {
+		if err = res.Scan(&uid, &data, &version); err != nil {
 			p.log.Errorf("Scan failed: %v", err)
-			return nil, err
+			return err
 		}
 
-		if err := json.Unmarshal(data, &order); err != nil {
-			return nil, err
+		order, err := p.decode(uid, version, data)
+		if err != nil {
+			return err
 		}
 
-		ret = append(ret, order)
+		if err := fn(order); err != nil {
+			return err
+		}
 	}
 
-	return ret, nil
+	return res.Err()
+}
+
+func (p *Postgres) DeleteOrder(ctx context.Context, orderUID schema.OrderUID) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	tag, err := p.deps.PGX.Exec(ctx, `DELETE FROM orderDB WHERE order_uid = $1`, orderUID)
+	if err != nil {
+		p.log.Errorf("failed to delete: %v", err)
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return orderdb.ErrNotFound
+	}
+
+	p.log.Infof("order deleted: %s", orderUID)
+	return nil
+}
+
+func (p *Postgres) AnonymizeOrder(ctx context.Context, orderUID schema.OrderUID) error {
+	order, err := p.GetOrder(ctx, orderUID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(order.Anonymized())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	tag, err := p.deps.PGX.Exec(ctx, `UPDATE orderDB SET data = $2, schema_version = $3
+		WHERE order_uid = $1`, orderUID, data, schema.CurrentVersion)
+	if err != nil {
+		p.log.Errorf("failed to anonymize: %v", err)
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return orderdb.ErrNotFound
+	}
+
+	p.log.Infof("order anonymized: %s", orderUID)
+	return nil
+}
+
+// SetSeqNumber перезаписывает low-water mark, в том числе на меньшее
+// значение. Обработанные номера не выше него забываются, участок сразу
+// за ним присоединяется к нему.
+func (p *Postgres) SetSeqNumber(ctx context.Context, seq schema.SeqNumber) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	txn, err := p.deps.PGX.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		p.log.Errorf("failed to create transaction: %v", err)
+		return err
+	}
+	defer txn.Rollback(context.Background()) //nolint:errcheck
+
+	if _, err := txn.Exec(ctx, `UPDATE seqDB SET seq = $1 WHERE id = 1`, seq); err != nil {
+		p.log.Errorf("failed to set seq number: %v", err)
+		return err
+	}
+
+	if _, err := txn.Exec(ctx, `DELETE FROM processed_seqs WHERE seq <= $1`, seq); err != nil {
+		p.log.Errorf("failed to clear processed seq numbers: %v", err)
+		return err
+	}
+
+	if err := advanceMark(ctx, txn); err != nil {
+		p.log.Errorf("failed to advance seq number: %v", err)
+		return err
+	}
+
+	if err := txn.Commit(ctx); err != nil {
+		p.log.Errorf("failed to commit seq number: %v", err)
+		return err
+	}
+
+	p.log.Infof("seq number set: %d", seq)
+	return nil
+}
+
+// Ping проверяет соединение с базой, по нему Consumer возобновляет
+// подписку после ошибок хранилища.
+func (p *Postgres) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	_, err := p.deps.PGX.Exec(ctx, `SELECT 1`)
+	return err
 }
@@ -0,0 +1,220 @@
+package orderpsql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderdb/orderdbtest"
+	"orderservice/internal/schema"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func newMock(t *testing.T) (*Postgres, pgxmock.PgxPoolIface) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, mock.ExpectationsWereMet())
+		mock.Close()
+	})
+
+	return New(Config{QueryTimeout: time.Second}, Dependencies{
+		Log: logrus.New(),
+		PGX: mock,
+	}), mock
+}
+
+func TestMockGetOrder(t *testing.T) {
+	order := orderdbtest.Order("uid")
+	data, err := json.Marshal(order)
+	require.NoError(t, err)
+
+	query := regexp.QuoteMeta(`SELECT data, schema_version FROM orderDB
+		WHERE order_uid = $1`)
+
+	cases := []struct {
+		name    string
+		setup   func(mock pgxmock.PgxPoolIface)
+		want    schema.Order
+		wantErr error
+	}{
+		{
+			name: "found",
+			setup: func(mock pgxmock.PgxPoolIface) {
+				mock.ExpectQuery(query).WithArgs(schema.OrderUID("uid")).
+					WillReturnRows(pgxmock.NewRows([]string{"data", "schema_version"}).
+						AddRow(data, schema.CurrentVersion))
+			},
+			want: order,
+		},
+		{
+			name: "not_found",
+			setup: func(mock pgxmock.PgxPoolIface) {
+				mock.ExpectQuery(query).WithArgs(schema.OrderUID("uid")).
+					WillReturnError(pgx.ErrNoRows)
+			},
+			wantErr: orderdb.ErrNotFound,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			c.setup(mock)
+
+			got, err := db.GetOrder(context.Background(), "uid")
+			require.ErrorIs(t, err, c.wantErr)
+			require.Equal(t, c.want, got)
+		})
+	}
+}
+
+var errConnReset = errors.New("connection reset")
+
+// Заказ и номер сообщения записываются в одной транзакции, повторно
+// доставленное сообщение не перезаписывает заказ. Low-water mark
+// продвигается после фиксации отдельной транзакцией.
+func TestMockAddOrder(t *testing.T) {
+	order := orderdbtest.Order("uid")
+	claim := func(mock pgxmock.PgxPoolIface, rows int64) {
+		mock.ExpectBeginTx(pgx.TxOptions{})
+		mock.ExpectExec("INSERT INTO processed_seqs").
+			WithArgs(schema.SeqNumber(7)).
+			WillReturnResult(pgxmock.NewResult("INSERT", rows))
+	}
+	insertOrder := func(mock pgxmock.PgxPoolIface) *pgxmock.ExpectedExec {
+		return mock.ExpectExec("INSERT INTO orderDB").
+			WithArgs(order.OrderUID, pgxmock.AnyArg(), schema.CurrentVersion,
+				order.DateCreated.Ptr(), order.Payment.PaymentDT.Ptr())
+	}
+	advance := func(mock pgxmock.PgxPoolIface) {
+		mock.ExpectBeginTx(pgx.TxOptions{})
+		mock.ExpectExec("UPDATE seqDB SET seq").
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		mock.ExpectExec("DELETE FROM processed_seqs").
+			WillReturnResult(pgxmock.NewResult("DELETE", 1))
+		mock.ExpectCommit()
+	}
+
+	cases := []struct {
+		name    string
+		setup   func(mock pgxmock.PgxPoolIface)
+		wantErr error
+	}{
+		{
+			name: "success",
+			setup: func(mock pgxmock.PgxPoolIface) {
+				claim(mock, 1)
+				insertOrder(mock).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				mock.ExpectCommit()
+				advance(mock)
+			},
+		},
+		{
+			name: "already_processed",
+			setup: func(mock pgxmock.PgxPoolIface) {
+				claim(mock, 0)
+				mock.ExpectRollback()
+			},
+			wantErr: orderdb.ErrAlreadyProcessed,
+		},
+		{
+			name: "order_failed",
+			setup: func(mock pgxmock.PgxPoolIface) {
+				claim(mock, 1)
+				insertOrder(mock).WillReturnError(errConnReset)
+				mock.ExpectRollback()
+			},
+			wantErr: errConnReset,
+		},
+		{
+			// Заказ сохранен, отметку сдвинет следующее сообщение
+			name: "advance_failed",
+			setup: func(mock pgxmock.PgxPoolIface) {
+				claim(mock, 1)
+				insertOrder(mock).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				mock.ExpectCommit()
+				mock.ExpectBeginTx(pgx.TxOptions{})
+				mock.ExpectExec("UPDATE seqDB SET seq").WillReturnError(errConnReset)
+				mock.ExpectRollback()
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			db, mock := newMock(t)
+			c.setup(mock)
+
+			err := db.AddOrder(context.Background(), order, 7)
+			require.ErrorIs(t, err, c.wantErr)
+		})
+	}
+}
+
+func TestMockSeqNumber(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT seq FROM seqDB WHERE id = 1`)).
+		WillReturnRows(pgxmock.NewRows([]string{"seq"}).AddRow(schema.SeqNumber(42)))
+
+	seq, err := db.SeqNumber(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, schema.SeqNumber(42), seq)
+}
+
+func TestMockListOrders(t *testing.T) {
+	db, mock := newMock(t)
+
+	rows := pgxmock.NewRows([]string{"order_uid", "data", "schema_version"})
+	for _, uid := range []schema.OrderUID{"a", "b"} {
+		data, err := json.Marshal(orderdbtest.Order(uid))
+		require.NoError(t, err)
+		rows.AddRow(uid, data, schema.CurrentVersion)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT order_uid, data, schema_version FROM orderDB` +
+		` WHERE data->>'customer_id' = $1 ORDER BY order_uid LIMIT $2 OFFSET $3`)).
+		WithArgs("test", 2, 4).
+		WillReturnRows(rows)
+
+	orders, err := db.ListOrders(context.Background(), orderdb.Filter{CustomerID: "test", Limit: 2, Offset: 4})
+	require.NoError(t, err)
+	require.Equal(t, []schema.Order{orderdbtest.Order("a"), orderdbtest.Order("b")}, orders)
+}
+
+func TestMockPing(t *testing.T) {
+	db, mock := newMock(t)
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT 1`)).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT 1`)).
+		WillReturnError(errors.New("connection refused"))
+
+	require.NoError(t, db.Ping(context.Background()))
+	require.Error(t, db.Ping(context.Background()))
+}
+
+// Запрос-телефон сравнивается с телефоном заказа только по цифрам
+func TestMockSearchPhone(t *testing.T) {
+	order := orderdbtest.Order("uid")
+	data, err := json.Marshal(order)
+	require.NoError(t, err)
+
+	db, mock := newMock(t)
+	mock.ExpectQuery(regexp.QuoteMeta(phoneSearchQuery)).
+		WithArgs("79120000000", 10).
+		WillReturnRows(pgxmock.NewRows([]string{"order_uid", "data", "schema_version", "rank"}).
+			AddRow(order.OrderUID, data, schema.CurrentVersion, 1.0))
+
+	res, err := db.Search(context.Background(), "+7 (912) 000-00-00", 10)
+	require.NoError(t, err)
+	require.Equal(t, []orderdb.SearchResult{{Order: order, Rank: 1}}, res)
+}
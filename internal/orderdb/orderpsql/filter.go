@@ -0,0 +1,70 @@
+package orderpsql
+
+import (
+	"fmt"
+	"orderservice/internal/orderdb"
+	"strings"
+)
+
+// Пути к полям в JSONB документе заказа
+const (
+	customerIDField      = `data->>'customer_id'`
+	trackNumberField     = `data->>'track_number'`
+	deliveryServiceField = `data->>'delivery_service'`
+	currencyField        = `data->'Payment'->>'currency'`
+	localeField          = `data->>'locale'`
+)
+
+// Столбец с временем создания заказа, заполняется при записи
+const createdAtColumn = `created_at`
+
+func whereClause(filter orderdb.Filter, args []any) (string, []any) {
+	conds := make([]string, 0)
+	add := func(field, value string) {
+		if value == "" {
+			return
+		}
+
+		args = append(args, value)
+		conds = append(conds, fmt.Sprintf("%s = $%d", field, len(args)))
+	}
+
+	add(customerIDField, filter.CustomerID)
+	add(trackNumberField, filter.TrackNumber)
+	add(deliveryServiceField, filter.DeliveryService)
+	add(currencyField, filter.Currency)
+	add(localeField, filter.Locale)
+
+	if !filter.CreatedFrom.IsZero() {
+		args = append(args, filter.CreatedFrom)
+		conds = append(conds, fmt.Sprintf("%s >= $%d", createdAtColumn, len(args)))
+	}
+
+	if !filter.CreatedTo.IsZero() {
+		args = append(args, filter.CreatedTo)
+		conds = append(conds, fmt.Sprintf("%s < $%d", createdAtColumn, len(args)))
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+func listQuery(filter orderdb.Filter) (string, []any) {
+	where, args := whereClause(filter, nil)
+	query := "SELECT order_uid, data, schema_version FROM orderDB" + where + " ORDER BY order_uid"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	return query, args
+}
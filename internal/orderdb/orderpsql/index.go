@@ -0,0 +1,69 @@
+package orderpsql
+
+import (
+	"context"
+	"fmt"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/schema"
+)
+
+// Запросы используют выражения, по которым построены индексы в init.sql.
+// Items в старых заказах хранится объектом, поэтому rid ищется
+// вхождением и в массив, и в объект.
+var findQueries = map[orderdb.Key]string{
+	orderdb.KeyTrackNumber: `SELECT order_uid, data, schema_version FROM orderDB WHERE data->>'track_number' = $1 ORDER BY order_uid`,
+	orderdb.KeyTransaction: `SELECT order_uid, data, schema_version FROM orderDB WHERE data->'Payment'->>'transaction' = $1 ORDER BY order_uid`,
+	orderdb.KeyCustomerID:  `SELECT order_uid, data, schema_version FROM orderDB WHERE data->>'customer_id' = $1 ORDER BY order_uid`,
+	orderdb.KeyRID: `SELECT order_uid, data, schema_version FROM orderDB
+		WHERE data->'Items' @> jsonb_build_array(jsonb_build_object('rid', $1::text))
+			OR data->'Items' @> jsonb_build_object('rid', $1::text)
+		ORDER BY order_uid`,
+}
+
+func (p *Postgres) FindOrders(ctx context.Context, key orderdb.Key, value string) ([]schema.Order, error) {
+	query, ok := findQueries[key]
+	if !ok {
+		return nil, fmt.Errorf("unsupported lookup key: %s", key)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	res, err := p.deps.PGX.Query(ctx, query, value)
+	if err != nil {
+		p.log.Errorf("failed to find by %s: %v", key, err)
+		return nil, err
+	}
+	defer res.Close()
+
+	ret := make([]schema.Order, 0)
+	for res.Next() {
+		var (
+			uid     schema.OrderUID
+			data    []byte
+			version int
+		)
+
+		if err := res.Scan(&uid, &data, &version); err != nil {
+			p.log.Errorf("Scan failed: %v", err)
+			return nil, err
+		}
+
+		order, err := p.decode(uid, version, data)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, order)
+	}
+
+	if err := res.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ret) == 0 {
+		return nil, orderdb.ErrNotFound
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,92 @@
+package orderpsql
+
+import (
+	"context"
+	"encoding/json"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/outbox"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// insertEvent добавляет событие получателя target в outbox. Повторная
+// обработка того же сообщения дает событие с тем же ID, которое уже
+// записано.
+func insertEvent(ctx context.Context, txn pgx.Tx, target string, event orderevent.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = txn.Exec(ctx, `INSERT INTO outbox (event_id, target, event_type, order_uid, payload)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (event_id, target) DO NOTHING`,
+		event.ID, target, event.Type, event.OrderUID, payload)
+	return err
+}
+
+func (p *Postgres) PendingEvents(ctx context.Context, target string, after int64, limit int) ([]outbox.Record, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	rows, err := p.deps.PGX.Query(ctx, `SELECT id, payload, attempts FROM outbox
+		WHERE target = $1 AND id > $2 AND published_at IS NULL ORDER BY id LIMIT $3`,
+		target, after, limit)
+	if err != nil {
+		p.log.Errorf("failed to select outbox events: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []outbox.Record
+	for rows.Next() {
+		var (
+			rec     outbox.Record
+			payload []byte
+		)
+		if err := rows.Scan(&rec.ID, &payload, &rec.Attempts); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(payload, &rec.Event); err != nil {
+			return nil, err
+		}
+		ret = append(ret, rec)
+	}
+
+	return ret, rows.Err()
+}
+
+func (p *Postgres) MarkPublished(ctx context.Context, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	_, err := p.deps.PGX.Exec(ctx, `UPDATE outbox
+		SET published_at = now(), attempts = attempts + 1, last_error = NULL
+		WHERE id = $1`, id)
+	return err
+}
+
+func (p *Postgres) MarkFailed(ctx context.Context, id int64, reason string) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	_, err := p.deps.PGX.Exec(ctx, `UPDATE outbox
+		SET attempts = attempts + 1, last_error = $2
+		WHERE id = $1`, id, reason)
+	return err
+}
+
+func (p *Postgres) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	tag, err := p.deps.PGX.Exec(ctx,
+		`DELETE FROM outbox WHERE published_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,132 @@
+package orderpsql
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderdb/orderdbtest"
+	"orderservice/internal/provider/pgxprovider"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// TEST_POSTGRES_URL задает сервер для тестов с настоящей базой, каждый
+// тест получает в нем свою базу. Без переменной поднимается временный
+// экземпляр через initdb и pg_ctl, если они установлены.
+const testPostgresEnv = "TEST_POSTGRES_URL"
+
+func TestConformance(t *testing.T) {
+	server := testPostgres(t)
+	initSQL, err := os.ReadFile("../../../postgres/init.sql")
+	require.NoError(t, err)
+
+	var databases atomic.Int32
+	orderdbtest.Run(t, func(t *testing.T) orderdb.OrderDB {
+		ctx := context.Background()
+		name := fmt.Sprintf("orders_test_%d_%d", os.Getpid(), databases.Add(1))
+
+		admin := connect(t, server, "postgres")
+		_, err := admin.Exec(ctx, "CREATE DATABASE "+name)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_, _ = admin.Exec(context.Background(), "DROP DATABASE IF EXISTS "+name+" WITH (FORCE)")
+			admin.Close(context.Background())
+		})
+
+		pgxp := connect(t, server, name)
+		t.Cleanup(func() { pgxp.Close(context.Background()) })
+
+		// Без аргументов запрос выполняется простым протоколом, что
+		// позволяет передать весь файл сразу
+		_, err = pgxp.Exec(ctx, string(initSQL))
+		require.NoError(t, err)
+
+		return New(Config{QueryTimeout: 5 * time.Second}, Dependencies{
+			Log: logrus.New(),
+			PGX: pgxp,
+		})
+	})
+}
+
+func connect(t *testing.T, server *url.URL, database string) *pgxprovider.PGXProvider {
+	u := *server
+	u.Path = "/" + database
+
+	pgxp, err := pgxprovider.New(pgxprovider.Config{URL: u.String(), ConnectTimeout: 5 * time.Second})
+	require.NoError(t, err)
+	return pgxp
+}
+
+// testPostgres возвращает адрес сервера или пропускает тест, если
+// сервер недоступен.
+func testPostgres(t *testing.T) *url.URL {
+	if raw := os.Getenv(testPostgresEnv); raw != "" {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		return u
+	}
+
+	if testing.Short() {
+		t.Skip("postgres is not started in short mode")
+	}
+
+	initdb, pgctl := postgresBinary("initdb"), postgresBinary("pg_ctl")
+	if initdb == "" || pgctl == "" {
+		t.Skipf("%s is not set and initdb or pg_ctl is not installed", testPostgresEnv)
+	}
+
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	if out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust",
+		"--no-sync").CombinedOutput(); err != nil {
+		// initdb не запускается от root, например в контейнере
+		t.Skipf("initdb: %v: %s", err, out)
+	}
+
+	port := freePort(t)
+	opts := fmt.Sprintf("-F -p %d -k %s -c listen_addresses=127.0.0.1", port, dir)
+	if out, err := exec.Command(pgctl, "-D", data, "-o", opts, "-l", filepath.Join(dir, "log"),
+		"-w", "start").CombinedOutput(); err != nil {
+		t.Fatalf("pg_ctl start: %v: %s", err, out)
+	}
+	t.Cleanup(func() {
+		_ = exec.Command(pgctl, "-D", data, "-m", "immediate", "stop").Run()
+	})
+
+	return &url.URL{
+		Scheme:   "postgres",
+		User:     url.User("postgres"),
+		Host:     fmt.Sprintf("127.0.0.1:%d", port),
+		RawQuery: "sslmode=disable",
+	}
+}
+
+// postgresBinary ищет программу в PATH и в каталогах пакетов Debian.
+func postgresBinary(name string) string {
+	if path, err := exec.LookPath(name); err == nil {
+		return path
+	}
+
+	matches, _ := filepath.Glob(filepath.Join("/usr/lib/postgresql/*/bin", name))
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[len(matches)-1]
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
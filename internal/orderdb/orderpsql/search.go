@@ -0,0 +1,66 @@
+package orderpsql
+
+import (
+	"context"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/schema"
+	"strings"
+)
+
+// Полнотекстовое совпадение ранжируется выше, неточные совпадения
+// находятся по триграммам search_text.
+const searchQuery = `SELECT order_uid, data, schema_version,
+		ts_rank(to_tsvector('simple', search_text), plainto_tsquery('simple', $1))
+			+ word_similarity($1, search_text) AS rank
+	FROM orderDB
+	WHERE to_tsvector('simple', search_text) @@ plainto_tsquery('simple', $1)
+		OR $1 <% search_text
+	ORDER BY rank DESC, order_uid
+	LIMIT $2`
+
+// Телефон сравнивается только по цифрам, как в индексе кеша
+const phoneSearchQuery = `SELECT order_uid, data, schema_version, 1::float8 AS rank
+	FROM orderDB
+	WHERE regexp_replace(data->'Delivery'->>'phone', '\D', '', 'g') = $1
+	ORDER BY order_uid
+	LIMIT $2`
+
+func (p *Postgres) Search(ctx context.Context, query string, limit int) ([]orderdb.SearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	sql, arg := searchQuery, strings.ToLower(query)
+	if orderdb.IsPhone(query) {
+		sql, arg = phoneSearchQuery, orderdb.PhoneDigits(query)
+	}
+
+	res, err := p.deps.PGX.Query(ctx, sql, arg, limit)
+	if err != nil {
+		p.log.Errorf("failed to search: %v", err)
+		return nil, err
+	}
+	defer res.Close()
+
+	ret := make([]orderdb.SearchResult, 0)
+	for res.Next() {
+		var (
+			uid     schema.OrderUID
+			data    []byte
+			version int
+			result  orderdb.SearchResult
+		)
+
+		if err := res.Scan(&uid, &data, &version, &result.Rank); err != nil {
+			p.log.Errorf("Scan failed: %v", err)
+			return nil, err
+		}
+
+		if result.Order, err = p.decode(uid, version, data); err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, result)
+	}
+
+	return ret, res.Err()
+}
@@ -0,0 +1,46 @@
+package orderpsql
+
+import (
+	"context"
+	"fmt"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/schema"
+)
+
+// decode приводит строку, сохраненную в старой версии схемы, к текущей
+func (p *Postgres) decode(uid schema.OrderUID, version int, data []byte) (schema.Order, error) {
+	order, err := p.deps.Registry.DecodeVersion(version, data)
+	if err != nil {
+		p.log.Errorf("failed to decode order %s of version %d: %v", uid, version, err)
+		return schema.Order{}, fmt.Errorf("order %s: %w", uid, err)
+	}
+
+	return order, nil
+}
+
+// SchemaVersions возвращает количество заказов в каждой версии схемы.
+func (p *Postgres) SchemaVersions(ctx context.Context) ([]orderdb.VersionCount, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	res, err := p.deps.PGX.Query(ctx, `SELECT schema_version, count(*) FROM orderDB
+		GROUP BY schema_version ORDER BY schema_version`)
+	if err != nil {
+		p.log.Errorf("failed to count schema versions: %v", err)
+		return nil, err
+	}
+	defer res.Close()
+
+	ret := make([]orderdb.VersionCount, 0)
+	for res.Next() {
+		var v orderdb.VersionCount
+		if err := res.Scan(&v.Version, &v.Orders); err != nil {
+			p.log.Errorf("Scan failed: %v", err)
+			return nil, err
+		}
+
+		ret = append(ret, v)
+	}
+
+	return ret, res.Err()
+}
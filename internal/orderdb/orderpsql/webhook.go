@@ -0,0 +1,193 @@
+package orderpsql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/webhook"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (p *Postgres) CreateSubscription(ctx context.Context, sub webhook.Subscription) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	filter, err := json.Marshal(sub.Filter)
+	if err != nil {
+		return err
+	}
+
+	types := make([]string, 0, len(sub.EventTypes))
+	for _, typ := range sub.EventTypes {
+		types = append(types, string(typ))
+	}
+
+	_, err = p.deps.PGX.Exec(ctx, `INSERT INTO webhook_subscriptions
+		(id, url, event_types, filter, secret, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		sub.ID, sub.URL, types, filter, sub.Secret, sub.CreatedAt)
+	if err != nil {
+		p.log.Errorf("failed to create subscription: %v", err)
+	}
+	return err
+}
+
+const selectSubscriptions = `SELECT id::text, url, event_types, filter, secret, created_at
+	FROM webhook_subscriptions`
+
+func (p *Postgres) Subscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	rows, err := p.deps.PGX.Query(ctx, selectSubscriptions+` ORDER BY created_at, id`)
+	if err != nil {
+		p.log.Errorf("failed to select subscriptions: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []webhook.Subscription
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, sub)
+	}
+
+	return ret, rows.Err()
+}
+
+func (p *Postgres) Subscription(ctx context.Context, id string) (webhook.Subscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	sub, err := scanSubscription(p.deps.PGX.QueryRow(ctx, selectSubscriptions+` WHERE id::text = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return webhook.Subscription{}, orderdb.ErrNotFound
+	}
+
+	return sub, err
+}
+
+func scanSubscription(row pgx.Row) (webhook.Subscription, error) {
+	var (
+		sub    webhook.Subscription
+		types  []string
+		filter []byte
+	)
+	if err := row.Scan(&sub.ID, &sub.URL, &types, &filter, &sub.Secret, &sub.CreatedAt); err != nil {
+		return webhook.Subscription{}, err
+	}
+
+	for _, typ := range types {
+		sub.EventTypes = append(sub.EventTypes, orderevent.EventType(typ))
+	}
+
+	return sub, json.Unmarshal(filter, &sub.Filter)
+}
+
+// DeleteSubscription удаляет подписку вместе с ее доставками.
+func (p *Postgres) DeleteSubscription(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	tag, err := p.deps.PGX.Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id::text = $1`, id)
+	if err != nil {
+		p.log.Errorf("failed to delete subscription: %v", err)
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return orderdb.ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) EnqueueDeliveries(ctx context.Context, deliveries []webhook.Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	batch := &pgx.Batch{}
+	for _, d := range deliveries {
+		batch.Queue(`INSERT INTO webhook_deliveries
+			(subscription_id, event_id, event_type, payload, status, next_attempt_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (subscription_id, event_id) DO NOTHING`,
+			d.SubscriptionID, d.EventID, d.EventType, d.Payload, d.Status, d.NextAttemptAt)
+	}
+
+	if err := p.deps.PGX.SendBatch(ctx, batch).Close(); err != nil {
+		p.log.Errorf("failed to enqueue deliveries: %v", err)
+		return err
+	}
+	return nil
+}
+
+const selectDeliveries = `SELECT id, subscription_id::text, event_id::text, event_type, payload,
+	status, attempts, next_attempt_at, last_status_code, last_error, updated_at
+	FROM webhook_deliveries`
+
+func (p *Postgres) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]webhook.Delivery, error) {
+	return p.queryDeliveries(ctx, selectDeliveries+`
+		WHERE status = 'pending' AND next_attempt_at <= $1
+		ORDER BY next_attempt_at, id LIMIT $2`, now, limit)
+}
+
+// Deliveries возвращает журнал доставок подписки, последние первыми.
+func (p *Postgres) Deliveries(ctx context.Context, subscriptionID string, limit int) ([]webhook.Delivery, error) {
+	return p.queryDeliveries(ctx, selectDeliveries+`
+		WHERE subscription_id::text = $1
+		ORDER BY id DESC LIMIT $2`, subscriptionID, limit)
+}
+
+func (p *Postgres) queryDeliveries(ctx context.Context, query string, args ...any) ([]webhook.Delivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	rows, err := p.deps.PGX.Query(ctx, query, args...)
+	if err != nil {
+		p.log.Errorf("failed to select deliveries: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []webhook.Delivery
+	for rows.Next() {
+		var (
+			d         webhook.Delivery
+			code      *int
+			lastError *string
+		)
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload,
+			&d.Status, &d.Attempts, &d.NextAttemptAt, &code, &lastError, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		if code != nil {
+			d.LastStatusCode = *code
+		}
+		if lastError != nil {
+			d.LastError = *lastError
+		}
+		ret = append(ret, d)
+	}
+
+	return ret, rows.Err()
+}
+
+func (p *Postgres) UpdateDelivery(ctx context.Context, d webhook.Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.QueryTimeout)
+	defer cancel()
+
+	_, err := p.deps.PGX.Exec(ctx, `UPDATE webhook_deliveries SET
+		status = $2, attempts = $3, next_attempt_at = $4,
+		last_status_code = NULLIF($5, 0), last_error = NULLIF($6, ''), updated_at = now()
+		WHERE id = $1`,
+		d.ID, d.Status, d.Attempts, d.NextAttemptAt, d.LastStatusCode, d.LastError)
+	return err
+}
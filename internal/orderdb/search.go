@@ -0,0 +1,33 @@
+package orderdb
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MinPhoneDigits is the number of digits from which a search query
+// consisting of digits and phone punctuation is treated as a phone.
+const MinPhoneDigits = 5
+
+// PhoneDigits strips everything but digits, so that phones written with
+// different punctuation compare equal.
+func PhoneDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// IsPhone reports whether a search query is a phone, such as
+// "+7 (912) 000-00-00". Stores match such queries by PhoneDigits.
+func IsPhone(s string) bool {
+	if len(PhoneDigits(s)) < MinPhoneDigits {
+		return false
+	}
+
+	return strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && !strings.ContainsRune("+-() ", r)
+	}) < 0
+}
@@ -0,0 +1,75 @@
+package orderdb
+
+import (
+	"context"
+	"errors"
+	"orderservice/internal/schema"
+)
+
+// ErrAlreadyProcessed is returned by a SeqTracker for a message delivered
+// again after it was processed. Nothing is written, the message should be
+// acknowledged.
+var ErrAlreadyProcessed = errors.New("message is already processed")
+
+// SeqRange is an inclusive range of message sequences.
+type SeqRange struct {
+	From schema.SeqNumber `json:"from"`
+	To   schema.SeqNumber `json:"to"`
+}
+
+// SeqProgress describes which messages have been processed. Every
+// sequence up to LowWaterMark is processed, Processed lists the ranges
+// processed above it and Gaps the ranges between them that are not.
+type SeqProgress struct {
+	LowWaterMark  schema.SeqNumber `json:"low_water_mark"`
+	HighWaterMark schema.SeqNumber `json:"high_water_mark"`
+	Processed     []SeqRange       `json:"processed"`
+	Gaps          []SeqRange       `json:"gaps"`
+}
+
+// SeqTracker is implemented by stores that record every processed
+// sequence rather than only the highest one. SeqNumber of such a store
+// returns the low-water mark, so consumption resumes from the lowest
+// unprocessed sequence and messages processed above a gap are
+// recognised when they are delivered again.
+type SeqTracker interface {
+	SeqProgress(ctx context.Context) (SeqProgress, error)
+	// Rewind forgets that the sequences from from on were processed, so
+	// they are written again when replayed. The low-water mark is lowered
+	// below from if it is higher.
+	Rewind(ctx context.Context, from schema.SeqNumber) error
+}
+
+// NewSeqProgress builds the progress from the low-water mark and the
+// ascending, non-overlapping ranges processed above it.
+func NewSeqProgress(mark schema.SeqNumber, processed []SeqRange) SeqProgress {
+	progress := SeqProgress{
+		LowWaterMark:  mark,
+		HighWaterMark: mark,
+		Processed:     processed,
+		Gaps:          []SeqRange{},
+	}
+	if progress.Processed == nil {
+		progress.Processed = []SeqRange{}
+	}
+
+	next := mark + 1
+	for _, r := range processed {
+		if r.From > next {
+			progress.Gaps = append(progress.Gaps, SeqRange{From: next, To: r.From - 1})
+		}
+		next = r.To + 1
+		progress.HighWaterMark = r.To
+	}
+
+	return progress
+}
+
+// Missing returns the number of sequences in the gaps.
+func (p SeqProgress) Missing() uint64 {
+	var n uint64
+	for _, gap := range p.Gaps {
+		n += uint64(gap.To-gap.From) + 1
+	}
+	return n
+}
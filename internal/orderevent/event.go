@@ -2,14 +2,94 @@ package orderevent
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"orderservice/internal/schema"
+	"time"
+
+	"github.com/google/uuid"
 )
 
+// ErrUnsupported возвращается ControllableConsumer, если операция
+// недоступна в текущем режиме подписки.
+var ErrUnsupported = errors.New("operation is not supported")
+
 type OrderPublisher interface {
 	PublishOrder(context.Context, schema.Order) error
 }
 
+type EventType string
+
+const (
+	EventOrderAccepted EventType = "order.accepted"
+	EventOrderRejected EventType = "order.rejected"
+)
+
+// eventNamespace - пространство имен UUID для идентификаторов событий
+var eventNamespace = uuid.MustParse("5b0c7f9e-3d0a-4c55-9f3e-0c1b8f2a6d41")
+
+// Event - производное событие об обработке заказа. ID зависит только от
+// типа, заказа и номера сообщения, поэтому при повторной обработке или
+// публикации получатель может отбросить дубликат.
+type Event struct {
+	ID         string           `json:"id"`
+	Type       EventType        `json:"type"`
+	OrderUID   schema.OrderUID  `json:"order_uid"`
+	Seq        schema.SeqNumber `json:"seq"`
+	Reason     string           `json:"reason,omitempty"`
+	OccurredAt time.Time        `json:"occurred_at"`
+}
+
+func NewEvent(typ EventType, orderUID schema.OrderUID, seq schema.SeqNumber, reason string) Event {
+	key := fmt.Sprintf("%s:%s:%d", typ, orderUID, seq)
+	return Event{
+		ID:         uuid.NewSHA1(eventNamespace, []byte(key)).String(),
+		Type:       typ,
+		OrderUID:   orderUID,
+		Seq:        seq,
+		Reason:     reason,
+		OccurredAt: time.Now().UTC(),
+	}
+}
+
+type EventPublisher interface {
+	PublishEvent(context.Context, Event) error
+}
+
 type OrderConsumer interface {
 	SubscribeOnOrder(context.Context) error
 	Unsubscribe()
 }
+
+// ReplayOptions задает начало повторной обработки. Если задано FromTime,
+// FromSeq игнорируется. В режиме Shadow сообщения только проверяются
+// и не записываются в хранилище.
+type ReplayOptions struct {
+	FromSeq  schema.SeqNumber `json:"from_seq"`
+	FromTime time.Time        `json:"from_time"`
+	Shadow   bool             `json:"shadow"`
+}
+
+// ReplayReport описывает ход теневой повторной обработки.
+type ReplayReport struct {
+	Running   bool             `json:"running"`
+	StartedAt time.Time        `json:"started_at"`
+	UntilSeq  schema.SeqNumber `json:"until_seq"`
+	LastSeq   schema.SeqNumber `json:"last_seq"`
+	Processed int              `json:"processed"`
+	Invalid   int              `json:"invalid"`
+	// TimedOut - обработка завершена без сообщения с UntilSeq: сообщения
+	// перестали приходить
+	TimedOut bool     `json:"timed_out,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// ControllableConsumer позволяет вручную управлять точкой продолжения
+// обработки сообщений.
+type ControllableConsumer interface {
+	// Seek сохраняет seq как последний обработанный номер и продолжает
+	// обработку с seq+1.
+	Seek(ctx context.Context, seq schema.SeqNumber) error
+	Replay(ctx context.Context, opts ReplayOptions) error
+	ShadowReport() ReplayReport
+}
@@ -0,0 +1,14 @@
+package orderevent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEventID(t *testing.T) {
+	a := NewEvent(EventOrderAccepted, "uid", 7, "")
+	require.Equal(t, a.ID, NewEvent(EventOrderAccepted, "uid", 7, "").ID)
+	require.NotEqual(t, a.ID, NewEvent(EventOrderAccepted, "uid", 8, "").ID)
+	require.NotEqual(t, a.ID, NewEvent(EventOrderRejected, "uid", 7, "bad").ID)
+}
@@ -0,0 +1,71 @@
+package orderevent
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter ограничивает количество сообщений в обработке. Предел растет
+// на единицу за каждое окно быстрых ответов хранилища и уменьшается
+// вдвое при медленном ответе или ошибке (AIMD), оставаясь в пределах
+// [min, max]. Без target предел постоянно равен max.
+type limiter struct {
+	min, max int
+	target   time.Duration
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	// wake будит acquire после release, ждет только цикл Consumer.Run
+	wake chan struct{}
+}
+
+func newLimiter(min, max int, target time.Duration) *limiter {
+	return &limiter{
+		min:    min,
+		max:    max,
+		target: target,
+		limit:  float64(max),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+func (l *limiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.inflight < int(l.limit) {
+			l.inflight++
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-l.wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release освобождает место и пересчитывает предел по задержке
+// хранилища. failed - ошибка хранилища, ошибки проверки заказа на
+// предел не влияют.
+func (l *limiter) release(latency time.Duration, failed bool) {
+	l.mu.Lock()
+	l.inflight--
+	if l.target > 0 {
+		if failed || latency > l.target {
+			l.limit = max(float64(l.min), l.limit/2)
+		} else {
+			l.limit = min(float64(l.max), l.limit+1/l.limit)
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,39 @@
+package orderevent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(2, 8, 100*time.Millisecond)
+	ctx := context.Background()
+
+	for i := 0; i < 8; i++ {
+		require.NoError(t, l.acquire(ctx))
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, l.acquire(timeout), context.DeadlineExceeded)
+
+	// Медленные ответы уменьшают предел вдвое, но не ниже min
+	l.release(time.Second, false)
+	require.Equal(t, 4.0, l.limit)
+	require.Equal(t, 7, l.inflight)
+
+	l.release(time.Millisecond, true)
+	l.release(time.Millisecond, true)
+	require.Equal(t, 2.0, l.limit)
+
+	// Быстрые ответы увеличивают предел примерно на единицу за каждые
+	// limit ответов
+	for i := 0; i < 5; i++ {
+		l.release(time.Millisecond, false)
+	}
+	require.InDelta(t, 3.8, l.limit, 0.1)
+	require.Zero(t, l.inflight)
+}
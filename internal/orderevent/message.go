@@ -0,0 +1,329 @@
+package orderevent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderevent/ordercodec"
+	"orderservice/internal/schema"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HeaderContentType - заголовок с типом содержимого у брокеров, которые
+// поддерживают заголовки. Без него формат определяется по данным.
+const HeaderContentType = "Content-Type"
+
+// Message - сообщение с заказом от любого брокера. Адаптер брокера
+// оборачивает в него свои сообщения и передает их Consumer.
+type Message interface {
+	Data() []byte
+	// Seq - номер сообщения в канале, по нему продолжается обработка
+	// после перезапуска
+	Seq() schema.SeqNumber
+	// Headers может быть nil, если брокер не поддерживает заголовки
+	Headers() map[string]string
+	// Ack подтверждает обработку, сообщение больше не доставляется
+	Ack() error
+	// Nack просит доставить сообщение повторно. Брокеры без явного
+	// отказа доставляют неподтвержденное сообщение по таймауту.
+	Nack() error
+}
+
+type ConsumerConfig struct {
+	// Workers - количество параллельных обработчиков. Сообщения одного
+	// заказа обрабатывает один обработчик в порядке поступления.
+	Workers int
+	// MinInflight и MaxInflight ограничивают количество сообщений в
+	// обработке, MaxInflight по умолчанию равен Workers
+	MinInflight int
+	MaxInflight int
+	// TargetLatency - задержка записи в хранилище, выше которой предел
+	// сообщений в обработке уменьшается. 0 отключает адаптивный предел.
+	TargetLatency time.Duration
+	// FailureThreshold - количество ошибок хранилища подряд, после
+	// которого подписка приостанавливается. 0 отключает паузу.
+	FailureThreshold int
+	// ProbeInterval - интервал проверки хранилища во время паузы
+	ProbeInterval time.Duration
+	// RejectInvalid подтверждает некорректные заказы, без него такие
+	// сообщения остаются неподтвержденными. Отказ сохраняется через
+	// orderdb.Rejecter в любом случае, чтобы номер сообщения не оставлял
+	// пропуск перед low-water mark.
+	RejectInvalid bool
+}
+
+// Pausable - подписка, которую Consumer останавливает, пока хранилище
+// недоступно, чтобы брокер не доставлял сообщения повторно по таймауту.
+type Pausable interface {
+	Pause()
+	Resume() error
+}
+
+type ConsumerDependencies struct {
+	Log   *logrus.Logger
+	Store orderdb.OrderDB
+	// Codec decodes messages of any content type, JSON with the lenient
+	// registry by default
+	Codec *ordercodec.Mux
+	// Subscription приостанавливается при недоступности хранилища, без
+	// нее Consumer только перестает принимать сообщения
+	Subscription Pausable
+}
+
+// Consumer декодирует, проверяет и сохраняет заказы из сообщений
+// независимо от брокера.
+type Consumer struct {
+	cfg  ConsumerConfig
+	deps ConsumerDependencies
+
+	limiter *limiter
+	// failures - ошибки хранилища подряд, unhealthy сообщает Run о
+	// достижении FailureThreshold
+	failures  atomic.Int32
+	unhealthy chan struct{}
+
+	log *logrus.Entry
+}
+
+func NewConsumer(cfg ConsumerConfig, deps ConsumerDependencies) *Consumer {
+	cfg.Workers = max(cfg.Workers, 1)
+	if cfg.MaxInflight <= 0 {
+		cfg.MaxInflight = cfg.Workers
+	}
+	cfg.MinInflight = min(max(cfg.MinInflight, 1), cfg.MaxInflight)
+	if cfg.ProbeInterval <= 0 {
+		cfg.ProbeInterval = 5 * time.Second
+	}
+
+	if deps.Codec == nil {
+		// Конфигурация по умолчанию всегда корректна
+		deps.Codec, _ = ordercodec.NewMux(ordercodec.Config{}, ordercodec.Dependencies{})
+	}
+
+	return &Consumer{
+		cfg:       cfg,
+		deps:      deps,
+		limiter:   newLimiter(cfg.MinInflight, cfg.MaxInflight, cfg.TargetLatency),
+		unhealthy: make(chan struct{}, 1),
+		log:       deps.Log.WithField("component", "consumer"),
+	}
+}
+
+// job - декодированное сообщение в очереди обработчика
+type job struct {
+	msg   Message
+	order schema.Order
+	err   error
+}
+
+// Run распределяет сообщения между обработчиками по order_uid до отмены
+// ctx или закрытия messages. Принятые сообщения обрабатываются до конца и
+// после отмены ctx, Run возвращается после их обработки.
+func (c *Consumer) Run(ctx context.Context, messages <-chan Message) {
+	handleCtx := context.WithoutCancel(ctx)
+
+	var wg sync.WaitGroup
+	queues := make([]chan job, c.cfg.Workers)
+	for i := range queues {
+		// Предел сообщений в обработке не больше MaxInflight, поэтому
+		// отправка в очередь не блокируется
+		queue := make(chan job, c.cfg.MaxInflight)
+		queues[i] = queue
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				c.process(handleCtx, j)
+			}
+		}()
+	}
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		if c.failing() && !c.pause(ctx) {
+			return
+		}
+
+		if err := c.limiter.acquire(ctx); err != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			c.limiter.release(0, false)
+			return
+		case <-c.unhealthy:
+			c.limiter.release(0, false)
+		case msg, ok := <-messages:
+			if !ok {
+				c.limiter.release(0, false)
+				return
+			}
+
+			order, err := c.Decode(msg)
+			queues[shard(order.OrderUID, len(queues))] <- job{msg: msg, order: order, err: err}
+		}
+	}
+}
+
+func shard(orderUID schema.OrderUID, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(orderUID))
+	return int(h.Sum32() % uint32(n))
+}
+
+func (c *Consumer) process(ctx context.Context, j job) {
+	start := time.Now()
+	err := c.handle(ctx, j.msg, j.order, j.err)
+	c.limiter.release(time.Since(start), err != nil)
+
+	if err == nil {
+		c.failures.Store(0)
+		return
+	}
+
+	if c.failures.Add(1) == int32(c.cfg.FailureThreshold) {
+		select {
+		case c.unhealthy <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *Consumer) failing() bool {
+	return c.cfg.FailureThreshold > 0 && c.failures.Load() >= int32(c.cfg.FailureThreshold)
+}
+
+// pause приостанавливает подписку и проверяет хранилище каждые
+// ProbeInterval. Возвращает false, если ctx отменен во время паузы.
+func (c *Consumer) pause(ctx context.Context) bool {
+	c.log.Warnf("consumer paused after %d store failures", c.failures.Load())
+	if c.deps.Subscription != nil {
+		c.deps.Subscription.Pause()
+	}
+
+	ticker := time.NewTicker(c.cfg.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+
+		// Хранилище без проверки считается восстановившимся через
+		// ProbeInterval, при ошибках пауза повторится
+		if pinger, ok := c.deps.Store.(orderdb.Pinger); ok {
+			if err := pinger.Ping(ctx); err != nil {
+				c.log.Warnf("store is still unavailable: %v", err)
+				continue
+			}
+		}
+
+		if c.deps.Subscription != nil {
+			if err := c.deps.Subscription.Resume(); err != nil {
+				c.log.Errorf("failed to resume subscription: %v", err)
+				continue
+			}
+		}
+
+		c.failures.Store(0)
+		select {
+		case <-c.unhealthy:
+		default:
+		}
+
+		c.log.Info("consumer resumed")
+		return true
+	}
+}
+
+// Handle сохраняет заказ и подтверждает сообщение. Для некорректного
+// заказа сохраняется отказ, сообщение подтверждается только с
+// RejectInvalid. При ошибке хранилища сообщение возвращается брокеру:
+// запись заказа идемпотентна. Уже обработанное сообщение подтверждается
+// без записи.
+func (c *Consumer) Handle(ctx context.Context, msg Message) {
+	order, err := c.Decode(msg)
+	_ = c.handle(ctx, msg, order, err)
+}
+
+// handle возвращает ошибку хранилища, ошибки проверки заказа
+// обрабатываются отказом.
+func (c *Consumer) handle(ctx context.Context, msg Message, order schema.Order, invalid error) error {
+	if invalid != nil {
+		c.log.Errorf("invalid order %q at seq %d: %v", order.OrderUID, msg.Seq(), invalid)
+		return c.reject(ctx, msg, order.OrderUID, invalid)
+	}
+
+	err := c.deps.Store.AddOrder(ctx, order, msg.Seq())
+	if err != nil && !errors.Is(err, orderdb.ErrAlreadyProcessed) {
+		c.nack(msg)
+		return err
+	}
+
+	if err := msg.Ack(); err != nil {
+		c.log.Errorf("failed to ack message: %v", err)
+	}
+	return nil
+}
+
+// Decode декодирует и проверяет заказ, не сохраняя его. При ошибке
+// проверки возвращается декодированный заказ для журнала.
+func (c *Consumer) Decode(msg Message) (schema.Order, error) {
+	order, version, ct, err := c.deps.Codec.Decode(msg.Data())
+	if err != nil {
+		if header := msg.Headers()[HeaderContentType]; header != "" {
+			err = fmt.Errorf("%w (content type %s)", err, header)
+		}
+		return order, err
+	}
+
+	if version != schema.CurrentVersion {
+		c.log.Debugf("order %s upcasted from schema version %d (%s)", order.OrderUID, version, ct)
+	}
+
+	return order, order.Validate()
+}
+
+// reject отмечает сообщение с некорректным заказом обработанным. Без
+// RejectInvalid оно остается неподтвержденным, как до появления отказов,
+// но уже не задерживает low-water mark и возобновление после перезапуска.
+func (c *Consumer) reject(ctx context.Context, msg Message, orderUID schema.OrderUID, reason error) error {
+	if rejecter, ok := c.deps.Store.(orderdb.Rejecter); ok {
+		err := rejecter.RejectOrder(ctx, orderUID, reason.Error(), msg.Seq())
+		if err != nil && !errors.Is(err, orderdb.ErrAlreadyProcessed) {
+			c.log.Errorf("failed to reject order: %v", err)
+			c.nack(msg)
+			return err
+		}
+	}
+
+	if !c.cfg.RejectInvalid {
+		return nil
+	}
+
+	if err := msg.Ack(); err != nil {
+		c.log.Errorf("failed to ack message: %v", err)
+	}
+	return nil
+}
+
+func (c *Consumer) nack(msg Message) {
+	if err := msg.Nack(); err != nil {
+		c.log.Errorf("failed to nack message: %v", err)
+	}
+}
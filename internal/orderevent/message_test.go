@@ -0,0 +1,217 @@
+package orderevent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderdb/orderdbtest"
+	"orderservice/internal/schema"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type fakeMessage struct {
+	data   []byte
+	seq    schema.SeqNumber
+	acked  bool
+	nacked bool
+}
+
+func (m *fakeMessage) Data() []byte               { return m.data }
+func (m *fakeMessage) Seq() schema.SeqNumber      { return m.seq }
+func (m *fakeMessage) Headers() map[string]string { return nil }
+func (m *fakeMessage) Ack() error                 { m.acked = true; return nil }
+func (m *fakeMessage) Nack() error                { m.nacked = true; return nil }
+
+type rejectingStore struct {
+	*orderdb.MockOrderDB
+	rejected []schema.SeqNumber
+}
+
+func (s *rejectingStore) RejectOrder(_ context.Context, _ schema.OrderUID, _ string, seq schema.SeqNumber) error {
+	s.rejected = append(s.rejected, seq)
+	return nil
+}
+
+func TestConsumerHandle(t *testing.T) {
+	valid, err := json.Marshal(orderdbtest.Order("uid"))
+	require.NoError(t, err)
+
+	cases := []struct {
+		name       string
+		data       []byte
+		setup      func(db *orderdb.MockOrderDB)
+		reject     bool
+		wantAck    bool
+		wantNack   bool
+		wantReject bool
+	}{
+		{
+			name: "stored",
+			data: valid,
+			setup: func(db *orderdb.MockOrderDB) {
+				db.EXPECT().AddOrder(gomock.Any(), gomock.Any(), schema.SeqNumber(3))
+			},
+			wantAck: true,
+		},
+		{
+			name: "store_failed",
+			data: valid,
+			setup: func(db *orderdb.MockOrderDB) {
+				db.EXPECT().AddOrder(gomock.Any(), gomock.Any(), schema.SeqNumber(3)).
+					Return(errors.New("connection reset"))
+			},
+			wantNack: true,
+		},
+		{
+			name:       "rejected",
+			data:       []byte(`{"order_uid": "uid"`),
+			reject:     true,
+			wantAck:    true,
+			wantReject: true,
+		},
+		{
+			// Номер отмечается обработанным и без подтверждения
+			name:       "invalid_kept",
+			data:       []byte(`{"order_uid": "uid"`),
+			wantReject: true,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			db := orderdb.NewMockOrderDB(gomock.NewController(t))
+			if c.setup != nil {
+				c.setup(db)
+			}
+			store := &rejectingStore{MockOrderDB: db}
+
+			msg := &fakeMessage{data: c.data, seq: 3}
+			NewConsumer(ConsumerConfig{RejectInvalid: c.reject}, ConsumerDependencies{Log: logrus.New(), Store: store}).
+				Handle(context.Background(), msg)
+
+			require.Equal(t, c.wantAck, msg.acked)
+			require.Equal(t, c.wantNack, msg.nacked)
+			require.Equal(t, c.wantReject, len(store.rejected) == 1)
+		})
+	}
+}
+
+// Сообщения одного заказа сохраняются в порядке поступления при
+// параллельной обработке
+func TestConsumerOrdering(t *testing.T) {
+	db := orderdb.NewMockOrderDB(gomock.NewController(t))
+
+	var (
+		mu   sync.Mutex
+		seqs = map[schema.OrderUID][]schema.SeqNumber{}
+	)
+	db.EXPECT().AddOrder(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, order schema.Order, seq schema.SeqNumber) error {
+			mu.Lock()
+			defer mu.Unlock()
+			seqs[order.OrderUID] = append(seqs[order.OrderUID], seq)
+			return nil
+		}).AnyTimes()
+
+	messages := make(chan Message)
+	done := make(chan struct{})
+	consumer := NewConsumer(ConsumerConfig{Workers: 4, MaxInflight: 16},
+		ConsumerDependencies{Log: logrus.New(), Store: db})
+	go func() {
+		defer close(done)
+		consumer.Run(context.Background(), messages)
+	}()
+
+	uids := []schema.OrderUID{"a", "b", "c", "d", "e"}
+	for seq := schema.SeqNumber(1); seq <= 100; seq++ {
+		data, err := json.Marshal(orderdbtest.Order(uids[int(seq)%len(uids)]))
+		require.NoError(t, err)
+		messages <- &fakeMessage{data: data, seq: seq}
+	}
+	close(messages)
+	<-done
+
+	for _, uid := range uids {
+		require.Len(t, seqs[uid], 20)
+		require.IsIncreasing(t, seqs[uid])
+	}
+}
+
+type fakeSubscription struct {
+	pauses, resumes atomic.Int32
+}
+
+func (s *fakeSubscription) Pause()        { s.pauses.Add(1) }
+func (s *fakeSubscription) Resume() error { s.resumes.Add(1); return nil }
+
+type pingStore struct {
+	*orderdb.MockOrderDB
+	healthy atomic.Bool
+}
+
+func (s *pingStore) Ping(context.Context) error {
+	if !s.healthy.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// Подписка приостанавливается после FailureThreshold ошибок подряд и
+// возобновляется, когда хранилище отвечает на Ping
+func TestConsumerPause(t *testing.T) {
+	db := orderdb.NewMockOrderDB(gomock.NewController(t))
+	db.EXPECT().AddOrder(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(errors.New("connection refused")).Times(3)
+	store := &pingStore{MockOrderDB: db}
+
+	sub := &fakeSubscription{}
+	messages := make(chan Message)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	consumer := NewConsumer(
+		ConsumerConfig{FailureThreshold: 3, ProbeInterval: 10 * time.Millisecond},
+		ConsumerDependencies{Log: logrus.New(), Store: store, Subscription: sub})
+	go func() {
+		defer close(done)
+		consumer.Run(ctx, messages)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	data, err := json.Marshal(orderdbtest.Order("uid"))
+	require.NoError(t, err)
+	for seq := schema.SeqNumber(1); seq <= 3; seq++ {
+		messages <- &fakeMessage{data: data, seq: seq}
+	}
+
+	require.Eventually(t, func() bool { return sub.pauses.Load() == 1 }, time.Second, time.Millisecond)
+
+	// На паузе сообщения не принимаются
+	select {
+	case messages <- &fakeMessage{data: data, seq: 4}:
+		t.Fatal("message accepted while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.Zero(t, sub.resumes.Load())
+
+	db.EXPECT().AddOrder(gomock.Any(), gomock.Any(), schema.SeqNumber(4))
+	store.healthy.Store(true)
+	require.Eventually(t, func() bool { return sub.resumes.Load() == 1 }, time.Second, time.Millisecond)
+
+	msg := &fakeMessage{data: data, seq: 4}
+	messages <- msg
+	cancel()
+	<-done
+	require.True(t, msg.acked)
+}
@@ -0,0 +1,89 @@
+package ordercodec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"orderservice/internal/schema"
+)
+
+var (
+	ErrUnknownContentType = errors.New("unknown content type")
+	ErrInvalidFrame       = errors.New("invalid frame")
+)
+
+type ContentType string
+
+const (
+	ContentTypeJSON     ContentType = "application/json"
+	ContentTypeProtobuf ContentType = "application/x-protobuf"
+	ContentTypeMsgpack  ContentType = "application/msgpack"
+)
+
+// ParseContentType accepts a content type or its short name
+// json, protobuf or msgpack. Empty string means JSON.
+func ParseContentType(s string) (ContentType, error) {
+	switch s {
+	case "", "json", string(ContentTypeJSON):
+		return ContentTypeJSON, nil
+	case "protobuf", "proto", string(ContentTypeProtobuf):
+		return ContentTypeProtobuf, nil
+	case "msgpack", string(ContentTypeMsgpack):
+		return ContentTypeMsgpack, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnknownContentType, s)
+}
+
+// Codec encodes a single order of the current schema version.
+type Codec interface {
+	ContentType() ContentType
+	Marshal(order *schema.Order) ([]byte, error)
+	Unmarshal(data []byte, order *schema.Order) error
+}
+
+// Бинарные сообщения начинаются с заголовка: magic, код типа содержимого
+// и версия схемы (uvarint). JSON сообщения заголовка не имеют, чтобы
+// их можно было читать без кодеков, и начинаются с '{'.
+var frameMagic = [2]byte{0x00, 'O'}
+
+var contentTypeCodes = map[ContentType]byte{
+	ContentTypeProtobuf: 1,
+	ContentTypeMsgpack:  2,
+}
+
+func appendHeader(dst []byte, ct ContentType, version int) []byte {
+	dst = append(dst, frameMagic[:]...)
+	dst = append(dst, contentTypeCodes[ct])
+	return binary.AppendUvarint(dst, uint64(version))
+}
+
+func isFrame(data []byte) bool {
+	return len(data) >= len(frameMagic) && data[0] == frameMagic[0] && data[1] == frameMagic[1]
+}
+
+// parseHeader returns the content type, schema version and payload of a frame.
+func parseHeader(data []byte) (ContentType, int, []byte, error) {
+	data = data[len(frameMagic):]
+	if len(data) == 0 {
+		return "", 0, nil, fmt.Errorf("%w: no content type", ErrInvalidFrame)
+	}
+
+	var ct ContentType
+	for t, code := range contentTypeCodes {
+		if code == data[0] {
+			ct = t
+		}
+	}
+
+	if ct == "" {
+		return "", 0, nil, fmt.Errorf("%w: content type code %d", ErrUnknownContentType, data[0])
+	}
+
+	version, n := binary.Uvarint(data[1:])
+	if n <= 0 {
+		return "", 0, nil, fmt.Errorf("%w: bad schema version", ErrInvalidFrame)
+	}
+
+	return ct, int(version), data[1+n:], nil
+}
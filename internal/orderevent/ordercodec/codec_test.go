@@ -0,0 +1,145 @@
+package ordercodec
+
+import (
+	"orderservice/internal/schema"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testOrder() schema.Order {
+	created := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	return schema.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: schema.Delivery{
+			Name:   "Test Testov",
+			Phone:  "+9720000000",
+			Zip:    2639809,
+			City:   "Kiryat Mozkin",
+			Adress: "Ploshad Mira 15",
+			Region: "Kraiot",
+			Email:  "test@gmail.com",
+		},
+		Payment: schema.Payment{
+			Transaction:   "b563feb7b2b84b6test",
+			Currency:      "USD",
+			Provider:      "wbpay",
+			Amount:        1817,
+			PaymentDT:     schema.NewTimestamp(created.Add(time.Minute)),
+			Bank:          "alpha",
+			DeliveryConst: 1500,
+			GoodsTotal:    317,
+		},
+		Items: schema.Items{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			RID:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        9,
+		SmID:            99,
+		DateCreated:     schema.NewTimestamp(created),
+		OofShard:        1,
+	}
+}
+
+var codecs = []Codec{JSON{}, Protobuf{}, Msgpack{}}
+
+func TestCodecs(t *testing.T) {
+	want := testOrder()
+	for _, codec := range codecs {
+		t.Run(string(codec.ContentType()), func(t *testing.T) {
+			data, err := codec.Marshal(&want)
+			require.NoError(t, err)
+
+			var got schema.Order
+			require.NoError(t, codec.Unmarshal(data, &got))
+			require.Equal(t, want.OrderUID, got.OrderUID)
+			require.Equal(t, want.Delivery, got.Delivery)
+			require.Equal(t, want.Items, got.Items)
+			require.True(t, want.DateCreated.Equal(got.DateCreated.Time))
+			require.True(t, want.Payment.PaymentDT.Equal(got.Payment.PaymentDT.Time))
+			require.Equal(t, want.Payment.Amount, got.Payment.Amount)
+			require.Equal(t, want.OofShard, got.OofShard)
+		})
+	}
+}
+
+func TestMuxMixedTraffic(t *testing.T) {
+	order := testOrder()
+	consumer, err := NewMux(Config{}, Dependencies{})
+	require.NoError(t, err)
+
+	for _, ct := range []ContentType{ContentTypeJSON, ContentTypeProtobuf, ContentTypeMsgpack} {
+		producer, err := NewMux(Config{ContentType: ct}, Dependencies{})
+		require.NoError(t, err)
+
+		data, err := producer.Encode(order)
+		require.NoError(t, err)
+
+		got, version, gotCT, err := consumer.Decode(data)
+		require.NoError(t, err)
+		require.Equal(t, ct, gotCT)
+		require.Equal(t, schema.CurrentVersion, version)
+		require.Equal(t, order.OrderUID, got.OrderUID)
+		require.Equal(t, order.Items, got.Items)
+	}
+
+	// Старые сообщения без конверта
+	_, version, ct, err := consumer.Decode([]byte(`{"order_uid":"1"}`))
+	require.NoError(t, err)
+	require.Equal(t, 1, version)
+	require.Equal(t, ContentTypeJSON, ct)
+
+	_, _, _, err = consumer.Decode([]byte{0x00, 'O', 42, 2})
+	require.ErrorIs(t, err, ErrUnknownContentType)
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	order := testOrder()
+	for _, codec := range codecs {
+		b.Run(string(codec.ContentType()), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				data, err := codec.Marshal(&order)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(int64(len(data)))
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	order := testOrder()
+	for _, codec := range codecs {
+		data, err := codec.Marshal(&order)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(string(codec.ContentType()), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				var got schema.Order
+				if err := codec.Unmarshal(data, &got); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
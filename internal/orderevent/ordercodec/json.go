@@ -0,0 +1,21 @@
+package ordercodec
+
+import (
+	"encoding/json"
+	"orderservice/internal/schema"
+)
+
+// JSON encodes a bare order without an envelope.
+type JSON struct{}
+
+func (JSON) ContentType() ContentType {
+	return ContentTypeJSON
+}
+
+func (JSON) Marshal(order *schema.Order) ([]byte, error) {
+	return json.Marshal(order)
+}
+
+func (JSON) Unmarshal(data []byte, order *schema.Order) error {
+	return json.Unmarshal(data, order)
+}
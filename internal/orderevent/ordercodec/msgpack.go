@@ -0,0 +1,32 @@
+package ordercodec
+
+import (
+	"bytes"
+	"orderservice/internal/schema"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Msgpack encodes orders as MessagePack maps with the same keys as JSON.
+type Msgpack struct{}
+
+func (Msgpack) ContentType() ContentType {
+	return ContentTypeMsgpack
+}
+
+func (Msgpack) Marshal(order *schema.Order) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := msgpack.NewEncoder(buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(order); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (Msgpack) Unmarshal(data []byte, order *schema.Order) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(order)
+}
@@ -0,0 +1,100 @@
+package ordercodec
+
+import (
+	"fmt"
+	"orderservice/internal/schema"
+)
+
+type Config struct {
+	// ContentType used for published messages, JSON by default
+	ContentType ContentType
+}
+
+type Dependencies struct {
+	// Registry decodes JSON messages of older schema versions,
+	// lenient by default
+	Registry *schema.Registry
+}
+
+// Mux encodes orders with the configured codec and decodes messages of
+// any supported content type, so publishers can be switched one by one.
+type Mux struct {
+	cfg  Config
+	deps Dependencies
+
+	codecs map[ContentType]Codec
+}
+
+func NewMux(cfg Config, deps Dependencies) (*Mux, error) {
+	if cfg.ContentType == "" {
+		cfg.ContentType = ContentTypeJSON
+	}
+
+	if deps.Registry == nil {
+		deps.Registry = schema.NewRegistry(schema.Lenient)
+	}
+
+	m := &Mux{
+		cfg:  cfg,
+		deps: deps,
+		codecs: map[ContentType]Codec{
+			ContentTypeJSON:     JSON{},
+			ContentTypeProtobuf: Protobuf{},
+			ContentTypeMsgpack:  Msgpack{},
+		},
+	}
+
+	if _, ok := m.codecs[cfg.ContentType]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownContentType, cfg.ContentType)
+	}
+
+	return m, nil
+}
+
+func (m *Mux) ContentType() ContentType {
+	return m.cfg.ContentType
+}
+
+// Encode encodes the order in the current schema version. JSON is
+// wrapped into schema.Envelope, other formats into a binary frame.
+func (m *Mux) Encode(order schema.Order) ([]byte, error) {
+	if m.cfg.ContentType == ContentTypeJSON {
+		return m.deps.Registry.Encode(order)
+	}
+
+	payload, err := m.codecs[m.cfg.ContentType].Marshal(&order)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := appendHeader(make([]byte, 0, len(payload)+8), m.cfg.ContentType, schema.CurrentVersion)
+	return append(frame, payload...), nil
+}
+
+// Decode returns the order with the schema version and content type it
+// was sent in.
+func (m *Mux) Decode(data []byte) (schema.Order, int, ContentType, error) {
+	if !isFrame(data) {
+		order, version, err := m.deps.Registry.Decode(data)
+		return order, version, ContentTypeJSON, err
+	}
+
+	ct, version, payload, err := parseHeader(data)
+	if err != nil {
+		return schema.Order{}, 0, "", err
+	}
+
+	// Бинарные форматы появились во второй версии схемы, для следующих
+	// версий понадобятся upcaster'ы соответствующего формата
+	if version != schema.CurrentVersion {
+		return schema.Order{}, version, ct,
+			fmt.Errorf("%w: %d in %s", schema.ErrUnsupportedVersion, version, ct)
+	}
+
+	var order schema.Order
+	if err := m.codecs[ct].Unmarshal(payload, &order); err != nil {
+		return schema.Order{}, version, ct, fmt.Errorf("%s: %w", ct, err)
+	}
+
+	return order, version, ct, nil
+}
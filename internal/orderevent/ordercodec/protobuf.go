@@ -0,0 +1,326 @@
+package ordercodec
+
+import (
+	"fmt"
+	"orderservice/internal/schema"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Protobuf encodes orders according to order.proto. Unknown fields are
+// skipped so that newer producers can add fields.
+type Protobuf struct{}
+
+func (Protobuf) ContentType() ContentType {
+	return ContentTypeProtobuf
+}
+
+func (Protobuf) Marshal(order *schema.Order) ([]byte, error) {
+	return appendOrder(make([]byte, 0, 512), order), nil
+}
+
+func (Protobuf) Unmarshal(data []byte, order *schema.Order) error {
+	*order = schema.Order{}
+	return parseMessage(data, func(num protowire.Number, v value) error {
+		switch num {
+		case 1:
+			order.OrderUID = schema.OrderUID(v.str())
+		case 2:
+			order.TrackNumber = v.str()
+		case 3:
+			order.Entry = v.str()
+		case 4:
+			return parseDelivery(v.bytes, &order.Delivery)
+		case 5:
+			return parsePayment(v.bytes, &order.Payment)
+		case 6:
+			var item schema.Item
+			if err := parseItem(v.bytes, &item); err != nil {
+				return err
+			}
+			order.Items = append(order.Items, item)
+		case 7:
+			order.Locale = v.str()
+		case 8:
+			order.InternalSign = v.str()
+		case 9:
+			order.CustomerID = v.str()
+		case 10:
+			order.DeliveryService = v.str()
+		case 11:
+			order.Shardkey = v.int()
+		case 12:
+			order.SmID = v.int()
+		case 13:
+			return parseTimestamp(v.bytes, &order.DateCreated)
+		case 14:
+			order.OofShard = v.int()
+		}
+		return nil
+	})
+}
+
+func appendOrder(b []byte, o *schema.Order) []byte {
+	b = appendString(b, 1, string(o.OrderUID))
+	b = appendString(b, 2, o.TrackNumber)
+	b = appendString(b, 3, o.Entry)
+	b = appendMessage(b, 4, func(b []byte) []byte { return appendDelivery(b, &o.Delivery) })
+	b = appendMessage(b, 5, func(b []byte) []byte { return appendPayment(b, &o.Payment) })
+	for i := range o.Items {
+		b = appendMessage(b, 6, func(b []byte) []byte { return appendItem(b, &o.Items[i]) })
+	}
+	b = appendString(b, 7, o.Locale)
+	b = appendString(b, 8, o.InternalSign)
+	b = appendString(b, 9, o.CustomerID)
+	b = appendString(b, 10, o.DeliveryService)
+	b = appendInt(b, 11, o.Shardkey)
+	b = appendInt(b, 12, o.SmID)
+	b = appendTimestamp(b, 13, o.DateCreated)
+	b = appendInt(b, 14, o.OofShard)
+	return b
+}
+
+func appendDelivery(b []byte, d *schema.Delivery) []byte {
+	b = appendString(b, 1, d.Name)
+	b = appendString(b, 2, d.Phone)
+	b = appendInt(b, 3, d.Zip)
+	b = appendString(b, 4, d.City)
+	b = appendString(b, 5, d.Adress)
+	b = appendString(b, 6, d.Region)
+	b = appendString(b, 7, d.Email)
+	return b
+}
+
+func parseDelivery(data []byte, d *schema.Delivery) error {
+	return parseMessage(data, func(num protowire.Number, v value) error {
+		switch num {
+		case 1:
+			d.Name = v.str()
+		case 2:
+			d.Phone = v.str()
+		case 3:
+			d.Zip = v.int()
+		case 4:
+			d.City = v.str()
+		case 5:
+			d.Adress = v.str()
+		case 6:
+			d.Region = v.str()
+		case 7:
+			d.Email = v.str()
+		}
+		return nil
+	})
+}
+
+func appendPayment(b []byte, p *schema.Payment) []byte {
+	b = appendString(b, 1, p.Transaction)
+	b = appendString(b, 2, p.RequestID)
+	b = appendString(b, 3, p.Currency)
+	b = appendString(b, 4, p.Provider)
+	b = appendInt(b, 5, p.Amount)
+	b = appendTimestamp(b, 6, p.PaymentDT)
+	b = appendString(b, 7, p.Bank)
+	b = appendInt(b, 8, p.DeliveryConst)
+	b = appendInt(b, 9, p.GoodsTotal)
+	b = appendInt(b, 10, p.CustomFee)
+	return b
+}
+
+func parsePayment(data []byte, p *schema.Payment) error {
+	return parseMessage(data, func(num protowire.Number, v value) error {
+		switch num {
+		case 1:
+			p.Transaction = v.str()
+		case 2:
+			p.RequestID = v.str()
+		case 3:
+			p.Currency = v.str()
+		case 4:
+			p.Provider = v.str()
+		case 5:
+			p.Amount = v.int()
+		case 6:
+			return parseTimestamp(v.bytes, &p.PaymentDT)
+		case 7:
+			p.Bank = v.str()
+		case 8:
+			p.DeliveryConst = v.int()
+		case 9:
+			p.GoodsTotal = v.int()
+		case 10:
+			p.CustomFee = v.int()
+		}
+		return nil
+	})
+}
+
+func appendItem(b []byte, item *schema.Item) []byte {
+	b = appendInt(b, 1, item.ChrtID)
+	b = appendString(b, 2, item.TrackNumber)
+	b = appendInt(b, 3, item.Price)
+	b = appendString(b, 4, item.RID)
+	b = appendString(b, 5, item.Name)
+	b = appendInt(b, 6, item.Sale)
+	b = appendInt(b, 7, item.Size)
+	b = appendInt(b, 8, item.TotalPrice)
+	b = appendInt(b, 9, item.NmID)
+	b = appendString(b, 10, item.Brand)
+	b = appendInt(b, 11, item.Status)
+	return b
+}
+
+func parseItem(data []byte, item *schema.Item) error {
+	return parseMessage(data, func(num protowire.Number, v value) error {
+		switch num {
+		case 1:
+			item.ChrtID = v.int()
+		case 2:
+			item.TrackNumber = v.str()
+		case 3:
+			item.Price = v.int()
+		case 4:
+			item.RID = v.str()
+		case 5:
+			item.Name = v.str()
+		case 6:
+			item.Sale = v.int()
+		case 7:
+			item.Size = v.int()
+		case 8:
+			item.TotalPrice = v.int()
+		case 9:
+			item.NmID = v.int()
+		case 10:
+			item.Brand = v.str()
+		case 11:
+			item.Status = v.int()
+		}
+		return nil
+	})
+}
+
+func appendTimestamp(b []byte, num protowire.Number, ts schema.Timestamp) []byte {
+	if ts.IsZero() {
+		return b
+	}
+
+	return appendMessage(b, num, func(b []byte) []byte {
+		b = appendInt64(b, 1, ts.Unix())
+		return appendInt64(b, 2, int64(ts.Nanosecond()))
+	})
+}
+
+func parseTimestamp(data []byte, ts *schema.Timestamp) error {
+	var seconds, nanos int64
+	err := parseMessage(data, func(num protowire.Number, v value) error {
+		switch num {
+		case 1:
+			seconds = int64(v.varint)
+		case 2:
+			nanos = int64(v.varint)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	*ts = schema.NewTimestamp(time.Unix(seconds, nanos).UTC())
+	return nil
+}
+
+// Поля со значением по умолчанию не записываются, как в proto3
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendInt(b []byte, num protowire.Number, v int) []byte {
+	return appendInt64(b, num, int64(v))
+}
+
+func appendInt64(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+// appendMessage записывает вложенное сообщение, резервируя под длину
+// один байт и сдвигая содержимое, если длина не поместилась
+func appendMessage(b []byte, num protowire.Number, fn func([]byte) []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	pos := len(b)
+	b = append(b, 0)
+	b = fn(b)
+
+	size := len(b) - pos - 1
+	sizeLen := protowire.SizeVarint(uint64(size))
+	if sizeLen > 1 {
+		b = append(b, make([]byte, sizeLen-1)...)
+		copy(b[pos+sizeLen:], b[pos+1:pos+1+size])
+	}
+	protowire.AppendVarint(b[pos:pos], uint64(size))
+
+	return b
+}
+
+// value is a decoded field: varint for VarintType, bytes for BytesType.
+type value struct {
+	varint uint64
+	bytes  []byte
+}
+
+func (v value) str() string {
+	return string(v.bytes)
+}
+
+func (v value) int() int {
+	return int(int64(v.varint))
+}
+
+func parseMessage(data []byte, fn func(protowire.Number, value) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var v value
+		switch typ {
+		case protowire.VarintType:
+			v.varint, n = protowire.ConsumeVarint(data)
+		case protowire.BytesType:
+			v.bytes, n = protowire.ConsumeBytes(data)
+		default:
+			// Неизвестные поля других типов пропускаются
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		if err := fn(num, v); err != nil {
+			return fmt.Errorf("field %d: %w", num, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package ordercodec
+
+import (
+	"orderservice/internal/schema"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var (
+	protoPackage = regexp.MustCompile(`(?m)^package\s+([\w.]+);`)
+	protoMessage = regexp.MustCompile(`(?s)message\s+(\w+)\s*\{(.*?)\}`)
+	protoField   = regexp.MustCompile(`(?m)^\s*(repeated\s+)?(\w+)\s+(\w+)\s*=\s*(\d+);`)
+)
+
+var protoScalars = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"string": descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	"int64":  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"int32":  descriptorpb.FieldDescriptorProto_TYPE_INT32,
+}
+
+// loadOrderProto строит дескриптор из order.proto. protoc в сборке не
+// используется, поэтому разбирается только подмножество синтаксиса,
+// которое есть в файле: сообщения со скалярными, вложенными и repeated
+// полями.
+func loadOrderProto(t *testing.T) protoreflect.FileDescriptor {
+	src, err := os.ReadFile("order.proto")
+	require.NoError(t, err)
+
+	pkg := protoPackage.FindSubmatch(src)
+	require.NotNil(t, pkg, "package is not declared")
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("order.proto"),
+		Package: proto.String(string(pkg[1])),
+		Syntax:  proto.String("proto3"),
+	}
+
+	for _, m := range protoMessage.FindAllSubmatch(src, -1) {
+		msg := &descriptorpb.DescriptorProto{Name: proto.String(string(m[1]))}
+		for _, f := range protoField.FindAllStringSubmatch(string(m[2]), -1) {
+			number, err := strconv.ParseInt(f[4], 10, 32)
+			require.NoError(t, err)
+
+			field := &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String(f[3]),
+				JsonName: proto.String(f[3]),
+				Number:   proto.Int32(int32(number)),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			}
+			if f[1] != "" {
+				field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			}
+			if typ, ok := protoScalars[f[2]]; ok {
+				field.Type = typ.Enum()
+			} else {
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				field.TypeName = proto.String("." + string(pkg[1]) + "." + f[2])
+			}
+			msg.Field = append(msg.Field, field)
+		}
+		file.MessageType = append(file.MessageType, msg)
+	}
+
+	fd, err := protodesc.NewFile(file, nil)
+	require.NoError(t, err)
+	return fd
+}
+
+// requireKnown проверяет, что все поля сообщения описаны в схеме и
+// имеют объявленный в ней тип: иначе они попадают в unknown fields.
+func requireKnown(t *testing.T, m protoreflect.Message) {
+	t.Helper()
+	require.Empty(t, m.GetUnknown(), "unknown fields in %s", m.Descriptor().FullName())
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.Message() == nil:
+		case fd.IsList():
+			for i := 0; i < v.List().Len(); i++ {
+				requireKnown(t, v.List().Get(i).Message())
+			}
+		default:
+			requireKnown(t, v.Message())
+		}
+		return true
+	})
+}
+
+// Кодировщик написан вручную, поэтому сверяется с order.proto: сообщение
+// читается библиотекой protobuf по дескриптору схемы и обратно.
+func TestProtobufMatchesSchema(t *testing.T) {
+	order := testOrder()
+	order.InternalSign = "signature"
+	order.Payment.RequestID = "request"
+	order.Payment.CustomFee = 5
+	order.Items[0].Size = 42
+
+	fd := loadOrderProto(t)
+	desc := fd.Messages().ByName("Order")
+	require.NotNil(t, desc)
+
+	data, err := Protobuf{}.Marshal(&order)
+	require.NoError(t, err)
+
+	msg := dynamicpb.NewMessage(desc)
+	require.NoError(t, proto.Unmarshal(data, msg))
+	requireKnown(t, msg)
+
+	get := func(m protoreflect.Message, name protoreflect.Name) protoreflect.Value {
+		field := m.Descriptor().Fields().ByName(name)
+		require.NotNil(t, field, "no field %s in %s", name, m.Descriptor().FullName())
+		return m.Get(field)
+	}
+
+	delivery := get(msg, "delivery").Message()
+	payment := get(msg, "payment").Message()
+	item := get(msg, "items").List().Get(0).Message()
+
+	require.Equal(t, string(order.OrderUID), get(msg, "order_uid").String())
+	require.Equal(t, order.InternalSign, get(msg, "internal_signature").String())
+	require.Equal(t, int64(order.OofShard), get(msg, "oof_shard").Int())
+	require.Equal(t, order.DateCreated.Unix(), get(get(msg, "date_created").Message(), "seconds").Int())
+	require.Equal(t, order.Delivery.Adress, get(delivery, "address").String())
+	require.Equal(t, int64(order.Delivery.Zip), get(delivery, "zip").Int())
+	require.Equal(t, order.Payment.RequestID, get(payment, "request_id").String())
+	require.Equal(t, int64(order.Payment.DeliveryConst), get(payment, "delivery_cost").Int())
+	require.Equal(t, int64(order.Payment.CustomFee), get(payment, "custom_fee").Int())
+	require.Equal(t, order.Payment.PaymentDT.Unix(), get(get(payment, "payment_dt").Message(), "seconds").Int())
+	require.Equal(t, int64(order.Items[0].Size), get(item, "size").Int())
+	require.Equal(t, order.Items[0].Brand, get(item, "brand").String())
+
+	// Сообщение, закодированное библиотекой по схеме, читается обратно
+	data, err = proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	require.NoError(t, err)
+
+	var got schema.Order
+	require.NoError(t, Protobuf{}.Unmarshal(data, &got))
+	require.True(t, order.DateCreated.Equal(got.DateCreated.Time))
+	require.True(t, order.Payment.PaymentDT.Equal(got.Payment.PaymentDT.Time))
+	got.DateCreated, got.Payment.PaymentDT = order.DateCreated, order.Payment.PaymentDT
+	require.Equal(t, order, got)
+}
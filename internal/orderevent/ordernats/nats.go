@@ -4,48 +4,254 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"orderservice/internal/orderdb"
-	"orderservice/internal/provider/natsprovider"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/orderevent/ordercodec"
 	"orderservice/internal/schema"
+	"sync"
+	"time"
 
 	"github.com/nats-io/stan.go"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	maxShadowErrors = 100
+	// defaultShadowIdleTimeout завершает теневую обработку, если
+	// сообщение с UntilSeq так и не пришло, например удалено из канала
+	// по лимитам хранения
+	defaultShadowIdleTimeout = 30 * time.Second
+	// resubscribeInterval - интервал повторных попыток подписки после
+	// неудачного Seek или Replay
+	resubscribeInterval = 5 * time.Second
+	// firstSeqTimeout ограничивает ожидание первого сообщения при
+	// повторной обработке с момента времени
+	firstSeqTimeout = 5 * time.Second
+)
+
+// ErrQueueGroup возвращается Seek и Replay при подписке в группе: позиция
+// durable группы общая для всех реплик и не меняется при повторном
+// подключении одной из них.
+var ErrQueueGroup = fmt.Errorf("%w: seek and replay of a queue group subscription, use shadow replay",
+	orderevent.ErrUnsupported)
+
 type Config struct {
 	QueueDepth  int
 	ChannelName string
+	// QueueGroup включает durable подписку в группе: каждое сообщение
+	// обрабатывает одна из реплик. Пустое значение - отдельная подписка.
+	QueueGroup string
+	// DurableName сохраняет позицию группы между перезапусками, по
+	// умолчанию совпадает с QueueGroup
+	DurableName string
+	// EventsChannel - канал для событий orderevent.Event
+	EventsChannel string
+	// Consumer - параллельность и ограничение обработки сообщений,
+	// MaxInflight по умолчанию равен QueueDepth
+	Consumer orderevent.ConsumerConfig
+	// ShadowIdleTimeout - время без сообщений, после которого теневая
+	// обработка завершается, по умолчанию 30 секунд
+	ShadowIdleTimeout time.Duration
+}
+
+// Conn - операции NATS Streaming, которые использует адаптер. Реализуется
+// natsprovider.NatsProvider.
+type Conn interface {
+	Publish(subject string, data []byte) error
+	Subscribe(subject string, cb stan.MsgHandler, opts ...stan.SubscriptionOption) (stan.Subscription, error)
+	QueueSubscribe(subject, qgroup string, cb stan.MsgHandler,
+		opts ...stan.SubscriptionOption) (stan.Subscription, error)
 }
 
 type Dependencies struct {
 	Log        *logrus.Logger
-	NSProvider *natsprovider.NatsProvider
+	NSProvider Conn
 	Store      orderdb.OrderDB
+	// Codec encodes published orders and decodes messages of any
+	// content type, JSON with the lenient registry by default
+	Codec *ordercodec.Mux
 }
 
+// NatsOrderStore - адаптер NATS Streaming: сообщения канала передаются
+// orderevent.Consumer, который сохраняет заказы.
 type NatsOrderStore struct {
 	cfg  Config
 	deps Dependencies
 
-	sub *stan.Subscription
+	// consumer декодирует сообщения shadow replay, каждая подписка
+	// обрабатывает сообщения своим Consumer
+	consumer *orderevent.Consumer
+
+	mu  sync.Mutex
+	sub *subscription
+	// retry закрывается, чтобы остановить фоновые попытки подписки
+	retry  chan struct{}
+	shadow struct {
+		sub    stan.Subscription
+		report orderevent.ReplayReport
+		// idle завершает обработку по ShadowIdleTimeout, gen отличает
+		// таймер текущей обработки от сработавшего таймера предыдущей
+		idle *time.Timer
+		gen  int
+	}
+
 	log *logrus.Entry
 }
 
 func New(cfg Config, deps Dependencies) *NatsOrderStore {
+	if cfg.DurableName == "" {
+		cfg.DurableName = cfg.QueueGroup
+	}
+
+	if cfg.Consumer.MaxInflight <= 0 {
+		cfg.Consumer.MaxInflight = cfg.QueueDepth
+	}
+
+	if cfg.ShadowIdleTimeout <= 0 {
+		cfg.ShadowIdleTimeout = defaultShadowIdleTimeout
+	}
+
+	if deps.Codec == nil {
+		// Конфигурация по умолчанию всегда корректна
+		deps.Codec, _ = ordercodec.NewMux(ordercodec.Config{}, ordercodec.Dependencies{})
+	}
+
 	return &NatsOrderStore{
 		cfg:  cfg,
 		deps: deps,
-		log:  deps.Log.WithField("component", "ordernats"),
+		consumer: orderevent.NewConsumer(cfg.Consumer, orderevent.ConsumerDependencies{
+			Log:   deps.Log,
+			Store: deps.Store,
+			Codec: deps.Codec,
+		}),
+		log: deps.Log.WithField("component", "ordernats"),
 	}
 }
 
+// stanMessage - сообщение STAN для orderevent.Consumer
+type stanMessage struct {
+	msg *stan.Msg
+}
+
+func (m stanMessage) Data() []byte               { return m.msg.Data }
+func (m stanMessage) Seq() schema.SeqNumber      { return schema.SeqNumber(m.msg.Sequence) }
+func (m stanMessage) Headers() map[string]string { return nil }
+func (m stanMessage) Ack() error                 { return m.msg.Ack() }
+
+// Nack ничего не делает: STAN доставляет неподтвержденное сообщение
+// повторно после AckWait.
+func (m stanMessage) Nack() error { return nil }
+
+// subscription передает сообщения подписки циклу обработки Consumer.Run.
+// Consumer приостанавливает ее, пока хранилище недоступно.
+type subscription struct {
+	n        *NatsOrderStore
+	messages chan orderevent.Message
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mu sync.Mutex
+	// sub равен nil на паузе, stop закрывается при ее начале
+	sub    stan.Subscription
+	stop   chan struct{}
+	closed bool
+}
+
+func (s *subscription) open(start stan.SubscriptionOption) error {
+	stop := make(chan struct{})
+	handler := func(msg *stan.Msg) {
+		// После отписки сообщение остается неподтвержденным
+		select {
+		case s.messages <- stanMessage{msg: msg}:
+		case <-stop:
+		}
+	}
+
+	opts := []stan.SubscriptionOption{
+		stan.SetManualAckMode(),
+		stan.MaxInflight(s.n.cfg.QueueDepth),
+		// Для существующей durable группы начальная позиция игнорируется
+		start,
+	}
+
+	var (
+		sub stan.Subscription
+		err error
+	)
+	if s.n.cfg.QueueGroup == "" {
+		sub, err = s.n.deps.NSProvider.Subscribe(s.n.cfg.ChannelName, handler, opts...)
+	} else {
+		sub, err = s.n.deps.NSProvider.QueueSubscribe(s.n.cfg.ChannelName, s.n.cfg.QueueGroup, handler,
+			append(opts, stan.DurableName(s.n.cfg.DurableName))...)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.sub, s.stop = sub, stop
+	return nil
+}
+
+func (s *subscription) close() {
+	if s.sub == nil {
+		return
+	}
+
+	close(s.stop)
+
+	// Unsubscribe последнего участника удаляет durable группу, Close
+	// сохраняет ее позицию для других реплик и перезапуска
+	unsubscribe := s.sub.Unsubscribe
+	if s.n.cfg.QueueGroup != "" {
+		unsubscribe = s.sub.Close
+	}
+
+	if err := unsubscribe(); err != nil {
+		s.n.log.Errorf("failed to unsubscribe: %v", err)
+	}
+	s.sub = nil
+}
+
+// Pause отписывается от канала, неподтвержденные сообщения будут
+// доставлены после Resume.
+func (s *subscription) Pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.close()
+}
+
+// Resume подписывается со следующего после сохраненного номера
+// сообщения, позицию durable группы хранит сервер.
+func (s *subscription) Resume() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.sub != nil {
+		return nil
+	}
+
+	seq, err := s.n.deps.Store.SeqNumber(context.Background())
+	if err != nil {
+		return err
+	}
+
+	return s.open(stan.StartAtSequence(uint64(seq + 1)))
+}
+
 func (n *NatsOrderStore) PublishOrder(ctx context.Context, order schema.Order) error {
-	data, err := json.Marshal(&order)
+	data, err := n.deps.Codec.Encode(order)
 	if err != nil {
 		return err
 	}
 
-	err = n.deps.NSProvider.Publish(n.cfg.ChannelName, data)
+	return n.PublishRaw(ctx, data)
+}
+
+// PublishRaw публикует данные в канал заказов без проверки и кодирования.
+func (n *NatsOrderStore) PublishRaw(_ context.Context, data []byte) error {
+	err := n.deps.NSProvider.Publish(n.cfg.ChannelName, data)
 	if err != nil {
 		n.log.Errorf("failed to publish order: %v", err)
 		return err
@@ -56,7 +262,10 @@ func (n *NatsOrderStore) PublishOrder(ctx context.Context, order schema.Order) e
 }
 
 func (n *NatsOrderStore) SubscribeOnOrder(ctx context.Context) error {
-	if n.sub != nil {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.sub != nil || n.retry != nil {
 		return errors.New("already subscribed")
 	}
 
@@ -65,38 +274,379 @@ func (n *NatsOrderStore) SubscribeOnOrder(ctx context.Context) error {
 		return err
 	}
 
-	sub, err := n.deps.NSProvider.Subscribe(n.cfg.ChannelName, func(msg *stan.Msg) {
-		order := schema.Order{}
-		if err := json.Unmarshal(msg.Data, &order); err != nil {
-			n.log.Errorf("invalid order scheme: %v", err)
+	n.reportGaps(ctx, seq)
+	return n.subscribe(stan.StartAtSequence(uint64(seq + 1)))
+}
+
+// reportGaps сообщает о сообщениях, не обработанных до перезапуска.
+// Подписка начинается с первого пропуска, уже обработанные сообщения
+// выше него хранилище распознает при повторной доставке.
+func (n *NatsOrderStore) reportGaps(ctx context.Context, seq schema.SeqNumber) {
+	tracker, ok := n.deps.Store.(orderdb.SeqTracker)
+	if !ok {
+		return
+	}
+
+	progress, err := tracker.SeqProgress(ctx)
+	if err != nil {
+		n.log.Errorf("failed to get seq progress: %v", err)
+		return
+	}
+
+	if len(progress.Gaps) > 0 {
+		n.log.Warnf("resuming from seq %d: %d unprocessed messages in %d gaps up to seq %d",
+			seq+1, progress.Missing(), len(progress.Gaps), progress.HighWaterMark)
+	}
+}
+
+func (n *NatsOrderStore) subscribe(start stan.SubscriptionOption) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &subscription{
+		n:        n,
+		messages: make(chan orderevent.Message),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	if err := sub.open(start); err != nil {
+		cancel()
+		return err
+	}
+
+	consumer := orderevent.NewConsumer(n.cfg.Consumer, orderevent.ConsumerDependencies{
+		Log:          n.deps.Log,
+		Store:        n.deps.Store,
+		Codec:        n.deps.Codec,
+		Subscription: sub,
+	})
+	go func() {
+		defer close(sub.done)
+		consumer.Run(ctx, sub.messages)
+	}()
+
+	n.sub = sub
+	return nil
+}
+
+// PublishEvent публикует событие в EventsChannel. Publish в STAN ждет
+// подтверждения сервера, поэтому ошибка означает, что событие нужно
+// опубликовать повторно.
+func (n *NatsOrderStore) PublishEvent(_ context.Context, event orderevent.Event) error {
+	if n.cfg.EventsChannel == "" {
+		return errors.New("events channel is not configured")
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return n.deps.NSProvider.Publish(n.cfg.EventsChannel, data)
+}
+
+func (n *NatsOrderStore) Unsubscribe() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.unsubscribe()
+	n.stopShadow()
+}
+
+func (n *NatsOrderStore) unsubscribe() {
+	if n.retry != nil {
+		close(n.retry)
+		n.retry = nil
+	}
+
+	if n.sub == nil {
+		return
+	}
+
+	// closed не дает Consumer возобновить подписку после отписки
+	n.sub.mu.Lock()
+	n.sub.closed = true
+	n.sub.close()
+	n.sub.mu.Unlock()
+
+	// Обработка принятых сообщений завершается до возврата, чтобы после
+	// Seek не записать заказ со старой позиции
+	n.sub.cancel()
+	<-n.sub.done
+	n.sub = nil
+}
+
+// resubscribe подписывается со следующего после after номера. Если
+// подписаться не удается, попытки повторяются в фоне до успеха или
+// Unsubscribe, чтобы обработка не остановилась до перезапуска сервиса.
+func (n *NatsOrderStore) resubscribe(after schema.SeqNumber) error {
+	start := stan.StartAtSequence(uint64(after + 1))
+	err := n.subscribe(start)
+	if err == nil {
+		return nil
+	}
+
+	n.log.Errorf("failed to subscribe from seq %d, retrying: %v", after+1, err)
+	retry := make(chan struct{})
+	n.retry = retry
+	go func() {
+		ticker := time.NewTicker(resubscribeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-retry:
+				return
+			case <-ticker.C:
+			}
+
+			n.mu.Lock()
+			select {
+			case <-retry:
+				n.mu.Unlock()
+				return
+			default:
+			}
+
+			if err := n.subscribe(start); err != nil {
+				n.log.Errorf("failed to subscribe from seq %d: %v", after+1, err)
+				n.mu.Unlock()
+				continue
+			}
+
+			n.retry = nil
+			n.mu.Unlock()
+			n.log.Infof("subscribed from seq %d", after+1)
 			return
 		}
+	}()
 
-		if err := n.deps.Store.AddOrder(context.Background(), order,
-			schema.SeqNumber(msg.Sequence)); err != nil {
-			return
+	return err
+}
+
+func (n *NatsOrderStore) Seek(ctx context.Context, seq schema.SeqNumber) error {
+	admin, ok := n.deps.Store.(orderdb.AdminOrderDB)
+	if !ok {
+		return errors.New("store does not support setting seq number")
+	}
+
+	if n.cfg.QueueGroup != "" {
+		return ErrQueueGroup
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	prev, err := n.deps.Store.SeqNumber(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Подписка останавливается до записи номера, чтобы сообщение в
+	// обработке не сдвинуло его. При ошибке обработка продолжается с
+	// прежнего места.
+	n.unsubscribe()
+	if err := admin.SetSeqNumber(ctx, seq); err != nil {
+		_ = n.resubscribe(prev)
+		return err
+	}
+
+	n.log.Infof("seek to seq %d", seq)
+	return n.resubscribe(seq)
+}
+
+// Replay повторно обрабатывает сообщения начиная с opts.FromSeq или
+// первого сообщения после opts.FromTime. Хранилище, отслеживающее
+// номера, забывает, что они обработаны, поэтому заказы записываются
+// заново, а после перезапуска обработка продолжится с начала повторной.
+func (n *NatsOrderStore) Replay(ctx context.Context, opts orderevent.ReplayOptions) error {
+	if opts.Shadow {
+		start := stan.StartAtSequence(uint64(opts.FromSeq))
+		if !opts.FromTime.IsZero() {
+			start = stan.StartAtTime(opts.FromTime)
 		}
+		return n.replayShadow(ctx, start)
+	}
+
+	if n.cfg.QueueGroup != "" {
+		return ErrQueueGroup
+	}
 
-		// Операция вставки в БД идемпотента
-		// Поэтому не имеет смысла обрабатывать ошибочное подтверждение обработки сообщения
-		if err := msg.Ack(); err != nil {
-			n.log.Errorf("failed to ack message: %v", err)
+	from := opts.FromSeq
+	if !opts.FromTime.IsZero() {
+		var err error
+		if from, err = n.firstSeq(ctx, opts.FromTime); err != nil {
+			return err
 		}
-	},
-		stan.SetManualAckMode(),
-		stan.MaxInflight(n.cfg.QueueDepth),
-		stan.StartAtSequence(uint64(seq+1)))
+	}
+	from = max(from, 1)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	prev, err := n.deps.Store.SeqNumber(ctx)
+	if err != nil {
+		return err
+	}
+
+	n.unsubscribe()
+	if tracker, ok := n.deps.Store.(orderdb.SeqTracker); ok {
+		if err := tracker.Rewind(ctx, from); err != nil {
+			_ = n.resubscribe(prev)
+			return err
+		}
+	}
+
+	n.log.Infof("replay from seq %d", from)
+	return n.resubscribe(from - 1)
+}
+
+// firstSeq возвращает номер первого сообщения канала, опубликованного не
+// раньше from.
+func (n *NatsOrderStore) firstSeq(ctx context.Context, from time.Time) (schema.SeqNumber, error) {
+	first := make(chan uint64, 1)
+	sub, err := n.deps.NSProvider.Subscribe(n.cfg.ChannelName, func(msg *stan.Msg) {
+		select {
+		case first <- msg.Sequence:
+		default:
+		}
+	}, stan.StartAtTime(from), stan.MaxInflight(1))
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := sub.Unsubscribe(); err != nil {
+			n.log.Errorf("failed to unsubscribe: %v", err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, firstSeqTimeout)
+	defer cancel()
 
+	select {
+	case seq := <-first:
+		return schema.SeqNumber(seq), nil
+	case <-ctx.Done():
+		return 0, fmt.Errorf("no messages since %s: %w", from.Format(time.RFC3339), ctx.Err())
+	}
+}
+
+func (n *NatsOrderStore) replayShadow(ctx context.Context, start stan.SubscriptionOption) error {
+	until, err := n.deps.Store.SeqNumber(ctx)
 	if err != nil {
 		return err
 	}
 
-	n.sub = &sub
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.shadow.report.Running {
+		return errors.New("shadow replay is already running")
+	}
+
+	n.shadow.report = orderevent.ReplayReport{
+		Running:   true,
+		StartedAt: time.Now(),
+		UntilSeq:  until,
+	}
+
+	if until == 0 {
+		n.shadow.report.Running = false
+		return nil
+	}
+
+	sub, err := n.deps.NSProvider.Subscribe(n.cfg.ChannelName, n.handleShadow,
+		stan.MaxInflight(n.cfg.QueueDepth),
+		start)
+	if err != nil {
+		n.shadow.report.Running = false
+		return err
+	}
+
+	n.shadow.sub = sub
+	n.shadow.gen++
+	gen := n.shadow.gen
+	n.shadow.idle = time.AfterFunc(n.cfg.ShadowIdleTimeout, func() { n.shadowIdle(gen) })
+	n.log.Infof("shadow replay started until seq %d", until)
 	return nil
 }
 
-func (n *NatsOrderStore) Unsubscribe() {
-	if err := (*n.sub).Unsubscribe(); err != nil {
-		n.log.Errorf("failed to unsubscribe: %v", err)
+// shadowIdle завершает теневую обработку, в которой сообщения перестали
+// приходить раньше UntilSeq.
+func (n *NatsOrderStore) shadowIdle(gen int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if gen != n.shadow.gen || !n.shadow.report.Running {
+		return
 	}
+
+	n.shadow.report.TimedOut = true
+	n.log.Warnf("shadow replay received no messages for %v before seq %d",
+		n.cfg.ShadowIdleTimeout, n.shadow.report.UntilSeq)
+	n.stopShadow()
+}
+
+func (n *NatsOrderStore) handleShadow(msg *stan.Msg) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	report := &n.shadow.report
+	if !report.Running {
+		return
+	}
+
+	seq := schema.SeqNumber(msg.Sequence)
+	if seq > report.UntilSeq {
+		n.stopShadow()
+		return
+	}
+
+	n.shadow.idle.Reset(n.cfg.ShadowIdleTimeout)
+	report.LastSeq = seq
+	report.Processed++
+
+	if _, err := n.consumer.Decode(stanMessage{msg: msg}); err != nil {
+		report.Invalid++
+		if len(report.Errors) < maxShadowErrors {
+			report.Errors = append(report.Errors, fmt.Sprintf("seq %d: %v", seq, err))
+		}
+	}
+
+	if seq == report.UntilSeq {
+		n.stopShadow()
+	}
+}
+
+func (n *NatsOrderStore) stopShadow() {
+	if !n.shadow.report.Running {
+		return
+	}
+
+	n.shadow.report.Running = false
+	if n.shadow.idle != nil {
+		n.shadow.idle.Stop()
+	}
+	n.log.Infof("shadow replay finished: processed %d, invalid %d",
+		n.shadow.report.Processed, n.shadow.report.Invalid)
+
+	// Отписка из обработчика сообщения блокирует подписку, поэтому
+	// выполняется асинхронно
+	sub := n.shadow.sub
+	n.shadow.sub = nil
+	if sub != nil {
+		go func() {
+			if err := sub.Unsubscribe(); err != nil {
+				n.log.Errorf("failed to unsubscribe shadow replay: %v", err)
+			}
+		}()
+	}
+}
+
+func (n *NatsOrderStore) ShadowReport() orderevent.ReplayReport {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	report := n.shadow.report
+	report.Errors = append([]string(nil), report.Errors...)
+	return report
 }
@@ -0,0 +1,201 @@
+package ordernats_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"orderservice/internal/orderdb/orderbolt"
+	"orderservice/internal/orderdb/ordercache"
+	"orderservice/internal/orderdb/orderdbtest"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/orderevent/ordernats"
+	"orderservice/internal/provider/natsprovider/natstest"
+	"orderservice/internal/schema"
+	"orderservice/internal/server"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// Заказ проходит путь от публикации в канал до HTTP ответа сервера.
+// Некорректное сообщение не записывает заказ и не оставляет пропуск перед
+// low-water mark, даже если остается неподтвержденным.
+func TestPipeline(t *testing.T) {
+	for _, rejectInvalid := range []bool{true, false} {
+		rejectInvalid := rejectInvalid
+		t.Run(fmt.Sprintf("reject_invalid_%t", rejectInvalid), func(t *testing.T) {
+			testPipeline(t, rejectInvalid)
+		})
+	}
+}
+
+func testPipeline(t *testing.T, rejectInvalid bool) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	log := logrus.New()
+	stan := natstest.Start(t)
+
+	persistent, err := orderbolt.New(orderbolt.Config{Path: filepath.Join(t.TempDir(), "orders.db")},
+		orderbolt.Dependencies{Log: log})
+	require.NoError(t, err)
+	t.Cleanup(func() { persistent.Close() })
+
+	cache := ordercache.New(ordercache.Config{}, ordercache.Dependencies{Persistent: persistent})
+	consumer := ordernats.New(
+		ordernats.Config{
+			ChannelName: "orders",
+			QueueDepth:  16,
+			Consumer:    orderevent.ConsumerConfig{RejectInvalid: rejectInvalid},
+		},
+		ordernats.Dependencies{Log: log, NSProvider: stan.Connect(t), Store: cache})
+	require.NoError(t, consumer.SubscribeOnOrder(ctx))
+	t.Cleanup(consumer.Unsubscribe)
+
+	srv := httptest.NewServer(server.NewServer(server.Config{AdminToken: adminToken}, server.Dependencies{
+		Log:      log,
+		DB:       cache,
+		Consumer: consumer,
+	}).Handler())
+	t.Cleanup(srv.Close)
+
+	publisher := ordernats.New(
+		ordernats.Config{ChannelName: "orders"},
+		ordernats.Dependencies{Log: log, NSProvider: stan.Connect(t)})
+
+	order := orderdbtest.Order("b563feb7b2b84b6test")
+	next := orderdbtest.Order("c563feb7b2b84b6test")
+	require.NoError(t, publisher.PublishOrder(ctx, order))
+	require.NoError(t, publisher.PublishRaw(ctx, []byte(`{"order_uid": "broken"`)))
+	require.NoError(t, publisher.PublishOrder(ctx, next))
+
+	var got schema.Order
+	require.Eventually(t, func() bool {
+		return getJSON(t, srv.URL+"/orders/"+string(order.OrderUID), &got) == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, order, got)
+
+	var seq server.SeqResponse
+	require.Eventually(t, func() bool {
+		return getJSON(t, srv.URL+"/admin/seq", &seq) == http.StatusOK && seq.Seq == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	progress, err := persistent.SeqProgress(ctx)
+	require.NoError(t, err)
+	require.Empty(t, progress.Gaps)
+
+	require.Equal(t, http.StatusNotFound, getJSON(t, srv.URL+"/orders/broken", &got))
+}
+
+const adminToken = "secret"
+
+func getJSON(t *testing.T, url string, v any) int {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+	}
+	return resp.StatusCode
+}
+
+// Теневая обработка завершается по ShadowIdleTimeout, если сообщение с
+// сохраненным номером не приходит
+func TestShadowReplayTimeout(t *testing.T) {
+	ctx := context.Background()
+	log := logrus.New()
+	stan := natstest.Start(t)
+
+	persistent, err := orderbolt.New(orderbolt.Config{Path: filepath.Join(t.TempDir(), "orders.db")},
+		orderbolt.Dependencies{Log: log})
+	require.NoError(t, err)
+	t.Cleanup(func() { persistent.Close() })
+	require.NoError(t, persistent.SetSeqNumber(ctx, 5))
+
+	consumer := ordernats.New(
+		ordernats.Config{ChannelName: "orders", QueueDepth: 16, ShadowIdleTimeout: 100 * time.Millisecond},
+		ordernats.Dependencies{Log: log, NSProvider: stan.Connect(t), Store: persistent})
+	require.NoError(t, consumer.PublishOrder(ctx, orderdbtest.Order("b563feb7b2b84b6test")))
+
+	require.NoError(t, consumer.Replay(ctx, orderevent.ReplayOptions{FromSeq: 1, Shadow: true}))
+	require.Eventually(t, func() bool { return !consumer.ShadowReport().Running }, 5*time.Second, 10*time.Millisecond)
+
+	report := consumer.ShadowReport()
+	require.True(t, report.TimedOut)
+	require.Equal(t, 1, report.Processed)
+	require.Equal(t, schema.SeqNumber(5), report.UntilSeq)
+}
+
+// Повторная обработка записывает заказы заново, даже если хранилище уже
+// отметило их сообщения обработанными
+func TestReplayRewrites(t *testing.T) {
+	cases := []struct {
+		name string
+		opts func(published time.Time) orderevent.ReplayOptions
+	}{
+		{
+			name: "from_seq",
+			opts: func(time.Time) orderevent.ReplayOptions {
+				return orderevent.ReplayOptions{FromSeq: 1}
+			},
+		},
+		{
+			name: "from_time",
+			opts: func(published time.Time) orderevent.ReplayOptions {
+				return orderevent.ReplayOptions{FromTime: published}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			log := logrus.New()
+			stan := natstest.Start(t)
+
+			persistent, err := orderbolt.New(orderbolt.Config{Path: filepath.Join(t.TempDir(), "orders.db")},
+				orderbolt.Dependencies{Log: log})
+			require.NoError(t, err)
+			t.Cleanup(func() { persistent.Close() })
+
+			cache := ordercache.New(ordercache.Config{}, ordercache.Dependencies{Persistent: persistent})
+			consumer := ordernats.New(
+				ordernats.Config{ChannelName: "orders", QueueDepth: 16},
+				ordernats.Dependencies{Log: log, NSProvider: stan.Connect(t), Store: cache})
+			require.NoError(t, consumer.SubscribeOnOrder(ctx))
+			t.Cleanup(consumer.Unsubscribe)
+
+			published := time.Now().Add(-time.Second)
+			order := orderdbtest.Order("b563feb7b2b84b6test")
+			require.NoError(t, consumer.PublishOrder(ctx, order))
+			require.Eventually(t, func() bool {
+				seq, err := cache.SeqNumber(ctx)
+				return err == nil && seq == 1
+			}, 5*time.Second, 10*time.Millisecond)
+
+			// Заказ изменен после обработки, например ошибочной версией сервиса
+			require.NoError(t, cache.AnonymizeOrder(ctx, order.OrderUID))
+
+			require.NoError(t, consumer.Replay(ctx, c.opts(published)))
+			require.Eventually(t, func() bool {
+				got, err := cache.GetOrder(ctx, order.OrderUID)
+				return err == nil && got.Delivery.Phone == order.Delivery.Phone
+			}, 5*time.Second, 10*time.Millisecond)
+
+			seq, err := cache.SeqNumber(ctx)
+			require.NoError(t, err)
+			require.Equal(t, schema.SeqNumber(1), seq)
+		})
+	}
+}
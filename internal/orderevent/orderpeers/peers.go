@@ -0,0 +1,128 @@
+// Package orderpeers распространяет изменения кеша между репликами
+// сервиса через широковещательный subject NATS.
+package orderpeers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"orderservice/internal/orderdb"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultSubject = "orders.cache"
+
+	applyTimeout = 5 * time.Second
+)
+
+type Config struct {
+	// Subject, на который подписаны все реплики
+	Subject string
+	// ReplicaID отличает собственные уведомления от чужих, обычно это
+	// ClientID подключения к STAN
+	ReplicaID string
+}
+
+type Dependencies struct {
+	Log  *logrus.Logger
+	Conn *nats.Conn
+}
+
+// message - уведомление на subject. Core NATS доставляет его не более
+// одного раза: реплика, пропустившая уведомления из-за отключения,
+// догоняет остальных через admin/cache/refresh.
+type message struct {
+	Origin string              `json:"origin"`
+	Change orderdb.OrderChange `json:"change"`
+}
+
+// Broadcaster реализует orderdb.ChangeNotifier и применяет уведомления
+// других реплик.
+type Broadcaster struct {
+	cfg  Config
+	deps Dependencies
+
+	mu  sync.Mutex
+	sub *nats.Subscription
+
+	log *logrus.Entry
+}
+
+func New(cfg Config, deps Dependencies) *Broadcaster {
+	if cfg.Subject == "" {
+		cfg.Subject = DefaultSubject
+	}
+
+	return &Broadcaster{
+		cfg:  cfg,
+		deps: deps,
+		log:  deps.Log.WithField("component", "orderpeers"),
+	}
+}
+
+func (b *Broadcaster) NotifyChange(_ context.Context, change orderdb.OrderChange) error {
+	data, err := json.Marshal(message{Origin: b.cfg.ReplicaID, Change: change})
+	if err != nil {
+		return err
+	}
+
+	return b.deps.Conn.Publish(b.cfg.Subject, data)
+}
+
+// Subscribe передает applier изменения, сделанные другими репликами.
+func (b *Broadcaster) Subscribe(applier orderdb.ChangeApplier) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.sub != nil {
+		return errors.New("already subscribed")
+	}
+
+	sub, err := b.deps.Conn.Subscribe(b.cfg.Subject, func(msg *nats.Msg) {
+		b.handle(applier, msg)
+	})
+	if err != nil {
+		return err
+	}
+
+	b.sub = sub
+	return nil
+}
+
+func (b *Broadcaster) handle(applier orderdb.ChangeApplier, msg *nats.Msg) {
+	var m message
+	if err := json.Unmarshal(msg.Data, &m); err != nil {
+		b.log.Errorf("invalid change notification: %v", err)
+		return
+	}
+
+	if m.Origin == b.cfg.ReplicaID {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), applyTimeout)
+	defer cancel()
+
+	if err := applier.ApplyChange(ctx, m.Change); err != nil {
+		b.log.Errorf("failed to apply change of %s from %s: %v", m.Change.OrderUID, m.Origin, err)
+	}
+}
+
+func (b *Broadcaster) Unsubscribe() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.sub == nil {
+		return
+	}
+
+	if err := b.sub.Unsubscribe(); err != nil {
+		b.log.Errorf("failed to unsubscribe: %v", err)
+	}
+	b.sub = nil
+}
@@ -0,0 +1,277 @@
+package orderio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"orderservice/internal/schema"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const maxLineSize = 16 * 1024 * 1024
+
+// LineError is returned by Reader for a malformed record. Reading can
+// continue after it.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Reader decodes orders one by one. Next returns the order and the
+// number of the last input line (CSV record for CSV) it was read from,
+// io.EOF at the end of input and *LineError for malformed records.
+type Reader interface {
+	Next() (schema.Order, int, error)
+}
+
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		return &ndjsonReader{scanner: scanner}, nil
+	case FormatCSV:
+		return newCSVReader(r)
+	}
+
+	return nil, fmt.Errorf("unsupported import format: %s", format)
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (n *ndjsonReader) Next() (schema.Order, int, error) {
+	for n.scanner.Scan() {
+		n.line++
+		data := bytes.TrimSpace(n.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var order schema.Order
+		if err := json.Unmarshal(data, &order); err != nil {
+			return schema.Order{}, n.line, &LineError{Line: n.line, Err: err}
+		}
+
+		return order, n.line, nil
+	}
+
+	if err := n.scanner.Err(); err != nil {
+		return schema.Order{}, n.line, err
+	}
+
+	return schema.Order{}, n.line, io.EOF
+}
+
+// csvReader собирает заказ из идущих подряд строк с одинаковым order_uid,
+// как их записывает csvWriter.
+type csvReader struct {
+	r       *csv.Reader
+	columns []int
+	line    int
+
+	rows    []Row
+	pending *Row
+	eof     bool
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+
+	index := make(map[string]int)
+	t := reflect.TypeOf(Row{})
+	for i := 0; i < t.NumField(); i++ {
+		index[columnName(t.Field(i))] = i
+	}
+
+	columns := make([]int, len(header))
+	for i, name := range header {
+		field, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown csv column: %s", name)
+		}
+
+		columns[i] = field
+	}
+
+	return &csvReader{r: cr, columns: columns, line: 1}, nil
+}
+
+func (c *csvReader) readRow() (*Row, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			c.line++
+			return nil, &LineError{Line: c.line, Err: err}
+		}
+
+		return nil, err
+	}
+	c.line++
+
+	row := &Row{}
+	v := reflect.ValueOf(row).Elem()
+	for i, value := range record {
+		f := v.Field(c.columns[i])
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(value)
+		case reflect.Int64:
+			if value == "" {
+				continue
+			}
+
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, &LineError{Line: c.line, Err: fmt.Errorf("column %d: %w", i+1, err)}
+			}
+			f.SetInt(n)
+		case reflect.Struct:
+			if f.Type() != timeType {
+				continue
+			}
+
+			ts, err := schema.ParseTimestamp(value)
+			if err != nil {
+				return nil, &LineError{Line: c.line, Err: fmt.Errorf("column %d: %w", i+1, err)}
+			}
+			f.Set(reflect.ValueOf(ts.Time))
+		}
+	}
+
+	return row, nil
+}
+
+func (c *csvReader) Next() (schema.Order, int, error) {
+	for {
+		if c.pending == nil && !c.eof {
+			row, err := c.readRow()
+			switch {
+			case errors.Is(err, io.EOF):
+				c.eof = true
+			case err != nil:
+				// Собранные строки заказа сохраняются и будут отданы
+				// следующим вызовом
+				return schema.Order{}, c.line, err
+			default:
+				c.pending = row
+			}
+		}
+
+		if c.pending == nil {
+			if len(c.rows) == 0 {
+				return schema.Order{}, c.line, io.EOF
+			}
+
+			return c.flush(c.line)
+		}
+
+		if len(c.rows) > 0 && c.rows[0].OrderUID != c.pending.OrderUID {
+			// Заказ закончился на предыдущей строке
+			return c.flush(c.line - 1)
+		}
+
+		c.rows = append(c.rows, *c.pending)
+		c.pending = nil
+	}
+}
+
+func (c *csvReader) flush(line int) (schema.Order, int, error) {
+	order := orderFromRows(c.rows)
+	c.rows = c.rows[:0]
+	return order, line, nil
+}
+
+func orderFromRows(rows []Row) schema.Order {
+	r := rows[0]
+	order := schema.Order{
+		OrderUID:        schema.OrderUID(r.OrderUID),
+		TrackNumber:     r.TrackNumber,
+		Entry:           r.Entry,
+		Locale:          r.Locale,
+		InternalSign:    r.InternalSign,
+		CustomerID:      r.CustomerID,
+		DeliveryService: r.DeliveryService,
+		Shardkey:        int(r.Shardkey),
+		SmID:            int(r.SmID),
+		DateCreated:     timestamp(r.DateCreated),
+		OofShard:        int(r.OofShard),
+		Delivery: schema.Delivery{
+			Name:   r.DeliveryName,
+			Phone:  r.DeliveryPhone,
+			Zip:    int(r.DeliveryZip),
+			City:   r.DeliveryCity,
+			Adress: r.DeliveryAddress,
+			Region: r.DeliveryRegion,
+			Email:  r.DeliveryEmail,
+		},
+		Payment: schema.Payment{
+			Transaction:   r.PaymentTransaction,
+			RequestID:     r.PaymentRequestID,
+			Currency:      r.PaymentCurrency,
+			Provider:      r.PaymentProvider,
+			Amount:        int(r.PaymentAmount),
+			PaymentDT:     timestamp(r.PaymentDT),
+			Bank:          r.PaymentBank,
+			DeliveryConst: int(r.PaymentDeliveryCost),
+			GoodsTotal:    int(r.PaymentGoodsTotal),
+			CustomFee:     int(r.PaymentCustomFee),
+		},
+	}
+
+	for _, r := range rows {
+		// Строка без товара соответствует заказу без товаров
+		if r.ItemName == "" && r.ItemChrtID == 0 && r.ItemRID == "" {
+			continue
+		}
+
+		order.Items = append(order.Items, schema.Item{
+			ChrtID:      int(r.ItemChrtID),
+			TrackNumber: r.ItemTrackNumber,
+			Price:       int(r.ItemPrice),
+			RID:         r.ItemRID,
+			Name:        r.ItemName,
+			Sale:        int(r.ItemSale),
+			Size:        int(r.ItemSize),
+			TotalPrice:  int(r.ItemTotalPrice),
+			NmID:        int(r.ItemNmID),
+			Brand:       r.ItemBrand,
+			Status:      int(r.ItemStatus),
+		})
+	}
+
+	return order
+}
+
+// timestamp keeps zero time zero: parquet stores it as a timestamp in
+// the year 1 which is read back in UTC.
+func timestamp(t time.Time) schema.Timestamp {
+	if t.IsZero() {
+		return schema.Timestamp{}
+	}
+
+	return schema.NewTimestamp(t)
+}
@@ -0,0 +1,59 @@
+package orderio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"orderservice/internal/schema"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, r Reader) ([]schema.Order, []int) {
+	var (
+		orders []schema.Order
+		lines  []int
+	)
+
+	for {
+		order, _, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return orders, lines
+		}
+
+		var lineErr *LineError
+		if errors.As(err, &lineErr) {
+			lines = append(lines, lineErr.Line)
+			continue
+		}
+
+		require.NoError(t, err)
+		orders = append(orders, order)
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	r, err := NewReader(bytes.NewReader(writeAll(t, FormatCSV)), FormatCSV)
+	require.NoError(t, err)
+
+	orders, errLines := readAll(t, r)
+	require.Empty(t, errLines)
+	require.Equal(t, testOrders, orders)
+}
+
+func TestNDJSONReader(t *testing.T) {
+	input := `{"order_uid":"1"}
+
+{broken
+{"order_uid":"2"}
+`
+	r, err := NewReader(strings.NewReader(input), FormatNDJSON)
+	require.NoError(t, err)
+
+	orders, errLines := readAll(t, r)
+	require.Equal(t, []int{3}, errLines)
+	require.Len(t, orders, 2)
+	require.EqualValues(t, "2", orders[1].OrderUID)
+}
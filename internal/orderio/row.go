@@ -0,0 +1,157 @@
+package orderio
+
+import (
+	"orderservice/internal/schema"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Row is a flattened order with one row per item. Orders without items
+// produce a single row with empty item columns.
+type Row struct {
+	OrderUID        string    `parquet:"order_uid"`
+	TrackNumber     string    `parquet:"track_number"`
+	Entry           string    `parquet:"entry"`
+	Locale          string    `parquet:"locale"`
+	InternalSign    string    `parquet:"internal_signature"`
+	CustomerID      string    `parquet:"customer_id"`
+	DeliveryService string    `parquet:"delivery_service"`
+	Shardkey        int64     `parquet:"shardkey"`
+	SmID            int64     `parquet:"sm_id"`
+	DateCreated     time.Time `parquet:"date_created,timestamp(millisecond)"`
+	OofShard        int64     `parquet:"oof_shard"`
+
+	DeliveryName    string `parquet:"delivery_name"`
+	DeliveryPhone   string `parquet:"delivery_phone"`
+	DeliveryZip     int64  `parquet:"delivery_zip"`
+	DeliveryCity    string `parquet:"delivery_city"`
+	DeliveryAddress string `parquet:"delivery_address"`
+	DeliveryRegion  string `parquet:"delivery_region"`
+	DeliveryEmail   string `parquet:"delivery_email"`
+
+	PaymentTransaction  string    `parquet:"payment_transaction"`
+	PaymentRequestID    string    `parquet:"payment_request_id"`
+	PaymentCurrency     string    `parquet:"payment_currency"`
+	PaymentProvider     string    `parquet:"payment_provider"`
+	PaymentAmount       int64     `parquet:"payment_amount"`
+	PaymentDT           time.Time `parquet:"payment_dt,timestamp(millisecond)"`
+	PaymentBank         string    `parquet:"payment_bank"`
+	PaymentDeliveryCost int64     `parquet:"payment_delivery_cost"`
+	PaymentGoodsTotal   int64     `parquet:"payment_goods_total"`
+	PaymentCustomFee    int64     `parquet:"payment_custom_fee"`
+
+	ItemChrtID      int64  `parquet:"item_chrt_id"`
+	ItemTrackNumber string `parquet:"item_track_number"`
+	ItemPrice       int64  `parquet:"item_price"`
+	ItemRID         string `parquet:"item_rid"`
+	ItemName        string `parquet:"item_name"`
+	ItemSale        int64  `parquet:"item_sale"`
+	ItemSize        int64  `parquet:"item_size"`
+	ItemTotalPrice  int64  `parquet:"item_total_price"`
+	ItemNmID        int64  `parquet:"item_nm_id"`
+	ItemBrand       string `parquet:"item_brand"`
+	ItemStatus      int64  `parquet:"item_status"`
+}
+
+// Columns returns column names in the order used by CSV export.
+func Columns() []string {
+	t := reflect.TypeOf(Row{})
+	columns := make([]string, t.NumField())
+	for i := range columns {
+		columns[i] = columnName(t.Field(i))
+	}
+
+	return columns
+}
+
+func columnName(f reflect.StructField) string {
+	tag := f.Tag.Get("parquet")
+	for i := range tag {
+		if tag[i] == ',' {
+			return tag[:i]
+		}
+	}
+
+	return tag
+}
+
+func Rows(o schema.Order) []Row {
+	base := Row{
+		OrderUID:        string(o.OrderUID),
+		TrackNumber:     o.TrackNumber,
+		Entry:           o.Entry,
+		Locale:          o.Locale,
+		InternalSign:    o.InternalSign,
+		CustomerID:      o.CustomerID,
+		DeliveryService: o.DeliveryService,
+		Shardkey:        int64(o.Shardkey),
+		SmID:            int64(o.SmID),
+		DateCreated:     o.DateCreated.Time,
+		OofShard:        int64(o.OofShard),
+
+		DeliveryName:    o.Delivery.Name,
+		DeliveryPhone:   o.Delivery.Phone,
+		DeliveryZip:     int64(o.Delivery.Zip),
+		DeliveryCity:    o.Delivery.City,
+		DeliveryAddress: o.Delivery.Adress,
+		DeliveryRegion:  o.Delivery.Region,
+		DeliveryEmail:   o.Delivery.Email,
+
+		PaymentTransaction:  o.Payment.Transaction,
+		PaymentRequestID:    o.Payment.RequestID,
+		PaymentCurrency:     o.Payment.Currency,
+		PaymentProvider:     o.Payment.Provider,
+		PaymentAmount:       int64(o.Payment.Amount),
+		PaymentDT:           o.Payment.PaymentDT.Time,
+		PaymentBank:         o.Payment.Bank,
+		PaymentDeliveryCost: int64(o.Payment.DeliveryConst),
+		PaymentGoodsTotal:   int64(o.Payment.GoodsTotal),
+		PaymentCustomFee:    int64(o.Payment.CustomFee),
+	}
+
+	if len(o.Items) == 0 {
+		return []Row{base}
+	}
+
+	rows := make([]Row, len(o.Items))
+	for i, item := range o.Items {
+		row := base
+		row.ItemChrtID = int64(item.ChrtID)
+		row.ItemTrackNumber = item.TrackNumber
+		row.ItemPrice = int64(item.Price)
+		row.ItemRID = item.RID
+		row.ItemName = item.Name
+		row.ItemSale = int64(item.Sale)
+		row.ItemSize = int64(item.Size)
+		row.ItemTotalPrice = int64(item.TotalPrice)
+		row.ItemNmID = int64(item.NmID)
+		row.ItemBrand = item.Brand
+		row.ItemStatus = int64(item.Status)
+		rows[i] = row
+	}
+
+	return rows
+}
+
+// record formats the row as CSV fields in Columns order.
+func (r *Row) record() []string {
+	v := reflect.ValueOf(r).Elem()
+	record := make([]string, v.NumField())
+	for i := range record {
+		switch f := v.Field(i); f.Kind() {
+		case reflect.String:
+			record[i] = f.String()
+		case reflect.Int64:
+			record[i] = strconv.FormatInt(f.Int(), 10)
+		case reflect.Struct:
+			if f.Type() == timeType {
+				record[i] = schema.NewTimestamp(f.Interface().(time.Time)).String()
+			}
+		}
+	}
+
+	return record
+}
@@ -0,0 +1,127 @@
+package orderio
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"orderservice/internal/schema"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+type Format string
+
+const (
+	FormatNDJSON  Format = "ndjson"
+	FormatCSV     Format = "csv"
+	FormatParquet Format = "parquet"
+)
+
+// Количество строк в одной группе parquet, ограничивает потребление памяти
+const parquetRowGroupSize = 10000
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatNDJSON, FormatCSV, FormatParquet:
+		return f, nil
+	case "":
+		return FormatNDJSON, nil
+	}
+
+	return "", fmt.Errorf("unsupported format: %s", s)
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	}
+
+	return "application/x-ndjson"
+}
+
+// Writer encodes orders one by one without buffering the whole result.
+// Close must be called to flush buffered data, it does not close the
+// underlying io.Writer.
+type Writer interface {
+	Write(order schema.Order) error
+	Close() error
+}
+
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case FormatNDJSON:
+		bw := bufio.NewWriter(w)
+		return &ndjsonWriter{w: bw, enc: json.NewEncoder(bw)}, nil
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(Columns()); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw}, nil
+	case FormatParquet:
+		return &parquetWriter{w: parquet.NewGenericWriter[Row](w)}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported format: %s", format)
+}
+
+type ndjsonWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(order schema.Order) error {
+	return n.enc.Encode(&order)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.w.Flush()
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Write(order schema.Order) error {
+	for _, row := range Rows(order) {
+		if err := c.w.Write(row.record()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type parquetWriter struct {
+	w       *parquet.GenericWriter[Row]
+	pending int
+}
+
+func (p *parquetWriter) Write(order schema.Order) error {
+	rows := Rows(order)
+	if _, err := p.w.Write(rows); err != nil {
+		return err
+	}
+
+	p.pending += len(rows)
+	if p.pending < parquetRowGroupSize {
+		return nil
+	}
+
+	p.pending = 0
+	return p.w.Flush()
+}
+
+func (p *parquetWriter) Close() error {
+	return p.w.Close()
+}
@@ -0,0 +1,67 @@
+package orderio
+
+import (
+	"bytes"
+	"encoding/csv"
+	"orderservice/internal/schema"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/require"
+)
+
+var testOrders = []schema.Order{
+	{
+		OrderUID: "1",
+		Delivery: schema.Delivery{Name: "Test Testov", Adress: "Ploshad Mira 15"},
+		Payment:  schema.Payment{Currency: "USD", Amount: 1817, GoodsTotal: 317, DeliveryConst: 1500},
+		Items: schema.Items{
+			{Name: "Mascaras", TotalPrice: 200},
+			{Name: "Lipstick", TotalPrice: 117},
+		},
+	},
+	{OrderUID: "2"},
+}
+
+func writeAll(t *testing.T, format Format) []byte {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, format)
+	require.NoError(t, err)
+	for _, order := range testOrders {
+		require.NoError(t, w.Write(order))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestCSVWriter(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(writeAll(t, FormatCSV))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	require.Equal(t, Columns(), records[0])
+
+	col := func(name string) int {
+		for i, c := range records[0] {
+			if c == name {
+				return i
+			}
+		}
+		t.Fatalf("no column %s", name)
+		return 0
+	}
+
+	require.Equal(t, "Ploshad Mira 15", records[1][col("delivery_address")])
+	require.Equal(t, "Mascaras", records[1][col("item_name")])
+	require.Equal(t, "Lipstick", records[2][col("item_name")])
+	require.Equal(t, "1817", records[2][col("payment_amount")])
+	require.Equal(t, "2", records[3][col("order_uid")])
+}
+
+func TestParquetWriter(t *testing.T) {
+	data := writeAll(t, FormatParquet)
+	rows, err := parquet.Read[Row](bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	require.Equal(t, "Lipstick", rows[1].ItemName)
+	require.EqualValues(t, 1817, rows[1].PaymentAmount)
+}
@@ -0,0 +1,185 @@
+// Package outbox публикует события, записанные в хранилище в одной
+// транзакции с заказом. Событие удаляется из очереди только после
+// успешной публикации, поэтому доставка происходит хотя бы один раз.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/schema"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Получатели событий. Для каждого событие записывается отдельной строкой
+// и публикуется своим Relay, поэтому ошибка одного получателя не
+// задерживает и не повторяет публикацию в другой.
+const (
+	TargetEvents   = "events"
+	TargetWebhooks = "webhooks"
+)
+
+const (
+	defaultInterval      = time.Second
+	defaultBatchSize     = 100
+	defaultKeepPublished = 24 * time.Hour
+	// maxFailures - ошибки публикации подряд, после которых Flush
+	// прерывается: скорее всего недоступен сам получатель
+	maxFailures = 5
+)
+
+// Record - событие в очереди outbox.
+type Record struct {
+	ID       int64
+	Event    orderevent.Event
+	Attempts int
+}
+
+type Store interface {
+	// PendingEvents возвращает неопубликованные события получателя target
+	// с ID больше after в порядке записи
+	PendingEvents(ctx context.Context, target string, after int64, limit int) ([]Record, error)
+	MarkPublished(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, reason string) error
+	// DeletePublished удаляет события, опубликованные до before
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Lease проверяет, что реплика все еще лидер, реализуется leader.Lease.
+// После паузы процесса лидером могла стать другая реплика, которая уже
+// публикует те же события.
+type Lease interface {
+	Check(ctx context.Context) error
+}
+
+type Config struct {
+	// Target - получатель, события которого публикует Relay
+	Target    string
+	Interval  time.Duration
+	BatchSize int
+	// KeepPublished - сколько хранить опубликованные события для разбора
+	KeepPublished time.Duration
+}
+
+type Dependencies struct {
+	Log       *logrus.Logger
+	Store     Store
+	Publisher orderevent.EventPublisher
+}
+
+// Relay переносит события получателя Target из Store в Publisher. Должен
+// работать в одном экземпляре, см. internal/leader, иначе события одного
+// заказа могут публиковаться не по порядку.
+type Relay struct {
+	cfg  Config
+	deps Dependencies
+	now  func() time.Time
+
+	log *logrus.Entry
+}
+
+func New(cfg Config, deps Dependencies) *Relay {
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.KeepPublished == 0 {
+		cfg.KeepPublished = defaultKeepPublished
+	}
+
+	return &Relay{
+		cfg:  cfg,
+		deps: deps,
+		now:  time.Now,
+		log:  deps.Log.WithField("component", "outbox"),
+	}
+}
+
+// Run публикует события раз в Interval до отмены ctx.
+func (r *Relay) Run(ctx context.Context, lease Lease) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Flush(ctx, lease); err != nil && !errors.Is(err, context.Canceled) {
+			r.log.Errorf("flush failed: %v", err)
+		}
+
+		deleted, err := r.deps.Store.DeletePublished(ctx, r.now().Add(-r.cfg.KeepPublished))
+		if err != nil && !errors.Is(err, context.Canceled) {
+			r.log.Errorf("failed to delete published events: %v", err)
+		} else if deleted > 0 {
+			r.log.Debugf("deleted %d published events", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush публикует все накопившиеся события. Если событие не
+// опубликовано, следующие события того же заказа откладываются до
+// следующего вызова, чтобы не нарушать их порядок, а события других
+// заказов публикуются. После maxFailures ошибок подряд публикация
+// прерывается до следующего вызова. Возвращает первую ошибку публикации.
+// Лидерство проверяется перед каждой пачкой событий.
+func (r *Relay) Flush(ctx context.Context, lease Lease) (int, error) {
+	var (
+		published, failures int
+		after               int64
+		firstErr            error
+		blocked             = make(map[schema.OrderUID]bool)
+	)
+	for {
+		if err := lease.Check(ctx); err != nil {
+			return published, err
+		}
+
+		records, err := r.deps.Store.PendingEvents(ctx, r.cfg.Target, after, r.cfg.BatchSize)
+		if err != nil {
+			return published, err
+		}
+
+		for _, rec := range records {
+			after = rec.ID
+			if blocked[rec.Event.OrderUID] {
+				continue
+			}
+
+			if err := r.deps.Publisher.PublishEvent(ctx, rec.Event); err != nil {
+				if markErr := r.deps.Store.MarkFailed(ctx, rec.ID, err.Error()); markErr != nil {
+					r.log.Errorf("failed to record publish error of event %s: %v", rec.Event.ID, markErr)
+				}
+
+				blocked[rec.Event.OrderUID] = true
+				if firstErr == nil {
+					firstErr = err
+				}
+				failures++
+				if failures == maxFailures {
+					return published, firstErr
+				}
+				continue
+			}
+			failures = 0
+
+			// Если отметка не сохранится, событие будет опубликовано
+			// повторно с тем же ID
+			if err := r.deps.Store.MarkPublished(ctx, rec.ID); err != nil {
+				return published, err
+			}
+			published++
+		}
+
+		if len(records) < r.cfg.BatchSize {
+			return published, firstErr
+		}
+	}
+}
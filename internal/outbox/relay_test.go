@@ -0,0 +1,133 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/schema"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+type memStore struct {
+	records   []Record
+	published map[int64]bool
+	errors    map[int64]string
+}
+
+func (s *memStore) PendingEvents(_ context.Context, _ string, after int64, limit int) ([]Record, error) {
+	var ret []Record
+	for _, rec := range s.records {
+		if rec.ID > after && !s.published[rec.ID] && len(ret) < limit {
+			ret = append(ret, rec)
+		}
+	}
+	return ret, nil
+}
+
+func (s *memStore) MarkPublished(_ context.Context, id int64) error {
+	s.published[id] = true
+	return nil
+}
+
+func (s *memStore) MarkFailed(_ context.Context, id int64, reason string) error {
+	s.errors[id] = reason
+	return nil
+}
+
+func (s *memStore) DeletePublished(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+type publisher struct {
+	events []orderevent.Event
+	failOn map[string]bool
+}
+
+func (p *publisher) PublishEvent(_ context.Context, event orderevent.Event) error {
+	if p.failOn[event.ID] || p.failOn["*"] {
+		return errors.New("nats unavailable")
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+// lease - лидерство, которое потеряно, если lost
+type lease struct {
+	lost bool
+}
+
+func (l lease) Check(context.Context) error {
+	if l.lost {
+		return errors.New("leader lease lost")
+	}
+	return nil
+}
+
+func newStore(uids ...schema.OrderUID) *memStore {
+	store := &memStore{published: make(map[int64]bool), errors: make(map[int64]string)}
+	for i, uid := range uids {
+		store.records = append(store.records, Record{
+			ID:    int64(i + 1),
+			Event: orderevent.NewEvent(orderevent.EventOrderAccepted, uid, schema.SeqNumber(i+1), ""),
+		})
+	}
+	return store
+}
+
+func (p *publisher) seqs() []schema.SeqNumber {
+	var seqs []schema.SeqNumber
+	for _, e := range p.events {
+		seqs = append(seqs, e.Seq)
+	}
+	return seqs
+}
+
+// Ошибка откладывает следующие события того же заказа, события других
+// заказов публикуются
+func TestFlush(t *testing.T) {
+	store := newStore("a", "b", "a", "c", "a")
+	pub := &publisher{failOn: map[string]bool{store.records[0].Event.ID: true}}
+	relay := New(Config{BatchSize: 2}, Dependencies{Log: logrus.New(), Store: store, Publisher: pub})
+
+	n, err := relay.Flush(context.Background(), lease{})
+	require.Error(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, "nats unavailable", store.errors[1])
+	require.Equal(t, []schema.SeqNumber{2, 4}, pub.seqs())
+	require.False(t, store.published[3])
+
+	pub.failOn = nil
+	n, err = relay.Flush(context.Background(), lease{})
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, []schema.SeqNumber{2, 4, 1, 3, 5}, pub.seqs())
+}
+
+// Недоступный получатель не перебирает всю очередь
+func TestFlushUnavailable(t *testing.T) {
+	store := newStore("a", "b", "c", "d", "e", "f", "g")
+	pub := &publisher{failOn: map[string]bool{"*": true}}
+	relay := New(Config{BatchSize: 2}, Dependencies{Log: logrus.New(), Store: store, Publisher: pub})
+
+	n, err := relay.Flush(context.Background(), lease{})
+	require.Error(t, err)
+	require.Zero(t, n)
+	require.Len(t, store.errors, maxFailures)
+}
+
+// Реплика, потерявшая лидерство, не публикует события параллельно с
+// новым лидером
+func TestFlushLeaseLost(t *testing.T) {
+	store := newStore("a", "b")
+	pub := &publisher{}
+	relay := New(Config{}, Dependencies{Log: logrus.New(), Store: store, Publisher: pub})
+
+	n, err := relay.Flush(context.Background(), lease{lost: true})
+	require.Error(t, err)
+	require.Zero(t, n)
+	require.Empty(t, pub.events)
+}
@@ -1,18 +1,26 @@
 package natsprovider
 
 import (
+	"fmt"
+	"os"
+	"regexp"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/stan.go"
 )
 
 const (
 	defaultConnectTimeout = 3 * time.Second
+
+	DefaultClientPrefix = "orderservice"
 )
 
 type Config struct {
-	StanClusterID  string
+	StanClusterID string
+	// ClientID должен быть уникальным в кластере, пустое значение
+	// заменяется на UniqueClientID(DefaultClientPrefix)
 	ClientID       string
 	URL            string
 	ConnectTimeout time.Duration
@@ -20,6 +28,7 @@ type Config struct {
 
 type NatsProvider struct {
 	stan.Conn
+	clientID string
 }
 
 func New(cfg Config) (*NatsProvider, error) {
@@ -28,6 +37,10 @@ func New(cfg Config) (*NatsProvider, error) {
 		connectTimeout = cfg.ConnectTimeout
 	}
 
+	if cfg.ClientID == "" {
+		cfg.ClientID = UniqueClientID(DefaultClientPrefix)
+	}
+
 	sc, err := stan.Connect(cfg.StanClusterID, cfg.ClientID, stan.NatsURL(cfg.URL), stan.NatsOptions(
 		nats.Timeout(connectTimeout),
 	))
@@ -36,6 +49,32 @@ func New(cfg Config) (*NatsProvider, error) {
 	}
 
 	return &NatsProvider{
-		Conn: sc,
+		Conn:     sc,
+		clientID: cfg.ClientID,
 	}, nil
 }
+
+func (p *NatsProvider) ClientID() string {
+	return p.clientID
+}
+
+var invalidClientID = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// ValidClientID проверяет, что ClientID состоит только из символов,
+// которые допускает STAN: букв, цифр, - и _.
+func ValidClientID(id string) bool {
+	return !invalidClientID.MatchString(id)
+}
+
+// UniqueClientID строит ClientID из префикса, имени хоста и случайного
+// суффикса. STAN отклоняет второе подключение с тем же ClientID, поэтому
+// у каждой реплики он должен быть свой.
+func UniqueClientID(prefix string) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "host"
+	}
+
+	id := fmt.Sprintf("%s-%s-%s", prefix, host, uuid.NewString()[:8])
+	return invalidClientID.ReplaceAllString(id, "_")
+}
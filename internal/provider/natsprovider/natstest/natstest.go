@@ -0,0 +1,68 @@
+// Package natstest запускает NATS Streaming сервер внутри процесса теста,
+// чтобы проверять ordernats и весь конвейер обработки без контейнера.
+package natstest
+
+import (
+	"orderservice/internal/provider/natsprovider"
+	"testing"
+	"time"
+
+	stand "github.com/nats-io/nats-streaming-server/server"
+)
+
+// ClusterID - идентификатор кластера тестового сервера
+const ClusterID = "test-cluster"
+
+// Server - NATS Streaming сервер с хранилищем в памяти на случайном
+// порту. Сообщения не переживают остановку сервера.
+type Server struct {
+	stan *stand.StanServer
+}
+
+// Start запускает сервер и останавливает его по окончании теста.
+func Start(t testing.TB) *Server {
+	t.Helper()
+
+	opts := stand.GetDefaultOptions()
+	opts.ID = ClusterID
+
+	natsOpts := stand.DefaultNatsServerOptions
+	natsOpts.Host = "127.0.0.1"
+	natsOpts.Port = -1
+
+	s, err := stand.RunServerWithOpts(opts, &natsOpts)
+	if err != nil {
+		t.Fatalf("start nats streaming server: %v", err)
+	}
+	t.Cleanup(s.Shutdown)
+
+	return &Server{stan: s}
+}
+
+// URL возвращает адрес для natsprovider.Config.URL.
+func (s *Server) URL() string {
+	return s.stan.ClientURL()
+}
+
+// Config возвращает настройки подключения с уникальным ClientID.
+func (s *Server) Config() natsprovider.Config {
+	return natsprovider.Config{
+		StanClusterID:  ClusterID,
+		URL:            s.URL(),
+		ConnectTimeout: 5 * time.Second,
+	}
+}
+
+// Connect подключает нового клиента и закрывает подключение по окончании
+// теста.
+func (s *Server) Connect(t testing.TB) *natsprovider.NatsProvider {
+	t.Helper()
+
+	np, err := natsprovider.New(s.Config())
+	if err != nil {
+		t.Fatalf("connect to nats streaming server: %v", err)
+	}
+	t.Cleanup(func() { np.Close() })
+
+	return np
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"time"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 const (
@@ -14,10 +14,14 @@ const (
 type Config struct {
 	URL            string
 	ConnectTimeout time.Duration
+	// MaxConns ограничивает размер пула соединений, 0 - значение pgxpool по умолчанию
+	MaxConns int32
 }
 
+// PGXProvider предоставляет пул соединений, так как к базе одновременно
+// обращаются обработчик сообщений и HTTP сервер.
 type PGXProvider struct {
-	*pgx.Conn
+	*pgxpool.Pool
 }
 
 func New(cfg Config) (*PGXProvider, error) {
@@ -26,19 +30,35 @@ func New(cfg Config) (*PGXProvider, error) {
 		connectTimeout = cfg.ConnectTimeout
 	}
 
+	poolCfg, err := pgxpool.ParseConfig(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxConns != 0 {
+		poolCfg.MaxConns = cfg.MaxConns
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
 	defer cancel()
 
-	conn, err := pgx.Connect(ctx, cfg.URL)
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
 	if err != nil {
 		return nil, err
 	}
 
+	// pgxpool подключается лениво, проверяем доступность базы сразу
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
 	return &PGXProvider{
-		Conn: conn,
+		Pool: pool,
 	}, nil
 }
 
-func (pgx *PGXProvider) Close(ctx context.Context) error {
-	return pgx.Conn.Close(context.Background())
+func (pgx *PGXProvider) Close(_ context.Context) error {
+	pgx.Pool.Close()
+	return nil
 }
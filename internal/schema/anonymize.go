@@ -0,0 +1,16 @@
+package schema
+
+const anonymized = "anonymized"
+
+// Anonymized returns a copy of the order with customer personal data
+// removed. Order, payment and item data is kept for statistics.
+func (o Order) Anonymized() Order {
+	o.CustomerID = anonymized
+	o.Delivery = Delivery{
+		Name:   anonymized,
+		City:   o.Delivery.City,
+		Region: o.Delivery.Region,
+	}
+
+	return o
+}
@@ -0,0 +1,254 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrMoneyOverflow    = errors.New("money overflow")
+)
+
+// minorUnits is the number of digits after the decimal separator for
+// ISO 4217 currencies.
+var minorUnits = map[string]int{
+	"AED": 2, "AMD": 2, "AZN": 2, "BHD": 3, "BYN": 2, "CHF": 2, "CLP": 0,
+	"CNY": 2, "CZK": 2, "EUR": 2, "GBP": 2, "GEL": 2, "HKD": 2, "IDR": 2,
+	"ILS": 2, "INR": 2, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KGS": 2,
+	"KRW": 0, "KWD": 3, "KZT": 2, "LYD": 3, "MDL": 2, "OMR": 3, "PLN": 2,
+	"RUB": 2, "SEK": 2, "TJS": 2, "TND": 3, "TRY": 2, "UAH": 2, "USD": 2,
+	"UZS": 2, "VND": 0,
+}
+
+// MinorUnits returns the number of minor unit digits of currency.
+func MinorUnits(currency string) (int, error) {
+	units, ok := minorUnits[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+
+	return units, nil
+}
+
+// Money is an exact amount in minor units (cents for USD) of a currency.
+// Arithmetic refuses to mix currencies.
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+func NewMoney(minor int64, currency string) (Money, error) {
+	if _, err := MinorUnits(currency); err != nil {
+		return Money{}, err
+	}
+
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+// FromMajor converts an amount in whole currency units to Money.
+func FromMajor(major int64, currency string) (Money, error) {
+	units, err := MinorUnits(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	scale := pow10(units)
+	if major > math.MaxInt64/scale || major < math.MinInt64/scale {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return Money{Minor: major * scale, Currency: currency}, nil
+}
+
+// ParseMoney parses a decimal amount like "12.34" without going through
+// floating point. More fractional digits than the currency has is an error.
+func ParseMoney(amount, currency string) (Money, error) {
+	units, err := MinorUnits(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	s := strings.TrimSpace(amount)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" || len(frac) > units || strings.ContainsAny(whole+frac, "+-") {
+		return Money{}, fmt.Errorf("invalid %s amount %q", currency, amount)
+	}
+
+	frac += strings.Repeat("0", units-len(frac))
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid %s amount %q: %w", currency, amount, err)
+	}
+
+	if neg {
+		minor = -minor
+	}
+
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+func (m Money) check(other Money) error {
+	if m.Currency != other.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+
+	return nil
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.check(other); err != nil {
+		return Money{}, err
+	}
+
+	sum := m.Minor + other.Minor
+	if (other.Minor > 0 && sum < m.Minor) || (other.Minor < 0 && sum > m.Minor) {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return Money{Minor: sum, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Minor == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return m.Add(Money{Minor: -other.Minor, Currency: other.Currency})
+}
+
+func (m Money) Mul(n int64) (Money, error) {
+	// MinInt64 * -1 wraps around to MinInt64, which the division check
+	// below does not notice
+	if (m.Minor == math.MinInt64 && n == -1) || (n == math.MinInt64 && m.Minor == -1) {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	if n != 0 && (m.Minor*n)/n != m.Minor {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return Money{Minor: m.Minor * n, Currency: m.Currency}, nil
+}
+
+// Div divides the amount rounding half away from zero.
+func (m Money) Div(n int64) Money {
+	q, r := m.Minor/n, m.Minor%n
+	if r < 0 {
+		r = -r
+	}
+
+	if 2*r >= abs(n) {
+		if (m.Minor < 0) != (n < 0) {
+			q--
+		} else {
+			q++
+		}
+	}
+
+	return Money{Minor: q, Currency: m.Currency}
+}
+
+// Cmp returns -1, 0 or 1 when m is less than, equal to or greater than other.
+func (m Money) Cmp(other Money) (int, error) {
+	if err := m.check(other); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case m.Minor < other.Minor:
+		return -1, nil
+	case m.Minor > other.Minor:
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+// Decimal formats the amount with the currency's number of minor digits.
+func (m Money) Decimal() string {
+	units, ok := minorUnits[m.Currency]
+	if !ok || units == 0 {
+		return strconv.FormatInt(m.Minor, 10)
+	}
+
+	sign := ""
+	minor := m.Minor
+	if minor < 0 {
+		sign = "-"
+	}
+
+	digits := strconv.FormatUint(uint64(abs(minor)), 10)
+	if len(digits) <= units {
+		digits = strings.Repeat("0", units-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-units] + "." + digits[len(digits)-units:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+type moneyJSON struct {
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as a decimal string to keep it exact.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v moneyJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	parsed, err := ParseMoney(strings.Trim(string(v.Amount), `"`), v.Currency)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+func pow10(n int) int64 {
+	ret := int64(1)
+	for i := 0; i < n; i++ {
+		ret *= 10
+	}
+
+	return ret
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+// Money returns one of the payment amounts as Money. Payment amounts
+// are sent by upstream in whole units of Payment.Currency.
+func (p Payment) Money(amount int) (Money, error) {
+	return FromMajor(int64(amount), p.Currency)
+}
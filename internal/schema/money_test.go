@@ -0,0 +1,63 @@
+package schema
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	m, err := ParseMoney("12.3", "USD")
+	require.NoError(t, err)
+	require.Equal(t, Money{Minor: 1230, Currency: "USD"}, m)
+	require.Equal(t, "12.30", m.Decimal())
+
+	m, err = ParseMoney("-0.005", "KWD")
+	require.NoError(t, err)
+	require.Equal(t, "-0.005 KWD", m.String())
+
+	m, err = ParseMoney("500", "JPY")
+	require.NoError(t, err)
+	require.Equal(t, int64(500), m.Minor)
+
+	_, err = ParseMoney("1.234", "USD")
+	require.Error(t, err)
+	_, err = ParseMoney("1", "XXX")
+	require.ErrorIs(t, err, ErrUnknownCurrency)
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	usd := Money{Minor: 1000, Currency: "USD"}
+
+	_, err := usd.Add(Money{Minor: 1, Currency: "EUR"})
+	require.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	sum, err := usd.Add(Money{Minor: 5, Currency: "USD"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1005), sum.Minor)
+
+	_, err = Money{Minor: 1 << 62, Currency: "USD"}.Mul(4)
+	require.ErrorIs(t, err, ErrMoneyOverflow)
+
+	_, err = Money{Minor: math.MinInt64, Currency: "USD"}.Mul(-1)
+	require.ErrorIs(t, err, ErrMoneyOverflow)
+
+	_, err = Money{Minor: -1, Currency: "USD"}.Mul(math.MinInt64)
+	require.ErrorIs(t, err, ErrMoneyOverflow)
+
+	require.Equal(t, int64(333), usd.Div(3).Minor)
+	require.Equal(t, int64(3), Money{Minor: 5, Currency: "USD"}.Div(2).Minor)
+	require.Equal(t, int64(-3), Money{Minor: -5, Currency: "USD"}.Div(2).Minor)
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(Money{Minor: 1234, Currency: "EUR"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"amount":"12.34","currency":"EUR"}`, string(data))
+
+	var m Money
+	require.NoError(t, json.Unmarshal(data, &m))
+	require.Equal(t, Money{Minor: 1234, Currency: "EUR"}, m)
+}
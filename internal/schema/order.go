@@ -1,24 +1,29 @@
 package schema
 
+import (
+	"bytes"
+	"encoding/json"
+)
+
 type OrderUID string
 
 type SeqNumber uint64
 
 type Order struct {
-	OrderUID        OrderUID `json:"order_uid"`
+	OrderUID        OrderUID `json:"order_uid" jsonschema:"required,minLength=1"`
 	TrackNumber     string   `json:"track_number"`
 	Entry           string   `json:"entry"`
 	Delivery        Delivery
-	Payment         Payment
-	Items           Items
-	Locale          string `json:"locale"`
-	InternalSign    string `json:"internal_signature"`
-	CustomerID      string `json:"customer_id"`
-	DeliveryService string `json:"delivery_service"`
-	Shardkey        int    `json:"shardkey"`
-	SmID            int    `json:"sm_id"`
-	DateCreated     string `json:"date_created"`
-	OofShard        int    `json:"oof_shard"`
+	Payment         Payment   `jsonschema:"required"`
+	Items           Items     `jsonschema:"required"`
+	Locale          string    `json:"locale"`
+	InternalSign    string    `json:"internal_signature"`
+	CustomerID      string    `json:"customer_id"`
+	DeliveryService string    `json:"delivery_service"`
+	Shardkey        int       `json:"shardkey"`
+	SmID            int       `json:"sm_id"`
+	DateCreated     Timestamp `json:"date_created" jsonschema:"required"`
+	OofShard        int       `json:"oof_shard"`
 }
 
 type Delivery struct {
@@ -32,19 +37,38 @@ type Delivery struct {
 }
 
 type Payment struct {
-	Transaction   string `json:"transaction"`
-	RequestID     string `json:"request_id"`
-	Currency      string `json:"currency"`
-	Provider      string `json:"provider"`
-	Amount        int    `json:"amount"`
-	PaymentDT     int    `json:"payment_dt"`
-	Bank          string `json:"bank"`
-	DeliveryConst int    `json:"delivery_cost"`
-	GoodsTotal    int    `json:"goods_total"`
-	CustomFee     int    `json:"custom_fee"`
+	Transaction   string    `json:"transaction"`
+	RequestID     string    `json:"request_id"`
+	Currency      string    `json:"currency" jsonschema:"required,minLength=1"`
+	Provider      string    `json:"provider"`
+	Amount        int       `json:"amount"`
+	PaymentDT     Timestamp `json:"payment_dt"`
+	Bank          string    `json:"bank"`
+	DeliveryConst int       `json:"delivery_cost"`
+	GoodsTotal    int       `json:"goods_total"`
+	CustomFee     int       `json:"custom_fee"`
+}
+
+// Items is the list of order positions. Older producers sent a single
+// object instead of an array, so both forms are accepted on decode.
+type Items []Item
+
+func (i *Items) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var item Item
+		if err := json.Unmarshal(data, &item); err != nil {
+			return err
+		}
+
+		*i = Items{item}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]Item)(i))
 }
 
-type Items struct {
+type Item struct {
 	ChrtID      int    `json:"chrt_id"`
 	TrackNumber string `json:"track_number"`
 	Price       int    `json:"price"`
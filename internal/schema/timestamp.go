@@ -0,0 +1,108 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Значения больше порога считаются миллисекундами: в секундах это
+// 5138 год, в миллисекундах - март 1973
+const unixMillisThreshold = 1e11
+
+// Timestamp is a point in time decoded from RFC3339 strings, Unix
+// seconds or Unix milliseconds, either as JSON numbers or numeric strings.
+// It is always encoded as RFC3339 in UTC, the zero value as null.
+type Timestamp struct {
+	time.Time
+}
+
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t}
+}
+
+// ParseTimestamp parses any of the accepted formats. Unix time is
+// returned in UTC, RFC3339 keeps the offset it was sent with.
+func ParseTimestamp(s string) (Timestamp, error) {
+	if s == "" {
+		return Timestamp{}, nil
+	}
+
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return unixTimestamp(n), nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return Timestamp{}, fmt.Errorf("invalid timestamp %q: expected RFC3339 or unix time", s)
+	}
+
+	return Timestamp{Time: t}, nil
+}
+
+func unixTimestamp(n int64) Timestamp {
+	if n >= unixMillisThreshold || n <= -unixMillisThreshold {
+		return Timestamp{Time: time.UnixMilli(n).UTC()}
+	}
+
+	return Timestamp{Time: time.Unix(n, 0).UTC()}
+}
+
+// String formats the timestamp as RFC3339 in UTC, the zero value as "".
+func (t Timestamp) String() string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// Ptr returns nil for the zero value, so it is stored as SQL NULL.
+func (t Timestamp) Ptr() *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t.Time
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(t.String())
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*t = Timestamp{}
+		return nil
+	}
+
+	if len(data) > 0 && data[0] != '"' {
+		n, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid unix timestamp %s", data)
+		}
+
+		*t = unixTimestamp(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	parsed, err := ParseTimestamp(s)
+	if err != nil {
+		return err
+	}
+
+	*t = parsed
+	return nil
+}
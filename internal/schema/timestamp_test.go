@@ -0,0 +1,39 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestampJSON(t *testing.T) {
+	want := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+
+	for _, input := range []string{
+		`"2021-11-26T06:22:19Z"`,
+		`"2021-11-26T09:22:19+03:00"`,
+		`1637907739`,
+		`"1637907739"`,
+		`1637907739000`,
+	} {
+		var ts Timestamp
+		require.NoError(t, json.Unmarshal([]byte(input), &ts), input)
+		require.True(t, want.Equal(ts.Time), input)
+
+		data, err := json.Marshal(ts)
+		require.NoError(t, err)
+		require.Equal(t, `"2021-11-26T06:22:19Z"`, string(data))
+	}
+
+	var ts Timestamp
+	require.NoError(t, json.Unmarshal([]byte(`null`), &ts))
+	require.True(t, ts.IsZero())
+
+	data, err := json.Marshal(ts)
+	require.NoError(t, err)
+	require.Equal(t, `null`, string(data))
+
+	require.Error(t, json.Unmarshal([]byte(`"26.11.2021"`), &ts))
+}
@@ -0,0 +1,46 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidOrder = errors.New("invalid order")
+
+// Validate checks that required fields are set and that payment totals
+// match the items.
+func (o Order) Validate() error {
+	if o.OrderUID == "" {
+		return fmt.Errorf("%w: empty order_uid", ErrInvalidOrder)
+	}
+
+	if o.Payment.Currency == "" {
+		return fmt.Errorf("%w: empty payment currency", ErrInvalidOrder)
+	}
+
+	if o.DateCreated.IsZero() {
+		return fmt.Errorf("%w: empty date_created", ErrInvalidOrder)
+	}
+
+	if len(o.Items) == 0 {
+		return fmt.Errorf("%w: no items", ErrInvalidOrder)
+	}
+
+	goodsTotal := 0
+	for _, item := range o.Items {
+		goodsTotal += item.TotalPrice
+	}
+
+	if goodsTotal != o.Payment.GoodsTotal {
+		return fmt.Errorf("%w: goods_total %d does not match items total %d",
+			ErrInvalidOrder, o.Payment.GoodsTotal, goodsTotal)
+	}
+
+	amount := o.Payment.GoodsTotal + o.Payment.DeliveryConst + o.Payment.CustomFee
+	if amount != o.Payment.Amount {
+		return fmt.Errorf("%w: amount %d does not match goods, delivery and fee total %d",
+			ErrInvalidOrder, o.Payment.Amount, amount)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		DateCreated: NewTimestamp(time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)),
+		Payment: Payment{
+			Currency:      "USD",
+			Amount:        1817,
+			DeliveryConst: 1500,
+			GoodsTotal:    317,
+		},
+		Items: Items{{Price: 453, Sale: 30, TotalPrice: 317}},
+	}
+
+	cases := []struct {
+		name    string
+		modify  func(*Order)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(*Order) {}},
+		{name: "no_uid", modify: func(o *Order) { o.OrderUID = "" }, wantErr: true},
+		{name: "no_date", modify: func(o *Order) { o.DateCreated = Timestamp{} }, wantErr: true},
+		{name: "no_items", modify: func(o *Order) { o.Items = nil }, wantErr: true},
+		{name: "goods_total", modify: func(o *Order) { o.Payment.GoodsTotal = 300 }, wantErr: true},
+		{name: "amount", modify: func(o *Order) { o.Payment.CustomFee = 1 }, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			order := valid
+			order.Items = append(Items(nil), valid.Items...)
+			c.modify(&order)
+
+			err := order.Validate()
+			if c.wantErr {
+				require.ErrorIs(t, err, ErrInvalidOrder)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestItemsLegacyObject(t *testing.T) {
+	var order Order
+	err := json.Unmarshal([]byte(`{"order_uid":"1","items":{"name":"Mascaras"}}`), &order)
+	require.NoError(t, err)
+	require.Equal(t, Items{{Name: "Mascaras"}}, order.Items)
+}
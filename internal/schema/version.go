@@ -0,0 +1,225 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CurrentVersion is the version of the Order JSON format produced by
+// this code. Version 1 is the format used before envelopes were added:
+// a bare order with Items possibly sent as a single object and
+// date_created and payment_dt as arbitrary strings or Unix seconds.
+const CurrentVersion = 2
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported schema version")
+	ErrUnknownField       = errors.New("unknown field")
+)
+
+// Envelope is the versioned form of an order in messages.
+type Envelope struct {
+	Version int             `json:"schema_version"`
+	Order   json.RawMessage `json:"order"`
+}
+
+// FieldPolicy decides what happens to fields the current Order does not have.
+type FieldPolicy int
+
+const (
+	// Lenient drops unknown fields
+	Lenient FieldPolicy = iota
+	// Strict rejects payloads with unknown fields
+	Strict
+)
+
+func ParseFieldPolicy(s string) (FieldPolicy, error) {
+	switch s {
+	case "", "lenient":
+		return Lenient, nil
+	case "strict":
+		return Strict, nil
+	}
+
+	return Lenient, fmt.Errorf("unknown field policy %q", s)
+}
+
+// Upcaster migrates a decoded order document from one version to the next.
+type Upcaster func(doc map[string]any) (map[string]any, error)
+
+// Registry decodes orders of any known version into the current Order
+// by applying upcasters one version at a time.
+type Registry struct {
+	policy    FieldPolicy
+	upcasters map[int]Upcaster
+}
+
+// NewRegistry returns a registry with the built-in upcasters registered.
+func NewRegistry(policy FieldPolicy) *Registry {
+	r := &Registry{
+		policy:    policy,
+		upcasters: make(map[int]Upcaster),
+	}
+	r.Register(1, upcastV1)
+
+	return r
+}
+
+// Register sets the upcaster from version from to from+1.
+func (r *Registry) Register(from int, fn Upcaster) {
+	r.upcasters[from] = fn
+}
+
+// Encode wraps the order into an envelope of the current version.
+func (r *Registry) Encode(order Order) ([]byte, error) {
+	data, err := json.Marshal(&order)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(Envelope{Version: CurrentVersion, Order: data})
+}
+
+// Decode accepts an envelope or a bare version 1 order and returns the
+// order with the version it was sent in.
+func (r *Registry) Decode(data []byte) (Order, int, error) {
+	env, ok, err := parseEnvelope(data)
+	if err != nil {
+		return Order{}, 0, err
+	}
+
+	if !ok {
+		env = Envelope{Version: 1, Order: data}
+	}
+
+	order, err := r.DecodeVersion(env.Version, env.Order)
+	return order, env.Version, err
+}
+
+// DecodeVersion decodes an order document stored in the given version.
+func (r *Registry) DecodeVersion(version int, data []byte) (Order, error) {
+	if version < 1 || version > CurrentVersion {
+		return Order{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	if version < CurrentVersion {
+		var err error
+		if data, err = r.upcast(version, data); err != nil {
+			return Order{}, err
+		}
+	}
+
+	var order Order
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&order); err != nil {
+		return Order{}, err
+	}
+
+	// encoding/json has no error type for unknown fields. A document that
+	// decodes leniently but not strictly can only fail on them.
+	if r.policy == Strict {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&Order{}); err != nil {
+			return Order{}, fmt.Errorf("%w: %v", ErrUnknownField, err)
+		}
+	}
+
+	return order, nil
+}
+
+func (r *Registry) upcast(version int, data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	for v := version; v < CurrentVersion; v++ {
+		fn, ok := r.upcasters[v]
+		if !ok {
+			return nil, fmt.Errorf("%w: no upcaster from version %d", ErrUnsupportedVersion, v)
+		}
+
+		var err error
+		if doc, err = fn(doc); err != nil {
+			return nil, fmt.Errorf("upcast from version %d: %w", v, err)
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
+// parseEnvelope reports whether data is an envelope rather than a bare order.
+func parseEnvelope(data []byte) (Envelope, bool, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return Envelope{}, false, err
+	}
+
+	if _, ok := probe["schema_version"]; !ok {
+		return Envelope{}, false, nil
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return Envelope{}, false, err
+	}
+
+	return env, true, nil
+}
+
+// upcastV1 приводит Items к массиву, а даты к RFC3339
+func upcastV1(doc map[string]any) (map[string]any, error) {
+	for key, value := range doc {
+		if !strings.EqualFold(key, "items") {
+			continue
+		}
+
+		if item, ok := value.(map[string]any); ok {
+			doc[key] = []any{item}
+		}
+	}
+
+	if err := upcastTime(doc, "date_created"); err != nil {
+		return nil, err
+	}
+
+	for key, value := range doc {
+		if payment, ok := value.(map[string]any); ok && strings.EqualFold(key, "payment") {
+			if err := upcastTime(payment, "payment_dt"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+func upcastTime(doc map[string]any, key string) error {
+	value, ok := doc[key]
+	if !ok || value == nil {
+		return nil
+	}
+
+	var s string
+	switch v := value.(type) {
+	case json.Number:
+		s = v.String()
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("%s: unexpected type %T", key, value)
+	}
+
+	ts, err := ParseTimestamp(s)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+
+	doc[key] = ts
+	return nil
+}
@@ -0,0 +1,48 @@
+package schema
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryDecode(t *testing.T) {
+	registry := NewRegistry(Lenient)
+
+	legacy := []byte(`{"order_uid":"1","date_created":"2021-11-26T06:22:19Z",
+		"payment":{"currency":"USD","payment_dt":1637907739},
+		"items":{"name":"Mascaras"},"legacy_field":true}`)
+	order, version, err := registry.Decode(legacy)
+	require.NoError(t, err)
+	require.Equal(t, 1, version)
+	require.Equal(t, OrderUID("1"), order.OrderUID)
+	require.Equal(t, Items{{Name: "Mascaras"}}, order.Items)
+	require.True(t, order.Payment.PaymentDT.Equal(time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)))
+
+	data, err := registry.Encode(order)
+	require.NoError(t, err)
+
+	decoded, version, err := registry.Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, CurrentVersion, version)
+	require.Equal(t, order, decoded)
+
+	_, _, err = registry.Decode([]byte(`{"schema_version":99,"order":{}}`))
+	require.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+func TestRegistryStrict(t *testing.T) {
+	data := []byte(`{"schema_version":2,"order":{"order_uid":"1","unknown":1}}`)
+
+	_, _, err := NewRegistry(Lenient).Decode(data)
+	require.NoError(t, err)
+
+	_, _, err = NewRegistry(Strict).Decode(data)
+	require.ErrorIs(t, err, ErrUnknownField)
+
+	// Ошибка типа не считается неизвестным полем
+	_, _, err = NewRegistry(Strict).Decode([]byte(`{"schema_version":2,"order":{"order_uid":1}}`))
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrUnknownField)
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/schema"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) registerAdmin(router gin.IRouter) {
+	admin := router.Group("admin")
+	if s.deps.Consumer != nil {
+		admin.GET("seq", s.getSeqHandler)
+		admin.PUT("seq", s.setSeqHandler)
+		admin.GET("replay", s.replayReportHandler)
+		admin.POST("replay", s.replayHandler)
+	}
+
+	if _, ok := s.deps.DB.(orderdb.RestorableOrderDB); ok {
+		admin.POST("cache/refresh", s.refreshCacheHandler)
+	}
+
+	if _, ok := s.deps.DB.(orderdb.VersionReporter); ok {
+		admin.GET("schema-versions", s.schemaVersionsHandler)
+	}
+
+	if _, ok := s.deps.DB.(orderdb.SeqTracker); ok {
+		admin.GET("seq/progress", s.seqProgressHandler)
+	}
+}
+
+// seqProgressHandler показывает обработанные номера сообщений и пропуски
+// между ними. Сообщения из пропусков будут доставлены повторно после
+// перезапуска или переподписки.
+func (s *Server) seqProgressHandler(c *gin.Context) {
+	progress, err := s.deps.DB.(orderdb.SeqTracker).SeqProgress(c)
+	if s.replyError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, &progress)
+}
+
+// schemaVersionsHandler показывает, сколько сохраненных заказов в каждой
+// версии схемы, например перед удалением старого upcaster.
+func (s *Server) schemaVersionsHandler(c *gin.Context) {
+	versions, err := s.deps.DB.(orderdb.VersionReporter).SchemaVersions(c)
+	if s.replyError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, &SchemaVersionsResponse{
+		Current:  schema.CurrentVersion,
+		Versions: versions,
+	})
+}
+
+// refreshCacheHandler перечитывает заказы из persistent хранилища,
+// например после импорта в обход очереди.
+func (s *Server) refreshCacheHandler(c *gin.Context) {
+	err := s.deps.DB.(orderdb.RestorableOrderDB).Restore(c)
+	if s.replyError(c, err) {
+		return
+	}
+
+	s.log.Info("cache refreshed")
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Server) getSeqHandler(c *gin.Context) {
+	seq, err := s.deps.DB.SeqNumber(c)
+	if s.replyError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, &SeqResponse{Seq: seq})
+}
+
+func (s *Server) setSeqHandler(c *gin.Context) {
+	var req SeqRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.replyError(c, fmt.Errorf("%w: %v", ErrBadRequest, err))
+		return
+	}
+
+	err := s.deps.Consumer.Seek(c, req.Seq)
+	if s.replyError(c, err) {
+		return
+	}
+
+	s.log.Warnf("consumer moved to seq %d", req.Seq)
+	c.JSON(http.StatusOK, &SeqResponse{Seq: req.Seq})
+}
+
+func (s *Server) replayHandler(c *gin.Context) {
+	var req orderevent.ReplayOptions
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.replyError(c, fmt.Errorf("%w: %v", ErrBadRequest, err))
+		return
+	}
+
+	if req.FromSeq == 0 && req.FromTime.IsZero() {
+		s.replyError(c, fmt.Errorf("%w: from_seq or from_time is required", ErrBadRequest))
+		return
+	}
+
+	err := s.deps.Consumer.Replay(c, req)
+	if s.replyError(c, err) {
+		return
+	}
+
+	s.log.Warnf("replay started: %+v", req)
+	c.JSON(http.StatusAccepted, s.deps.Consumer.ShadowReport())
+}
+
+func (s *Server) replayReportHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.deps.Consumer.ShadowReport())
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"orderservice/internal/analytics"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ParamGroupBy   = "group_by"
+	ParamFrom      = "from"
+	ParamTo        = "to"
+	ParamTopBrands = "top_brands"
+)
+
+func (s *Server) analyticsHandler(c *gin.Context) {
+	if s.deps.Analytics == nil {
+		s.replyError(c, fmt.Errorf("%w: analytics is not configured", ErrBadRequest))
+		return
+	}
+
+	q, err := parseAnalyticsQuery(c.Request.URL.Query())
+	if s.replyError(c, err) {
+		return
+	}
+
+	report, err := s.deps.Analytics.Report(c, q)
+	if s.replyError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, &report)
+}
+
+func parseAnalyticsQuery(query url.Values) (analytics.Query, error) {
+	groupBy, err := analytics.ParseGroupBy(query.Get(ParamGroupBy))
+	if err != nil {
+		return analytics.Query{}, err
+	}
+
+	q := analytics.Query{GroupBy: groupBy}
+	if q.From, err = parseTime(query, ParamFrom); err != nil {
+		return analytics.Query{}, err
+	}
+
+	if q.To, err = parseTime(query, ParamTo); err != nil {
+		return analytics.Query{}, err
+	}
+
+	if q.TopBrands, err = parseUint(query, ParamTopBrands); err != nil {
+		return analytics.Query{}, err
+	}
+
+	q.Currency = query.Get(ParamCurrency)
+
+	return q, nil
+}
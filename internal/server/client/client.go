@@ -0,0 +1,203 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/orderio"
+	"orderservice/internal/schema"
+	"orderservice/internal/server"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+)
+
+type Config struct {
+	// URL адрес сервиса, например http://localhost:8080
+	URL     string
+	Timeout time.Duration
+	// Token передается маршрутам администрирования, см.
+	// server.Config.AdminToken
+	Token string
+}
+
+// Client обращается к HTTP API server.Server.
+type Client struct {
+	cfg  Config
+	http *http.Client
+	// stream используется для выгрузок, длительность которых
+	// ограничивается только контекстом
+	stream *http.Client
+}
+
+func New(cfg Config) *Client {
+	timeout := defaultTimeout
+	if cfg.Timeout != 0 {
+		timeout = cfg.Timeout
+	}
+
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+	return &Client{
+		cfg:    cfg,
+		http:   &http.Client{Timeout: timeout},
+		stream: &http.Client{},
+	}
+}
+
+func (c *Client) GetOrder(ctx context.Context, orderUID schema.OrderUID) (schema.Order, error) {
+	var order schema.Order
+	err := c.do(ctx, http.MethodGet, "/orders/"+url.PathEscape(string(orderUID)), nil, &order)
+	return order, err
+}
+
+func (c *Client) ListOrders(ctx context.Context, filter orderdb.Filter) ([]schema.Order, error) {
+	var orders []schema.Order
+	err := c.do(ctx, http.MethodGet, "/orders/", server.FilterQuery(filter), &orders)
+	return orders, err
+}
+
+// Export записывает в w выгрузку заказов в формате format. Если сервер
+// прервал выгрузку, возвращается ошибка, а в w остается ее начало.
+func (c *Client) Export(ctx context.Context, format orderio.Format, filter orderdb.Filter, w io.Writer) error {
+	query := server.FilterQuery(filter)
+	query.Set(server.ParamFormat, string(format))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		c.cfg.URL+"/orders/export?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return responseError(resp)
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return err
+	}
+
+	// Трейлер доступен только после чтения всего тела
+	if msg := resp.Trailer.Get(server.HeaderExportError); msg != "" {
+		return fmt.Errorf("export is incomplete: %s", msg)
+	}
+	return nil
+}
+
+func (c *Client) SeqNumber(ctx context.Context) (schema.SeqNumber, error) {
+	var resp server.SeqResponse
+	err := c.do(ctx, http.MethodGet, "/admin/seq", nil, &resp)
+	return resp.Seq, err
+}
+
+// SeqProgress возвращает обработанные номера сообщений и пропуски.
+func (c *Client) SeqProgress(ctx context.Context) (orderdb.SeqProgress, error) {
+	var progress orderdb.SeqProgress
+	err := c.do(ctx, http.MethodGet, "/admin/seq/progress", nil, &progress)
+	return progress, err
+}
+
+// Seek перемещает точку продолжения обработки работающего сервиса.
+func (c *Client) Seek(ctx context.Context, seq schema.SeqNumber) error {
+	return c.doJSON(ctx, http.MethodPut, "/admin/seq", &server.SeqRequest{Seq: seq}, nil)
+}
+
+func (c *Client) Replay(ctx context.Context, opts orderevent.ReplayOptions) error {
+	return c.doJSON(ctx, http.MethodPost, "/admin/replay", &opts, nil)
+}
+
+func (c *Client) ShadowReport(ctx context.Context) (orderevent.ReplayReport, error) {
+	var report orderevent.ReplayReport
+	err := c.do(ctx, http.MethodGet, "/admin/replay", nil, &report)
+	return report, err
+}
+
+// RefreshCache перечитывает кеш сервиса из persistent хранилища.
+func (c *Client) RefreshCache(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/admin/cache/refresh", nil, nil)
+}
+
+func (c *Client) SchemaVersions(ctx context.Context) (server.SchemaVersionsResponse, error) {
+	var resp server.SchemaVersionsResponse
+	err := c.do(ctx, http.MethodGet, "/admin/schema-versions", nil, &resp)
+	return resp, err
+}
+
+func (c *Client) doJSON(ctx context.Context, method, path string, in, out any) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.cfg.URL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.send(req, out)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, out any) error {
+	u := c.cfg.URL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.send(req, out)
+}
+
+func (c *Client) send(req *http.Request, out any) error {
+	if c.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return responseError(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func responseError(resp *http.Response) error {
+	var errResp server.ErrorResponse
+	data, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(data, &errResp); err != nil || errResp.Message == "" {
+		errResp.Message = strings.TrimSpace(string(data))
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", orderdb.ErrNotFound, errResp.Message)
+	}
+
+	return fmt.Errorf("%s: %s", resp.Status, errResp.Message)
+}
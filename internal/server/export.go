@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"orderservice/internal/orderio"
+	"orderservice/internal/schema"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ParamFormat = "format"
+
+// HeaderExportError - трейлер ответа выгрузки. Заголовки и часть заказов
+// к моменту ошибки уже отправлены, поэтому неполная выгрузка отмечается
+// текстом ошибки в трейлере.
+const HeaderExportError = "X-Export-Error"
+
+// exportHandler выгружает заказы из persistent хранилища, а не из кеша,
+// записывая их в ответ по мере чтения.
+func (s *Server) exportHandler(c *gin.Context) {
+	if s.deps.Exporter == nil {
+		s.replyError(c, fmt.Errorf("%w: export is not configured", ErrBadRequest))
+		return
+	}
+
+	format, err := orderio.ParseFormat(c.Query(ParamFormat))
+	if err != nil {
+		s.replyError(c, fmt.Errorf("%w: %v", ErrBadRequest, err))
+		return
+	}
+
+	filter, err := ParseFilter(c.Request.URL.Query())
+	if s.replyError(c, err) {
+		return
+	}
+
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="orders.%s"`, format))
+	c.Header("Trailer", HeaderExportError)
+	c.Status(http.StatusOK)
+
+	w, err := orderio.NewWriter(c.Writer, format)
+	if err != nil {
+		s.log.Errorf("failed to create export writer: %v", err)
+		c.Writer.Header().Set(HeaderExportError, err.Error())
+		return
+	}
+
+	count := 0
+	err = s.deps.Exporter.StreamOrders(c, filter, func(order schema.Order) error {
+		count++
+		return w.Write(order)
+	})
+	if err == nil {
+		err = w.Close()
+	}
+
+	if err != nil {
+		s.log.Errorf("export failed after %d orders: %v", count, err)
+		// Пока ничего не отправлено, можно ответить обычной ошибкой
+		if !c.Writer.Written() {
+			c.Header("Content-Disposition", "")
+			c.Header("Trailer", "")
+			s.replyError(c, err)
+			return
+		}
+
+		c.Writer.Header().Set(HeaderExportError, err.Error())
+		return
+	}
+
+	s.log.Infof("exported %d orders as %s", count, format)
+}
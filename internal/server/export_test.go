@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderdb/orderdbtest"
+	"orderservice/internal/schema"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+type failingExporter struct {
+	orders []schema.Order
+	err    error
+}
+
+func (e failingExporter) StreamOrders(_ context.Context, _ orderdb.Filter, fn func(schema.Order) error) error {
+	for _, order := range e.orders {
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	return e.err
+}
+
+// Ошибка после начала ответа передается в трейлере, до него - статусом
+func TestExportTrailer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Больше, чем помещается в буфер записи
+	orders := make([]schema.Order, 100)
+	for i := range orders {
+		orders[i] = orderdbtest.Order(schema.OrderUID(fmt.Sprint(i)))
+	}
+
+	cases := []struct {
+		name     string
+		orders   []schema.Order
+		err      error
+		wantCode int
+		wantErr  string
+	}{
+		{name: "complete", orders: orders, wantCode: http.StatusOK},
+		{name: "failed", orders: orders, err: errors.New("connection reset"),
+			wantCode: http.StatusOK, wantErr: "connection reset"},
+		{name: "failed_before_output", orders: orders[:1], err: errors.New("connection reset"),
+			wantCode: http.StatusInternalServerError},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			srv := httptest.NewServer(NewServer(Config{}, Dependencies{
+				Log:      logrus.New(),
+				DB:       orderdb.NewMockOrderDB(nil),
+				Exporter: failingExporter{orders: c.orders, err: c.err},
+			}).Handler())
+			t.Cleanup(srv.Close)
+
+			resp, err := http.Get(srv.URL + "/orders/export")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, c.wantCode, resp.StatusCode)
+			_, err = io.Copy(io.Discard, resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, c.wantErr, resp.Trailer.Get(HeaderExportError))
+		})
+	}
+}
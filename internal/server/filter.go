@@ -0,0 +1,119 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/schema"
+	"strconv"
+	"time"
+)
+
+// Параметры запроса фильтрации списка заказов
+const (
+	ParamCustomerID      = "customer_id"
+	ParamTrackNumber     = "track_number"
+	ParamDeliveryService = "delivery_service"
+	ParamCurrency        = "currency" // и валюта отчета аналитики
+	ParamLocale          = "locale"
+	ParamCreatedFrom     = "created_from"
+	ParamCreatedTo       = "created_to"
+	ParamLimit           = "limit"
+	ParamOffset          = "offset"
+)
+
+func ParseFilter(query url.Values) (orderdb.Filter, error) {
+	filter := orderdb.Filter{
+		CustomerID:      query.Get(ParamCustomerID),
+		TrackNumber:     query.Get(ParamTrackNumber),
+		DeliveryService: query.Get(ParamDeliveryService),
+		Currency:        query.Get(ParamCurrency),
+		Locale:          query.Get(ParamLocale),
+	}
+
+	var err error
+	if filter.Limit, err = parseUint(query, ParamLimit); err != nil {
+		return orderdb.Filter{}, err
+	}
+
+	if filter.Offset, err = parseUint(query, ParamOffset); err != nil {
+		return orderdb.Filter{}, err
+	}
+
+	if filter.CreatedFrom, err = parseTime(query, ParamCreatedFrom); err != nil {
+		return orderdb.Filter{}, err
+	}
+
+	if filter.CreatedTo, err = parseTime(query, ParamCreatedTo); err != nil {
+		return orderdb.Filter{}, err
+	}
+
+	if !filter.CreatedTo.IsZero() && !filter.CreatedFrom.Before(filter.CreatedTo) {
+		return orderdb.Filter{}, fmt.Errorf("%w: %s must be before %s",
+			ErrBadRequest, ParamCreatedFrom, ParamCreatedTo)
+	}
+
+	return filter, nil
+}
+
+func FilterQuery(filter orderdb.Filter) url.Values {
+	query := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	set(ParamCustomerID, filter.CustomerID)
+	set(ParamTrackNumber, filter.TrackNumber)
+	set(ParamDeliveryService, filter.DeliveryService)
+	set(ParamCurrency, filter.Currency)
+	set(ParamLocale, filter.Locale)
+	if filter.Limit > 0 {
+		query.Set(ParamLimit, strconv.Itoa(filter.Limit))
+	}
+	if filter.Offset > 0 {
+		query.Set(ParamOffset, strconv.Itoa(filter.Offset))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		query.Set(ParamCreatedFrom, schema.NewTimestamp(filter.CreatedFrom).String())
+	}
+	if !filter.CreatedTo.IsZero() {
+		query.Set(ParamCreatedTo, schema.NewTimestamp(filter.CreatedTo).String())
+	}
+
+	return query
+}
+
+func parseUint(query url.Values, key string) (int, error) {
+	value := query.Get(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	v, err := strconv.ParseUint(value, 10, 31)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: %v", ErrBadRequest, key, err)
+	}
+
+	return int(v), nil
+}
+
+// parseTime принимает дату YYYY-MM-DD (полночь UTC), RFC3339 или время Unix
+func parseTime(query url.Values, key string) (time.Time, error) {
+	value := query.Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+
+	ts, err := schema.ParseTimestamp(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s: %v", ErrBadRequest, key, err)
+	}
+
+	return ts.Time, nil
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"orderservice/internal/orderdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) registerLookups(router gin.IRouter) {
+	router.GET("orders/by-track/:value", s.lookupHandler(orderdb.KeyTrackNumber))
+	router.GET("orders/by-transaction/:value", s.lookupHandler(orderdb.KeyTransaction))
+	router.GET("orders/by-rid/:value", s.lookupHandler(orderdb.KeyRID))
+	router.GET("orders/by-customer/:value", s.lookupHandler(orderdb.KeyCustomerID))
+}
+
+func (s *Server) lookupHandler(key orderdb.Key) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		indexed, ok := s.deps.DB.(orderdb.IndexedOrderDB)
+		if !ok {
+			s.replyError(c, fmt.Errorf("%w: lookup by %s is not supported", ErrBadRequest, key))
+			return
+		}
+
+		res, err := indexed.FindOrders(c, key, c.Param("value"))
+		if s.replyError(c, err) {
+			return
+		}
+
+		c.JSON(http.StatusOK, &res)
+	}
+}
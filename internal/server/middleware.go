@@ -1,10 +1,14 @@
 package server
 
 import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 )
 
 func LoggerMiddleware(log *logrus.Entry) gin.HandlerFunc {
@@ -19,3 +23,30 @@ func LoggerMiddleware(log *logrus.Entry) gin.HandlerFunc {
 		)
 	}
 }
+
+func RateLimitMiddleware(limiter *rate.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limiter.Allow() {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, &ErrorResponse{
+				Message: http.StatusText(http.StatusTooManyRequests),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminAuthMiddleware пропускает только запросы с заголовком
+// Authorization: Bearer <token>.
+func AdminAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, &ErrorResponse{
+				Message: http.StatusText(http.StatusUnauthorized),
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := NewServer(Config{RateLimit: RateLimit{RPS: 0.001, Burst: 2}}, Dependencies{Log: logrus.New()})
+	router := s.router()
+
+	get := func() int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, get())
+	require.Equal(t, http.StatusOK, get())
+	require.Equal(t, http.StatusTooManyRequests, get())
+
+	// Снятие ограничения применяется без перезапуска
+	s.SetRateLimit(RateLimit{})
+	require.Equal(t, http.StatusOK, get())
+}
+
+// Маршруты администрирования не регистрируются без токена и требуют его,
+// если он задан
+func TestAdminAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	get := func(cfg Config, header string) int {
+		router := NewServer(cfg, Dependencies{Log: logrus.New(), DB: adminDB{}}).router()
+		req := httptest.NewRequest(http.MethodGet, "/admin/seq/progress", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusNotFound, get(Config{}, "Bearer secret"))
+
+	cfg := Config{AdminToken: "secret"}
+	require.Equal(t, http.StatusUnauthorized, get(cfg, ""))
+	require.Equal(t, http.StatusUnauthorized, get(cfg, "Bearer wrong"))
+	require.Equal(t, http.StatusUnauthorized, get(cfg, "secret"))
+	require.Equal(t, http.StatusOK, get(cfg, "Bearer secret"))
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"orderservice/internal/analytics"
+	"orderservice/internal/apispec"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/orderio"
+	"orderservice/internal/schema"
+	"orderservice/internal/webhook"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const apiVersion = "1.0"
+
+// OpenAPI возвращает описание всех маршрутов сервера. Опубликованная
+// копия лежит в api/openapi.json и сверяется с ним тестом.
+func OpenAPI() *apispec.Document {
+	b := apispec.NewBuilder("Order service", apiVersion)
+	// 429 возможен на любом маршруте при включенном ограничении запросов
+	failures := func(codes ...int) map[string]apispec.Response {
+		responses := make(map[string]apispec.Response)
+		for _, code := range append(codes, http.StatusTooManyRequests) {
+			responses[strconv.Itoa(code)] = apispec.Response{
+				Description: http.StatusText(code),
+				Content:     b.JSON(ErrorResponse{}),
+			}
+		}
+		return responses
+	}
+	ok := func(description string, v any, codes ...int) map[string]apispec.Response {
+		responses := failures(append(codes, http.StatusInternalServerError)...)
+		responses["200"] = apispec.Response{Description: description, Content: b.JSON(v)}
+		return responses
+	}
+	str := &apispec.Schema{Type: "string"}
+	num := &apispec.Schema{Type: "integer"}
+	html := map[string]apispec.Response{"200": {
+		Description: "HTML page",
+		Content:     map[string]apispec.MediaType{"text/html": {}},
+	}}
+
+	filter := []apispec.Parameter{
+		apispec.QueryParam(ParamCustomerID, "Exact customer_id", str),
+		apispec.QueryParam(ParamTrackNumber, "Exact track_number", str),
+		apispec.QueryParam(ParamDeliveryService, "Exact delivery_service", str),
+		apispec.QueryParam(ParamCurrency, "Payment currency", str),
+		apispec.QueryParam(ParamLocale, "Exact locale", str),
+		apispec.QueryParam(ParamCreatedFrom, "Created at or after, date, RFC3339 or Unix time", str),
+		apispec.QueryParam(ParamCreatedTo, "Created before, date, RFC3339 or Unix time", str),
+		apispec.QueryParam(ParamLimit, "Maximum number of orders", num),
+		apispec.QueryParam(ParamOffset, "Number of orders to skip", num),
+	}
+
+	b.Add(http.MethodGet, "/", apispec.Operation{Summary: "Order lookup page", Tags: []string{"ui"}, Responses: html})
+	b.Add(http.MethodGet, "docs", apispec.Operation{Summary: "API documentation", Tags: []string{"ui"}, Responses: html})
+	b.Add(http.MethodGet, "openapi.json", apispec.Operation{
+		Summary:   "This document",
+		Tags:      []string{"ui"},
+		Responses: map[string]apispec.Response{"200": {Description: "OpenAPI document", Content: map[string]apispec.MediaType{"application/json": {}}}},
+	})
+
+	b.Add(http.MethodGet, "orders/:id", apispec.Operation{
+		Summary:   "Get order by order_uid",
+		Tags:      []string{"orders"},
+		Responses: ok("Order", schema.Order{}, http.StatusNotFound),
+	})
+	b.Add(http.MethodGet, "orders/", apispec.Operation{
+		Summary:    "List orders",
+		Tags:       []string{"orders"},
+		Parameters: filter,
+		Responses:  ok("Orders ordered by order_uid when filtered", []schema.Order{}, http.StatusBadRequest),
+	})
+
+	exportResponses := failures(http.StatusBadRequest, http.StatusInternalServerError)
+	exportResponses["200"] = apispec.Response{
+		Description: "Orders streamed in the requested format. A failure after the response started " +
+			"is reported in the X-Export-Error trailer and the body is incomplete",
+		Content: map[string]apispec.MediaType{
+			orderio.FormatNDJSON.ContentType():  {Schema: b.Schema(schema.Order{})},
+			orderio.FormatCSV.ContentType():     {},
+			orderio.FormatParquet.ContentType(): {},
+		},
+	}
+	b.Add(http.MethodGet, "orders/export", apispec.Operation{
+		Summary: "Export orders from the persistent store",
+		Tags:    []string{"orders"},
+		Parameters: append([]apispec.Parameter{apispec.QueryParam(ParamFormat, "Export format", &apispec.Schema{
+			Type: "string",
+			Enum: []any{orderio.FormatNDJSON, orderio.FormatCSV, orderio.FormatParquet},
+		})}, filter...),
+		Responses: exportResponses,
+	})
+
+	search := apispec.QueryParam(ParamQuery, "Search text: name, phone, email, city, item or brand", str)
+	search.Required = true
+	b.Add(http.MethodGet, "orders/search", apispec.Operation{
+		Summary:    "Full-text order search",
+		Tags:       []string{"orders"},
+		Parameters: []apispec.Parameter{search, apispec.QueryParam(ParamLimit, "Maximum number of results", num)},
+		Responses:  ok("Orders by descending rank", []orderdb.SearchResult{}, http.StatusBadRequest),
+	})
+
+	for _, path := range []string{"by-track", "by-transaction", "by-rid", "by-customer"} {
+		b.Add(http.MethodGet, "orders/"+path+"/:value", apispec.Operation{
+			Summary:   "Find orders by secondary key",
+			Tags:      []string{"orders"},
+			Responses: ok("Matching orders", []schema.Order{}, http.StatusBadRequest, http.StatusNotFound),
+		})
+	}
+
+	b.Add(http.MethodGet, "analytics", apispec.Operation{
+		Summary: "Aggregated order analytics",
+		Tags:    []string{"analytics"},
+		Parameters: []apispec.Parameter{
+			apispec.QueryParam(ParamGroupBy, "Bucket key", &apispec.Schema{Type: "string", Enum: []any{
+				analytics.GroupByDay, analytics.GroupByCurrency, analytics.GroupByDeliveryService,
+				analytics.GroupByRegion, analytics.GroupByBank,
+			}}),
+			apispec.QueryParam(ParamFrom, "Range start, date or RFC3339", str),
+			apispec.QueryParam(ParamTo, "Range end (exclusive), date or RFC3339", str),
+			apispec.QueryParam(ParamTopBrands, "Number of top brands per bucket", num),
+			apispec.QueryParam(ParamCurrency, "Reporting currency", str),
+		},
+		Responses: ok("Report", analytics.Report{}, http.StatusBadRequest),
+	})
+
+	created := failures(http.StatusBadRequest, http.StatusInternalServerError)
+	created["201"] = apispec.Response{Description: "Subscription with its signing secret", Content: b.JSON(webhook.Subscription{})}
+	b.Add(http.MethodPost, "webhooks", apispec.Operation{
+		Summary:     "Subscribe to order events",
+		Tags:        []string{"webhooks"},
+		RequestBody: &apispec.RequestBody{Required: true, Content: b.JSON(WebhookRequest{})},
+		Responses:   created,
+	})
+	b.Add(http.MethodGet, "webhooks", apispec.Operation{
+		Summary:   "List subscriptions",
+		Tags:      []string{"webhooks"},
+		Responses: ok("Subscriptions without secrets", []webhook.Subscription{}),
+	})
+	b.Add(http.MethodGet, "webhooks/:id", apispec.Operation{
+		Summary:   "Get subscription",
+		Tags:      []string{"webhooks"},
+		Responses: ok("Subscription without secret", webhook.Subscription{}, http.StatusNotFound),
+	})
+
+	deleted := failures(http.StatusNotFound, http.StatusInternalServerError)
+	deleted["204"] = apispec.Response{Description: "Subscription and its deliveries deleted"}
+	b.Add(http.MethodDelete, "webhooks/:id", apispec.Operation{
+		Summary:   "Delete subscription",
+		Tags:      []string{"webhooks"},
+		Responses: deleted,
+	})
+	b.Add(http.MethodGet, "webhooks/:id/deliveries", apispec.Operation{
+		Summary:    "Delivery log, newest first",
+		Tags:       []string{"webhooks"},
+		Parameters: []apispec.Parameter{apispec.QueryParam(ParamLimit, "Maximum number of deliveries", num)},
+		Responses:  ok("Deliveries", []webhook.Delivery{}, http.StatusBadRequest, http.StatusNotFound),
+	})
+
+	b.Add(http.MethodGet, "admin/seq", apispec.Operation{
+		Summary:   "Last processed message sequence",
+		Tags:      []string{"admin"},
+		Responses: ok("Sequence", SeqResponse{}),
+	})
+	b.Add(http.MethodGet, "admin/seq/progress", apispec.Operation{
+		Summary:   "Processed message sequences and gaps",
+		Tags:      []string{"admin"},
+		Responses: ok("Progress", orderdb.SeqProgress{}),
+	})
+	b.Add(http.MethodPut, "admin/seq", apispec.Operation{
+		Summary:     "Move the consumer to a sequence",
+		Tags:        []string{"admin"},
+		RequestBody: &apispec.RequestBody{Required: true, Content: b.JSON(SeqRequest{})},
+		Responses:   ok("Sequence", SeqResponse{}, http.StatusBadRequest, http.StatusConflict),
+	})
+	b.Add(http.MethodGet, "admin/replay", apispec.Operation{
+		Summary:   "Shadow replay report",
+		Tags:      []string{"admin"},
+		Responses: ok("Report", orderevent.ReplayReport{}),
+	})
+
+	replay := failures(http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError)
+	replay["202"] = apispec.Response{Description: "Replay started", Content: b.JSON(orderevent.ReplayReport{})}
+	b.Add(http.MethodPost, "admin/replay", apispec.Operation{
+		Summary:     "Replay messages",
+		Tags:        []string{"admin"},
+		RequestBody: &apispec.RequestBody{Required: true, Content: b.JSON(orderevent.ReplayOptions{})},
+		Responses:   replay,
+	})
+
+	refresh := failures(http.StatusInternalServerError)
+	refresh["204"] = apispec.Response{Description: "Cache refreshed"}
+	b.Add(http.MethodPost, "admin/cache/refresh", apispec.Operation{
+		Summary:   "Reload the cache from the persistent store",
+		Tags:      []string{"admin"},
+		Responses: refresh,
+	})
+	b.Add(http.MethodGet, "admin/schema-versions", apispec.Operation{
+		Summary:   "Stored orders per schema version",
+		Tags:      []string{"admin"},
+		Responses: ok("Versions", SchemaVersionsResponse{}),
+	})
+
+	return b.Document()
+}
+
+var openAPIJSON = sync.OnceValues(func() ([]byte, error) {
+	return json.Marshal(OpenAPI())
+})
+
+func (s *Server) openAPIHandler(c *gin.Context) {
+	data, err := openAPIJSON()
+	if s.replyError(c, err) {
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", data)
+}
+
+func (s *Server) docsHandler(c *gin.Context) {
+	c.File("ui/templates/docs.html")
+}
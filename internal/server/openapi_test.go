@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"orderservice/internal/apispec"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/schema"
+	"orderservice/internal/webhook"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// Опубликованный api/openapi.json должен совпадать со сгенерированным,
+// обновить его можно через make spec
+func TestOpenAPIPublished(t *testing.T) {
+	want, err := os.ReadFile("../../api/openapi.json")
+	require.NoError(t, err)
+
+	got, err := apispec.MarshalIndent(OpenAPI())
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(got), "api/openapi.json is outdated, run make spec")
+}
+
+type adminDB struct {
+	orderdb.OrderDB
+}
+
+func (adminDB) Restore(context.Context) error { return nil }
+
+func (adminDB) SchemaVersions(context.Context) ([]orderdb.VersionCount, error) {
+	return nil, nil
+}
+
+func (adminDB) SeqProgress(context.Context) (orderdb.SeqProgress, error) {
+	return orderdb.SeqProgress{}, nil
+}
+
+func (adminDB) Rewind(context.Context, schema.SeqNumber) error { return nil }
+
+type fakeConsumer struct{}
+
+func (fakeConsumer) Seek(context.Context, schema.SeqNumber) error           { return nil }
+func (fakeConsumer) Replay(context.Context, orderevent.ReplayOptions) error { return nil }
+func (fakeConsumer) ShadowReport() orderevent.ReplayReport                  { return orderevent.ReplayReport{} }
+
+// Каждый маршрут описан в спецификации и наоборот
+func TestOpenAPIRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := NewServer(Config{AdminToken: "secret"}, Dependencies{
+		Log:      logrus.New(),
+		DB:       adminDB{},
+		Consumer: fakeConsumer{},
+		Webhooks: struct{ webhook.Store }{},
+	})
+
+	routes := make(map[string]bool)
+	for _, r := range s.router().Routes() {
+		routes[strings.ToLower(r.Method)+" "+apispec.OpenAPIPath(r.Path)] = true
+	}
+
+	documented := make(map[string]bool)
+	for path, item := range OpenAPI().Paths {
+		for method := range item {
+			documented[method+" "+path] = true
+		}
+	}
+
+	require.Equal(t, routes, documented)
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := NewServer(Config{}, Dependencies{Log: logrus.New()})
+
+	rec := httptest.NewRecorder()
+	s.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"openapi":"3.1.0"`)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"orderservice/internal/orderdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ParamQuery = "q"
+
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+func (s *Server) searchHandler(c *gin.Context) {
+	searcher, ok := s.deps.DB.(orderdb.Searcher)
+	if !ok {
+		s.replyError(c, fmt.Errorf("%w: search is not supported", ErrBadRequest))
+		return
+	}
+
+	query := c.Query(ParamQuery)
+	if query == "" {
+		s.replyError(c, fmt.Errorf("%w: empty query", ErrBadRequest))
+		return
+	}
+
+	limit, err := parseUint(c.Request.URL.Query(), ParamLimit)
+	if s.replyError(c, err) {
+		return
+	}
+
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	res, err := searcher.Search(c, query, limit)
+	if s.replyError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
@@ -5,55 +5,125 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"orderservice/internal/analytics"
 	"orderservice/internal/orderdb"
+	"orderservice/internal/orderevent"
 	"orderservice/internal/schema"
+	"orderservice/internal/webhook"
 	"text/template"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 )
 
 const (
-	shutdownTimeout = 5 * time.Second
+	defaultShutdownTimeout = 5 * time.Second
 )
 
+var ErrBadRequest = errors.New("bad request")
+
 type Config struct {
-	Address string
+	Address         string
+	ShutdownTimeout time.Duration
+	RateLimit       RateLimit
+	// AdminToken включает маршруты /admin и управление webhook подписками,
+	// запросы к ним передают заголовок Authorization: Bearer <token>.
+	// Пустой токен - маршруты не регистрируются.
+	AdminToken string
+}
+
+type RateLimit struct {
+	// RPS ограничивает число запросов в секунду ко всему серверу, 0 - без ограничения
+	RPS   float64
+	Burst int
 }
 
 type Dependencies struct {
-	Log *logrus.Logger
-	DB  orderdb.OrderDB
+	Log       *logrus.Logger
+	DB        orderdb.OrderDB
+	Consumer  orderevent.ControllableConsumer
+	Exporter  orderdb.StreamingOrderDB
+	Analytics *analytics.Service
+	// Webhooks включает управление подписками, nil - маршруты не регистрируются
+	Webhooks webhook.Store
 }
 
 type Server struct {
 	cfg  Config
 	deps Dependencies
 
-	log *logrus.Entry
+	log     *logrus.Entry
+	limiter *rate.Limiter
 }
 
 func NewServer(cfg Config, deps Dependencies) *Server {
-	return &Server{
-		cfg:  cfg,
-		deps: deps,
-		log:  deps.Log.WithField("component", "server"),
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
 	}
+
+	s := &Server{
+		cfg:     cfg,
+		deps:    deps,
+		log:     deps.Log.WithField("component", "server"),
+		limiter: rate.NewLimiter(rate.Inf, 0),
+	}
+	s.SetRateLimit(cfg.RateLimit)
+
+	return s
 }
 
-func (s *Server) Run(ctx context.Context) error {
+// SetRateLimit меняет ограничение запросов без перезапуска сервера.
+func (s *Server) SetRateLimit(limit RateLimit) {
+	if limit.RPS <= 0 {
+		s.limiter.SetLimit(rate.Inf)
+		return
+	}
+
+	if limit.Burst <= 0 {
+		limit.Burst = max(1, int(limit.RPS))
+	}
+
+	s.limiter.SetBurst(limit.Burst)
+	s.limiter.SetLimit(rate.Limit(limit.RPS))
+}
+
+// router регистрирует маршруты. При добавлении маршрута его нужно
+// описать в OpenAPI и обновить api/openapi.json.
+func (s *Server) router() *gin.Engine {
 	router := gin.New()
-	router.Use(LoggerMiddleware(s.log))
+	router.Use(LoggerMiddleware(s.log), RateLimitMiddleware(s.limiter))
 
 	router.GET("/", s.uiHandler)
+	router.GET("docs", s.docsHandler)
+	router.GET("openapi.json", s.openAPIHandler)
 	router.GET("orders/:id", s.getHandler)
 	router.GET("orders/", s.listHandler)
+	router.GET("orders/export", s.exportHandler)
+	router.GET("orders/search", s.searchHandler)
+	s.registerLookups(router)
+	router.GET("analytics", s.analyticsHandler)
+	if s.cfg.AdminToken != "" {
+		protected := router.Group("", AdminAuthMiddleware(s.cfg.AdminToken))
+		s.registerWebhooks(protected)
+		s.registerAdmin(protected)
+	}
+
+	return router
+}
+
+// Handler возвращает обработчик всех маршрутов, например для
+// httptest.Server в тестах.
+func (s *Server) Handler() http.Handler {
+	return s.router()
+}
 
+func (s *Server) Run(ctx context.Context) error {
 	var (
 		srv = &http.Server{
 			Addr:    s.cfg.Address,
-			Handler: router,
+			Handler: s.Handler(),
 		}
 	)
 
@@ -69,7 +139,7 @@ func (s *Server) Run(ctx context.Context) error {
 	select {
 	case <-ctx.Done():
 		s.log.Info("shutting down server gracefully")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
 		defer cancel()
 
 		if err := srv.Shutdown(shutdownCtx); err != nil {
@@ -93,7 +163,12 @@ func (s *Server) getHandler(c *gin.Context) {
 }
 
 func (s *Server) listHandler(c *gin.Context) {
-	res, err := s.deps.DB.ListOrders(c)
+	filter, err := ParseFilter(c.Request.URL.Query())
+	if s.replyError(c, err) {
+		return
+	}
+
+	res, err := s.deps.DB.ListOrders(c, filter)
 	if s.replyError(c, err) {
 		return
 	}
@@ -125,6 +200,10 @@ func (s *Server) replyError(c *gin.Context, err error) bool {
 	switch {
 	case errors.Is(err, orderdb.ErrNotFound):
 		code = http.StatusNotFound
+	case errors.Is(err, ErrBadRequest), errors.Is(err, analytics.ErrInvalidQuery):
+		code = http.StatusBadRequest
+	case errors.Is(err, orderevent.ErrUnsupported):
+		code = http.StatusConflict
 	}
 
 	c.JSON(code, &resp)
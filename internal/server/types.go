@@ -1,5 +1,33 @@
 package server
 
+import (
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/schema"
+	"orderservice/internal/webhook"
+)
+
 type ErrorResponse struct {
 	Message string
 }
+
+type SeqRequest struct {
+	Seq schema.SeqNumber `json:"seq"`
+}
+
+type SeqResponse struct {
+	Seq schema.SeqNumber `json:"seq"`
+}
+
+type SchemaVersionsResponse struct {
+	Current  int                    `json:"current"`
+	Versions []orderdb.VersionCount `json:"versions"`
+}
+
+// WebhookRequest создает подписку. Если Secret пуст, он генерируется.
+type WebhookRequest struct {
+	URL        string                 `json:"url"`
+	EventTypes []orderevent.EventType `json:"event_types,omitempty"`
+	Filter     webhook.Filter         `json:"filter"`
+	Secret     string                 `json:"secret,omitempty"`
+}
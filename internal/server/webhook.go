@@ -0,0 +1,131 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"orderservice/internal/webhook"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+func (s *Server) registerWebhooks(router gin.IRouter) {
+	if s.deps.Webhooks == nil {
+		return
+	}
+
+	router.POST("webhooks", s.createWebhookHandler)
+	router.GET("webhooks", s.listWebhooksHandler)
+	router.GET("webhooks/:id", s.getWebhookHandler)
+	router.DELETE("webhooks/:id", s.deleteWebhookHandler)
+	router.GET("webhooks/:id/deliveries", s.webhookDeliveriesHandler)
+}
+
+// createWebhookHandler возвращает секрет подписи только в ответе на
+// создание, дальше он не показывается.
+func (s *Server) createWebhookHandler(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.replyError(c, fmt.Errorf("%w: %v", ErrBadRequest, err))
+		return
+	}
+
+	sub := webhook.Subscription{
+		ID:         uuid.NewString(),
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		Filter:     req.Filter,
+		Secret:     req.Secret,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := sub.Validate(); err != nil {
+		s.replyError(c, fmt.Errorf("%w: %v", ErrBadRequest, err))
+		return
+	}
+
+	if sub.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); s.replyError(c, err) {
+			return
+		}
+		sub.Secret = hex.EncodeToString(secret)
+	}
+
+	err := s.deps.Webhooks.CreateSubscription(c, sub)
+	if s.replyError(c, err) {
+		return
+	}
+
+	s.log.Infof("webhook %s created for %s", sub.ID, sub.URL)
+	c.JSON(http.StatusCreated, &sub)
+}
+
+func (s *Server) listWebhooksHandler(c *gin.Context) {
+	subs, err := s.deps.Webhooks.Subscriptions(c)
+	if s.replyError(c, err) {
+		return
+	}
+
+	res := make([]webhook.Subscription, 0, len(subs))
+	for _, sub := range subs {
+		sub.Secret = ""
+		res = append(res, sub)
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+func (s *Server) getWebhookHandler(c *gin.Context) {
+	sub, err := s.deps.Webhooks.Subscription(c, c.Param("id"))
+	if s.replyError(c, err) {
+		return
+	}
+
+	sub.Secret = ""
+	c.JSON(http.StatusOK, &sub)
+}
+
+func (s *Server) deleteWebhookHandler(c *gin.Context) {
+	err := s.deps.Webhooks.DeleteSubscription(c, c.Param("id"))
+	if s.replyError(c, err) {
+		return
+	}
+
+	s.log.Infof("webhook %s deleted", c.Param("id"))
+	c.Status(http.StatusNoContent)
+}
+
+// webhookDeliveriesHandler показывает журнал доставок подписки.
+func (s *Server) webhookDeliveriesHandler(c *gin.Context) {
+	limit, err := parseUint(c.Request.URL.Query(), ParamLimit)
+	if s.replyError(c, err) {
+		return
+	}
+	if limit == 0 {
+		limit = defaultDeliveriesLimit
+	}
+	limit = min(limit, maxDeliveriesLimit)
+
+	id := c.Param("id")
+	if _, err := s.deps.Webhooks.Subscription(c, id); s.replyError(c, err) {
+		return
+	}
+
+	res, err := s.deps.Webhooks.Deliveries(c, id, limit)
+	if s.replyError(c, err) {
+		return
+	}
+	if res == nil {
+		res = []webhook.Delivery{}
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/schema"
+	"time"
+)
+
+type DispatcherDependencies struct {
+	Store Store
+	// Orders дополняет события заказом для фильтров и тела запроса
+	Orders orderdb.OrderDB
+}
+
+// Dispatcher ставит события в очередь доставок. Реализует
+// orderevent.EventPublisher, чтобы получать события от outbox.Relay:
+// событие считается опубликованным, когда доставки сохранены.
+type Dispatcher struct {
+	deps DispatcherDependencies
+	now  func() time.Time
+}
+
+func NewDispatcher(deps DispatcherDependencies) *Dispatcher {
+	return &Dispatcher{deps: deps, now: time.Now}
+}
+
+func (d *Dispatcher) PublishEvent(ctx context.Context, event orderevent.Event) error {
+	subs, err := d.deps.Store.Subscriptions(ctx)
+	if err != nil || len(subs) == 0 {
+		return err
+	}
+
+	var order *schema.Order
+	if event.Type == orderevent.EventOrderAccepted {
+		o, err := d.deps.Orders.GetOrder(ctx, event.OrderUID)
+		switch {
+		case err == nil:
+			order = &o
+		case !errors.Is(err, orderdb.ErrNotFound):
+			return err
+		}
+	}
+
+	payload, err := json.Marshal(Payload{Event: event, Order: order})
+	if err != nil {
+		return err
+	}
+
+	var deliveries []Delivery
+	for _, sub := range subs {
+		if !sub.Wants(event, order) {
+			continue
+		}
+
+		deliveries = append(deliveries, Delivery{
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        payload,
+			Status:         StatusPending,
+			NextAttemptAt:  d.now(),
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return d.deps.Store.EnqueueDeliveries(ctx, deliveries)
+}
@@ -0,0 +1,139 @@
+// Package webhook доставляет события заказов партнерам HTTP запросами.
+// События из outbox ставятся в очередь доставок для каждой подходящей
+// подписки, Worker отправляет их с подписью HMAC и повторами.
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/schema"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSubscription = errors.New("invalid subscription")
+
+// Filter ограничивает заказы подписки, пустые поля подходят под любое
+// значение. Под непустой фильтр не подходят события без заказа.
+type Filter struct {
+	CustomerID      string `json:"customer_id,omitempty"`
+	DeliveryService string `json:"delivery_service,omitempty"`
+	Currency        string `json:"currency,omitempty"`
+	Locale          string `json:"locale,omitempty"`
+}
+
+func (f Filter) Match(order *schema.Order) bool {
+	if f == (Filter{}) {
+		return true
+	}
+
+	return order != nil && orderdb.Filter{
+		CustomerID:      f.CustomerID,
+		DeliveryService: f.DeliveryService,
+		Currency:        f.Currency,
+		Locale:          f.Locale,
+	}.Match(*order)
+}
+
+type Subscription struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// EventTypes - типы событий подписки, пустой список - все события
+	EventTypes []orderevent.EventType `json:"event_types,omitempty"`
+	Filter     Filter                 `json:"filter"`
+	// Secret - ключ подписи HMAC, возвращается только при создании
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *Subscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidSubscription)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: url must not point to localhost", ErrInvalidSubscription)
+	}
+	if ip := net.ParseIP(host); ip != nil && blockedIP(ip) {
+		return fmt.Errorf("%w: url must not point to a loopback, private or link-local address", ErrInvalidSubscription)
+	}
+
+	for _, typ := range s.EventTypes {
+		if typ != orderevent.EventOrderAccepted && typ != orderevent.EventOrderRejected {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidSubscription, typ)
+		}
+	}
+
+	return nil
+}
+
+// blockedIP - адреса, на которые webhook не отправляется: сам сервис,
+// внутренняя сеть (RFC 1918, fc00::/7) и link-local, в том числе
+// метаданные облака 169.254.169.254.
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// Wants проверяет, нужно ли доставить событие подписке.
+func (s *Subscription) Wants(event orderevent.Event, order *schema.Order) bool {
+	if len(s.EventTypes) > 0 && !slices.Contains(s.EventTypes, event.Type) {
+		return false
+	}
+
+	return s.Filter.Match(order)
+}
+
+type DeliveryStatus string
+
+const (
+	StatusPending   DeliveryStatus = "pending"
+	StatusDelivered DeliveryStatus = "delivered"
+	StatusFailed    DeliveryStatus = "failed"
+)
+
+// Delivery - доставка одного события одной подписке и ее журнал:
+// число попыток и результат последней.
+type Delivery struct {
+	ID             int64                `json:"id"`
+	SubscriptionID string               `json:"subscription_id"`
+	EventID        string               `json:"event_id"`
+	EventType      orderevent.EventType `json:"event_type"`
+	Payload        []byte               `json:"-"`
+	Status         DeliveryStatus       `json:"status"`
+	Attempts       int                  `json:"attempts"`
+	NextAttemptAt  time.Time            `json:"next_attempt_at"`
+	LastStatusCode int                  `json:"last_status_code,omitempty"`
+	LastError      string               `json:"last_error,omitempty"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+}
+
+// Payload - тело запроса к получателю.
+type Payload struct {
+	Event orderevent.Event `json:"event"`
+	Order *schema.Order    `json:"order,omitempty"`
+}
+
+// Store хранит подписки и очередь доставок. Отсутствующая подписка -
+// orderdb.ErrNotFound.
+type Store interface {
+	CreateSubscription(ctx context.Context, sub Subscription) error
+	Subscriptions(ctx context.Context) ([]Subscription, error)
+	Subscription(ctx context.Context, id string) (Subscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+
+	// EnqueueDeliveries пропускает уже поставленные в очередь пары
+	// подписки и события
+	EnqueueDeliveries(ctx context.Context, deliveries []Delivery) error
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error)
+	UpdateDelivery(ctx context.Context, delivery Delivery) error
+	Deliveries(ctx context.Context, subscriptionID string, limit int) ([]Delivery, error)
+}
@@ -0,0 +1,330 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"orderservice/internal/orderdb"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderEvent     = "X-Webhook-Event"
+	// HeaderEventID совпадает при повторных доставках и служит ключом
+	// дедупликации у получателя
+	HeaderEventID = "X-Webhook-Event-Id"
+
+	defaultPollInterval     = time.Second
+	defaultBatchSize        = 50
+	defaultTimeout          = 10 * time.Second
+	defaultMaxAttempts      = 8
+	defaultBackoff          = 5 * time.Second
+	defaultMaxBackoff       = time.Hour
+	defaultFailureThreshold = 5
+	defaultCooldown         = time.Minute
+)
+
+type WorkerConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// Timeout одного запроса
+	Timeout time.Duration
+	// MaxAttempts - после стольких неудачных попыток доставка
+	// помечается failed
+	MaxAttempts int
+	// Backoff удваивается после каждой неудачной попытки до MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// После FailureThreshold ошибок подряд запросы к подписке не
+	// отправляются в течение Cooldown
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+// Lease проверяет, что реплика все еще лидер, реализуется leader.Lease.
+// После паузы процесса лидером могла стать другая реплика, которая уже
+// отправляет те же доставки.
+type Lease interface {
+	Check(ctx context.Context) error
+}
+
+type WorkerDependencies struct {
+	Log   *logrus.Logger
+	Store Store
+	// Client по умолчанию http.Client с Timeout, который не подключается
+	// к loopback, частным и link-local адресам
+	Client *http.Client
+}
+
+// Worker отправляет доставки, срок которых наступил. Должен работать в
+// одном экземпляре, см. internal/leader.
+type Worker struct {
+	cfg  WorkerConfig
+	deps WorkerDependencies
+	now  func() time.Time
+
+	breakers map[string]*breaker
+
+	log *logrus.Entry
+}
+
+func NewWorker(cfg WorkerConfig, deps WorkerDependencies) *Worker {
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.Backoff == 0 {
+		cfg.Backoff = defaultBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.FailureThreshold == 0 {
+		cfg.FailureThreshold = defaultFailureThreshold
+	}
+	if cfg.Cooldown == 0 {
+		cfg.Cooldown = defaultCooldown
+	}
+	if deps.Client == nil {
+		deps.Client = newClient(cfg.Timeout)
+	}
+
+	return &Worker{
+		cfg:      cfg,
+		deps:     deps,
+		now:      time.Now,
+		breakers: make(map[string]*breaker),
+		log:      deps.Log.WithField("component", "webhook"),
+	}
+}
+
+// newClient проверяет адрес при подключении, а не только URL подписки:
+// имя хоста может разрешиться во внутренний адрес.
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip != nil && blockedIP(ip) {
+				return fmt.Errorf("webhook to %s is not allowed", host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Через прокси проверялся бы адрес прокси, а не получателя
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// Run отправляет доставки до отмены ctx.
+func (w *Worker) Run(ctx context.Context, lease Lease) {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.Deliver(ctx, lease); err != nil && !errors.Is(err, context.Canceled) {
+			w.log.Errorf("delivery failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deliver выполняет одну попытку для каждой доставки, срок которой
+// наступил, если реплика все еще лидер. Возвращает число успешных
+// доставок.
+func (w *Worker) Deliver(ctx context.Context, lease Lease) (int, error) {
+	if err := lease.Check(ctx); err != nil {
+		return 0, err
+	}
+
+	due, err := w.deps.Store.DueDeliveries(ctx, w.now(), w.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	subs := make(map[string]*Subscription)
+	delivered := 0
+	for _, d := range due {
+		sub, ok := subs[d.SubscriptionID]
+		if !ok {
+			s, err := w.deps.Store.Subscription(ctx, d.SubscriptionID)
+			if errors.Is(err, orderdb.ErrNotFound) {
+				// Доставки удаленной подписки удаляются вместе с ней
+				continue
+			}
+			if err != nil {
+				return delivered, err
+			}
+			sub = &s
+			subs[d.SubscriptionID] = sub
+		}
+
+		if w.attempt(ctx, sub, &d) {
+			delivered++
+		}
+
+		if err := w.deps.Store.UpdateDelivery(ctx, d); err != nil {
+			return delivered, err
+		}
+	}
+
+	return delivered, nil
+}
+
+// attempt отправляет запрос и обновляет состояние доставки.
+func (w *Worker) attempt(ctx context.Context, sub *Subscription, d *Delivery) bool {
+	now := w.now()
+	b := w.breaker(sub.ID)
+	if until := b.openUntil; now.Before(until) {
+		// Попытка не расходуется, доставка откладывается до закрытия
+		d.NextAttemptAt = until
+		d.UpdatedAt = now
+		return false
+	}
+
+	d.Attempts++
+	d.UpdatedAt = now
+	code, err := w.send(ctx, sub, d)
+	d.LastStatusCode = code
+	if err == nil {
+		d.Status = StatusDelivered
+		d.LastError = ""
+		b.success()
+		return true
+	}
+
+	d.LastError = err.Error()
+	if b.failure(now, w.cfg.FailureThreshold, w.cfg.Cooldown) {
+		w.log.Warnf("subscription %s disabled until %s after %d failures",
+			sub.ID, b.openUntil.Format(time.RFC3339), w.cfg.FailureThreshold)
+	}
+
+	if d.Attempts >= w.cfg.MaxAttempts {
+		d.Status = StatusFailed
+		w.log.Errorf("giving up delivery of event %s to %s: %v", d.EventID, sub.URL, err)
+		return false
+	}
+
+	d.NextAttemptAt = now.Add(w.backoff(d.Attempts))
+	return false
+}
+
+func (w *Worker) backoff(attempts int) time.Duration {
+	backoff := w.cfg.Backoff
+	for i := 1; i < attempts && backoff < w.cfg.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, w.cfg.MaxBackoff)
+}
+
+func (w *Worker) send(ctx context.Context, sub *Subscription, d *Delivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(w.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderEvent, string(d.EventType))
+	req.Header.Set(HeaderEventID, d.EventID)
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, timestamp, d.Payload))
+
+	resp, err := w.deps.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Тело дочитывается, чтобы соединение вернулось в пул
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign возвращает значение заголовка X-Webhook-Signature: HMAC-SHA256
+// от "timestamp.body". Получатель проверяет подпись и отклоняет запросы
+// с устаревшим timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет подпись запроса, для получателей и тестов.
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+func (w *Worker) breaker(subscriptionID string) *breaker {
+	b, ok := w.breakers[subscriptionID]
+	if !ok {
+		b = &breaker{}
+		w.breakers[subscriptionID] = b
+	}
+	return b
+}
+
+// breaker - автомат отключения подписки после ошибок подряд. После
+// Cooldown пропускается одна попытка: успех закрывает автомат, ошибка
+// снова открывает его.
+type breaker struct {
+	failures  int
+	openUntil time.Time
+}
+
+func (b *breaker) success() {
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+// failure возвращает true, если автомат открылся.
+func (b *breaker) failure(now time.Time, threshold int, cooldown time.Duration) bool {
+	b.failures++
+	if b.failures < threshold {
+		return false
+	}
+
+	b.openUntil = now.Add(cooldown)
+	return true
+}
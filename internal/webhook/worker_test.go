@@ -0,0 +1,346 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"orderservice/internal/orderdb"
+	"orderservice/internal/orderevent"
+	"orderservice/internal/schema"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+type memStore struct {
+	mu         sync.Mutex
+	subs       []Subscription
+	deliveries []Delivery
+}
+
+func (m *memStore) CreateSubscription(_ context.Context, sub Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subs = append(m.subs, sub)
+	return nil
+}
+
+func (m *memStore) Subscriptions(context.Context) ([]Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Subscription(nil), m.subs...), nil
+}
+
+func (m *memStore) Subscription(_ context.Context, id string) (Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, sub := range m.subs {
+		if sub.ID == id {
+			return sub, nil
+		}
+	}
+	return Subscription{}, orderdb.ErrNotFound
+}
+
+func (m *memStore) DeleteSubscription(context.Context, string) error { return nil }
+
+func (m *memStore) EnqueueDeliveries(_ context.Context, deliveries []Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range deliveries {
+		d.ID = int64(len(m.deliveries) + 1)
+		m.deliveries = append(m.deliveries, d)
+	}
+	return nil
+}
+
+func (m *memStore) DueDeliveries(_ context.Context, now time.Time, limit int) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ret []Delivery
+	for _, d := range m.deliveries {
+		if d.Status == StatusPending && !d.NextAttemptAt.After(now) && len(ret) < limit {
+			ret = append(ret, d)
+		}
+	}
+	return ret, nil
+}
+
+func (m *memStore) UpdateDelivery(_ context.Context, d Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries[d.ID-1] = d
+	return nil
+}
+
+func (m *memStore) Deliveries(context.Context, string, int) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Delivery(nil), m.deliveries...), nil
+}
+
+type fakeOrders struct {
+	orderdb.OrderDB
+	orders map[schema.OrderUID]schema.Order
+}
+
+func (f fakeOrders) GetOrder(_ context.Context, uid schema.OrderUID) (schema.Order, error) {
+	order, ok := f.orders[uid]
+	if !ok {
+		return schema.Order{}, orderdb.ErrNotFound
+	}
+	return order, nil
+}
+
+func newTestWorker(store Store, now *time.Time) *Worker {
+	w := NewWorker(WorkerConfig{
+		MaxAttempts:      3,
+		Backoff:          time.Second,
+		MaxBackoff:       time.Minute,
+		FailureThreshold: 2,
+		Cooldown:         time.Hour,
+	}, WorkerDependencies{
+		Log:   logrus.New(),
+		Store: store,
+		// Получатели в тестах слушают loopback
+		Client: &http.Client{},
+	})
+	w.now = func() time.Time { return *now }
+	return w
+}
+
+func TestDispatcherFilter(t *testing.T) {
+	store := &memStore{subs: []Subscription{
+		{ID: "all"},
+		{ID: "rejected", EventTypes: []orderevent.EventType{orderevent.EventOrderRejected}},
+		{ID: "wbil", Filter: Filter{DeliveryService: "wbil"}},
+		{ID: "meest", Filter: Filter{DeliveryService: "meest"}},
+	}}
+	order := schema.Order{OrderUID: "uid", DeliveryService: "wbil"}
+	d := NewDispatcher(DispatcherDependencies{
+		Store:  store,
+		Orders: fakeOrders{orders: map[schema.OrderUID]schema.Order{"uid": order}},
+	})
+
+	event := orderevent.NewEvent(orderevent.EventOrderAccepted, "uid", 1, "")
+	require.NoError(t, d.PublishEvent(context.Background(), event))
+
+	var subs []string
+	for _, dl := range store.deliveries {
+		subs = append(subs, dl.SubscriptionID)
+		require.Equal(t, event.ID, dl.EventID)
+		require.Equal(t, StatusPending, dl.Status)
+	}
+	require.Equal(t, []string{"all", "wbil"}, subs)
+}
+
+func TestWorkerSignedDelivery(t *testing.T) {
+	type request struct {
+		verified bool
+		event    string
+		eventID  string
+	}
+	requests := make(chan request, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{
+			verified: Verify("secret", r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)),
+			event:    r.Header.Get(HeaderEvent),
+			eventID:  r.Header.Get(HeaderEventID),
+		}
+	}))
+	defer receiver.Close()
+
+	now := time.Now()
+	store := &memStore{subs: []Subscription{{ID: "sub", URL: receiver.URL, Secret: "secret"}}}
+	require.NoError(t, store.EnqueueDeliveries(context.Background(), []Delivery{{
+		SubscriptionID: "sub",
+		EventID:        "event",
+		EventType:      orderevent.EventOrderAccepted,
+		Payload:        []byte(`{"event":{}}`),
+		Status:         StatusPending,
+		NextAttemptAt:  now,
+	}}))
+
+	n, err := newTestWorker(store, &now).Deliver(context.Background(), lease{})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	got := <-requests
+	require.True(t, got.verified)
+	require.Equal(t, string(orderevent.EventOrderAccepted), got.event)
+	require.Equal(t, "event", got.eventID)
+	require.Equal(t, StatusDelivered, store.deliveries[0].Status)
+	require.Equal(t, http.StatusOK, store.deliveries[0].LastStatusCode)
+	require.False(t, Verify("other", "1", []byte("x"), Sign("secret", "1", []byte("x"))))
+}
+
+// Ошибки откладывают доставку с растущей задержкой, после порога
+// подписка отключается без расхода попыток
+func TestWorkerRetryAndBreaker(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+	store := &memStore{subs: []Subscription{{ID: "sub", URL: receiver.URL}}}
+	require.NoError(t, store.EnqueueDeliveries(ctx, []Delivery{
+		{SubscriptionID: "sub", EventID: "1", Status: StatusPending, NextAttemptAt: now},
+	}))
+	w := newTestWorker(store, &now)
+
+	_, err := w.Deliver(ctx, lease{})
+	require.NoError(t, err)
+	d := store.deliveries[0]
+	require.Equal(t, 1, d.Attempts)
+	require.Equal(t, http.StatusInternalServerError, d.LastStatusCode)
+	require.Equal(t, now.Add(time.Second), d.NextAttemptAt)
+
+	// До срока повтора запросов нет
+	_, err = w.Deliver(ctx, lease{})
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+
+	now = now.Add(time.Second)
+	_, err = w.Deliver(ctx, lease{})
+	require.NoError(t, err)
+	d = store.deliveries[0]
+	require.Equal(t, 2, d.Attempts)
+	// Вторая ошибка подряд открывает автомат
+	require.Equal(t, now.Add(2*time.Second), d.NextAttemptAt)
+
+	now = now.Add(2 * time.Second)
+	_, err = w.Deliver(ctx, lease{})
+	require.NoError(t, err)
+	d = store.deliveries[0]
+	require.Equal(t, 2, calls)
+	require.Equal(t, 2, d.Attempts)
+	require.Equal(t, now.Add(time.Hour-2*time.Second), d.NextAttemptAt)
+
+	// После cooldown последняя попытка исчерпывает лимит
+	now = d.NextAttemptAt
+	_, err = w.Deliver(ctx, lease{})
+	require.NoError(t, err)
+	d = store.deliveries[0]
+	require.Equal(t, 3, calls)
+	require.Equal(t, StatusFailed, d.Status)
+}
+
+func TestSubscriptionValidate(t *testing.T) {
+	cases := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: "https://partner.example.com/hooks"},
+		{url: "http://203.0.113.10:8080/hooks"},
+		{url: "ftp://partner.example.com", wantErr: true},
+		{url: "/hooks", wantErr: true},
+		{url: "http://localhost:8080", wantErr: true},
+		{url: "http://api.localhost", wantErr: true},
+		{url: "http://127.0.0.1", wantErr: true},
+		{url: "http://[::1]:8080", wantErr: true},
+		{url: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{url: "http://0.0.0.0", wantErr: true},
+		{url: "http://10.0.0.5/hooks", wantErr: true},
+		{url: "http://172.16.3.4/hooks", wantErr: true},
+		{url: "http://192.168.1.10:8080", wantErr: true},
+		{url: "http://[fd00::1]/hooks", wantErr: true},
+		{url: "http://[ff01::1]", wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.url, func(t *testing.T) {
+			sub := Subscription{URL: c.url}
+			err := sub.Validate()
+			if c.wantErr {
+				require.ErrorIs(t, err, ErrInvalidSubscription)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+// Клиент по умолчанию не подключается к loopback, даже если URL прошел
+// проверку, например имя хоста разрешилось в 127.0.0.1
+func TestWorkerBlocksLoopback(t *testing.T) {
+	requests := make(chan struct{}, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		requests <- struct{}{}
+	}))
+	defer receiver.Close()
+
+	now := time.Now()
+	store := &memStore{subs: []Subscription{{ID: "sub", URL: receiver.URL}}}
+	require.NoError(t, store.EnqueueDeliveries(context.Background(), []Delivery{
+		{SubscriptionID: "sub", EventID: "1", Status: StatusPending, NextAttemptAt: now},
+	}))
+
+	w := NewWorker(WorkerConfig{}, WorkerDependencies{Log: logrus.New(), Store: store})
+	w.now = func() time.Time { return now }
+	_, err := w.Deliver(context.Background(), lease{})
+	require.NoError(t, err)
+
+	require.Empty(t, requests)
+	require.Equal(t, 1, store.deliveries[0].Attempts)
+	require.Contains(t, store.deliveries[0].LastError, "not allowed")
+}
+
+// Клиент по умолчанию не подключается к адресам внутренней сети.
+// Проверка срабатывает до подключения, поэтому адреса не должны быть
+// доступны.
+func TestClientBlocksPrivate(t *testing.T) {
+	client := newClient(time.Second)
+	for _, url := range []string{
+		"http://10.0.0.5/hooks",
+		"http://172.16.3.4/hooks",
+		"http://192.168.1.10:8080/hooks",
+		"http://[fd00::1]/hooks",
+	} {
+		t.Run(url, func(t *testing.T) {
+			_, err := client.Post(url, "application/json", nil)
+			require.ErrorContains(t, err, "not allowed")
+		})
+	}
+}
+
+// lease - лидерство, которое потеряно, если lost
+type lease struct {
+	lost bool
+}
+
+func (l lease) Check(context.Context) error {
+	if l.lost {
+		return errors.New("leader lease lost")
+	}
+	return nil
+}
+
+// Реплика, потерявшая лидерство, не отправляет доставки параллельно с
+// новым лидером
+func TestWorkerLeaseLost(t *testing.T) {
+	now := time.Now()
+	store := &memStore{subs: []Subscription{{ID: "sub", URL: "https://partner.example.com/hooks"}}}
+	require.NoError(t, store.EnqueueDeliveries(context.Background(), []Delivery{
+		{SubscriptionID: "sub", EventID: "1", Status: StatusPending, NextAttemptAt: now},
+	}))
+
+	n, err := newTestWorker(store, &now).Deliver(context.Background(), lease{lost: true})
+	require.Error(t, err)
+	require.Zero(t, n)
+	require.Zero(t, store.deliveries[0].Attempts)
+}
@@ -1,3 +1,5 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 CREATE TABLE IF NOT EXISTS orderDB 
 (
 	order_uid 	VARCHAR(64) PRIMARY KEY,
@@ -10,4 +12,161 @@ CREATE TABLE IF NOT EXISTS seqDB
 	seq			NUMERIC
 );
 
-INSERT INTO seqDB (id, seq) VALUES (1, 0);
\ No newline at end of file
+INSERT INTO seqDB (id, seq) VALUES (1, 0);
+
+-- Версия схемы JSON заказа. Строки, сохраненные до ее появления,
+-- имеют версию 1 и приводятся к текущей при чтении.
+ALTER TABLE orderDB ADD COLUMN IF NOT EXISTS schema_version INT NOT NULL DEFAULT 1;
+
+-- Товары заказа массивом. Items в старых заказах хранится объектом.
+CREATE OR REPLACE FUNCTION order_items(data JSONB) RETURNS JSONB
+LANGUAGE SQL IMMUTABLE AS $$
+	SELECT CASE jsonb_typeof(data->'Items')
+		WHEN 'array' THEN data->'Items'
+		WHEN 'object' THEN jsonb_build_array(data->'Items')
+		ELSE '[]'::jsonb
+	END
+$$;
+
+-- Время создания заказа, NULL для некорректных значений date_created.
+-- Время без смещения считается UTC: TimeZone закреплен за функцией, иначе
+-- результат IMMUTABLE функции зависел бы от настроек сессии. AT TIME ZONE
+-- не подходит, так как отбрасывает смещение, указанное в значении.
+CREATE OR REPLACE FUNCTION order_created_at(data JSONB) RETURNS TIMESTAMPTZ
+LANGUAGE plpgsql IMMUTABLE SET TimeZone = 'UTC' AS $$
+BEGIN
+	RETURN (data->>'date_created')::timestamptz;
+EXCEPTION WHEN others THEN
+	RETURN NULL;
+END
+$$;
+
+-- Время оплаты: раньше payment_dt хранился в секундах Unix. Время без
+-- смещения считается UTC, как в order_created_at
+CREATE OR REPLACE FUNCTION order_paid_at(data JSONB) RETURNS TIMESTAMPTZ
+LANGUAGE plpgsql IMMUTABLE SET TimeZone = 'UTC' AS $$
+BEGIN
+	IF jsonb_typeof(data->'Payment'->'payment_dt') = 'number' THEN
+		RETURN to_timestamp((data->'Payment'->>'payment_dt')::bigint);
+	END IF;
+	RETURN (data->'Payment'->>'payment_dt')::timestamptz;
+EXCEPTION WHEN others THEN
+	RETURN NULL;
+END
+$$;
+
+-- Время создания и оплаты записываются сервисом вместе с заказом,
+-- для уже сохраненных заказов заполняются из JSON
+ALTER TABLE orderDB ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+ALTER TABLE orderDB ADD COLUMN IF NOT EXISTS paid_at TIMESTAMPTZ;
+UPDATE orderDB SET created_at = order_created_at(data), paid_at = order_paid_at(data)
+	WHERE created_at IS NULL;
+
+-- Текст для полнотекстового поиска: покупатель, контакты, товары и бренды
+CREATE OR REPLACE FUNCTION order_search_text(data JSONB) RETURNS TEXT
+LANGUAGE SQL IMMUTABLE AS $$
+	SELECT lower(concat_ws(' ',
+		data->>'order_uid',
+		data->>'track_number',
+		data->>'customer_id',
+		data->'Delivery'->>'name',
+		data->'Delivery'->>'phone',
+		data->'Delivery'->>'email',
+		data->'Delivery'->>'city',
+		(SELECT string_agg(concat_ws(' ', item->>'name', item->>'brand'), ' ')
+			FROM jsonb_array_elements(order_items(data)) AS item)
+	))
+$$;
+
+ALTER TABLE orderDB ADD COLUMN IF NOT EXISTS search_text TEXT
+	GENERATED ALWAYS AS (order_search_text(data)) STORED;
+
+CREATE INDEX IF NOT EXISTS orderdb_search_fts_idx ON orderDB
+	USING GIN (to_tsvector('simple', search_text));
+CREATE INDEX IF NOT EXISTS orderdb_search_trgm_idx ON orderDB
+	USING GIN (search_text gin_trgm_ops);
+-- Поиск по телефону без учета пробелов, скобок и дефисов
+CREATE INDEX IF NOT EXISTS orderdb_phone_idx ON orderDB
+	((regexp_replace(data->'Delivery'->>'phone', '\D', '', 'g')));
+
+-- Индексы для поиска по вторичным ключам
+CREATE INDEX IF NOT EXISTS orderdb_track_number_idx ON orderDB ((data->>'track_number'));
+CREATE INDEX IF NOT EXISTS orderdb_transaction_idx ON orderDB ((data->'Payment'->>'transaction'));
+CREATE INDEX IF NOT EXISTS orderdb_customer_id_idx ON orderDB ((data->>'customer_id'));
+CREATE INDEX IF NOT EXISTS orderdb_items_idx ON orderDB USING GIN ((data->'Items') jsonb_path_ops);
+
+-- Индекс для выборок и аналитики по времени создания
+CREATE INDEX IF NOT EXISTS orderdb_created_at_idx ON orderDB (created_at);
+
+-- Выбор лидера: advisory блокировка удерживается сессией лидера, token
+-- увеличивается при каждом захвате и служит fencing token
+CREATE TABLE IF NOT EXISTS leader_leases
+(
+	name 		TEXT PRIMARY KEY,
+	token 		BIGINT NOT NULL,
+	holder 		TEXT NOT NULL,
+	acquired_at TIMESTAMPTZ NOT NULL,
+	renewed_at 	TIMESTAMPTZ NOT NULL
+);
+
+-- Transactional outbox: события записываются в транзакции с заказом и
+-- публикуются internal/outbox. Для каждого получателя (target) событие
+-- хранится отдельной строкой, event_id служит ключом дедупликации.
+CREATE TABLE IF NOT EXISTS outbox
+(
+	id 				BIGSERIAL PRIMARY KEY,
+	event_id 		UUID NOT NULL,
+	target 			TEXT NOT NULL,
+	event_type 		TEXT NOT NULL,
+	order_uid 		VARCHAR(64) NOT NULL,
+	payload 		JSONB NOT NULL,
+	created_at 		TIMESTAMPTZ NOT NULL DEFAULT now(),
+	published_at 	TIMESTAMPTZ,
+	attempts 		INT NOT NULL DEFAULT 0,
+	last_error 		TEXT,
+	UNIQUE (event_id, target)
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (target, id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_published_at_idx ON outbox (published_at);
+
+-- Подписки партнеров на события заказов
+CREATE TABLE IF NOT EXISTS webhook_subscriptions
+(
+	id 			UUID PRIMARY KEY,
+	url 		TEXT NOT NULL,
+	event_types TEXT[] NOT NULL DEFAULT '{}',
+	filter 		JSONB NOT NULL DEFAULT '{}',
+	secret 		TEXT NOT NULL,
+	created_at 	TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Очередь и журнал доставок: одна строка на пару подписки и события
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+	id 					BIGSERIAL PRIMARY KEY,
+	subscription_id 	UUID NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+	event_id 			UUID NOT NULL,
+	event_type 			TEXT NOT NULL,
+	payload 			JSONB NOT NULL,
+	status 				TEXT NOT NULL,
+	attempts 			INT NOT NULL DEFAULT 0,
+	next_attempt_at 	TIMESTAMPTZ NOT NULL,
+	last_status_code 	INT,
+	last_error 			TEXT,
+	created_at 			TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at 			TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at)
+	WHERE status = 'pending';
+
+-- Номера обработанных сообщений выше seqDB.seq. seqDB.seq - low-water
+-- mark: все сообщения с номерами не выше него обработаны, поэтому после
+-- сбоя обработка продолжается с первого пропуска. Номер записывается в
+-- транзакции с заказом, отметка сдвигается после нее отдельно
+CREATE TABLE IF NOT EXISTS processed_seqs
+(
+	seq 	BIGINT PRIMARY KEY
+);